@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"reflect"
 
 	"github.com/BurntSushi/toml"
 )
@@ -13,6 +14,8 @@ type EnvConfig struct {
 	Log      Log      `toml:"log"`
 	Ethereum Ethereum `toml:"ethereum"`
 	Cron     Cron     `toml:"cron"`
+	Storage  Storage  `toml:"storage"`
+	Webhook  Webhook  `toml:"webhook"`
 }
 
 type Server struct {
@@ -34,12 +37,48 @@ type Log struct {
 }
 
 type Ethereum struct {
-	Url string `toml:"url"`
+	ChainID               int        `toml:"chain_id"`
+	Url                   string     `toml:"url"`
+	WsUrl                 string     `toml:"ws_url"`
+	Providers             []Provider `toml:"providers"`
+	HealthCheckInterval   int        `toml:"health_check_interval"`
+	MaxHeadLag            int        `toml:"max_head_lag"`
+	RequestTimeout        int        `toml:"request_timeout"`
+	RetryMaxAttempts      int        `toml:"retry_max_attempts"`
+	RetryInitialBackoffMs int        `toml:"retry_initial_backoff_ms"`
+	RetryMaxBackoffMs     int        `toml:"retry_max_backoff_ms"`
+	RateLimit             float64    `toml:"rate_limit"`
+	RateLimitBurst        int        `toml:"rate_limit_burst"`
+	ResolveTokenMetadata  bool       `toml:"resolve_token_metadata"`
+	Tracer                string     `toml:"tracer"`
 }
 
-type Cron struct {
+type Provider struct {
 	Url    string `toml:"url"`
-	Period string `toml:"period"`
+	Weight int    `toml:"weight"`
+}
+
+type Cron struct {
+	Period            string `toml:"period"`
+	MaxReorgDepth     int    `toml:"max_reorg_depth"`
+	BackfillBatchSize int    `toml:"backfill_batch_size"`
+	FetchChunkSize    int    `toml:"fetch_chunk_size"`
+}
+
+type Storage struct {
+	Type      string `toml:"type"`
+	Path      string `toml:"path"`
+	MaxBlocks int    `toml:"max_blocks"`
+}
+
+type Webhook struct {
+	Timeout          int `toml:"timeout"`
+	MaxAttempts      int `toml:"max_attempts"`
+	InitialBackoffMs int `toml:"initial_backoff_ms"`
+	MaxBackoffMs     int `toml:"max_backoff_ms"`
+	// AllowPrivateCallbacks lets callback URLs reach loopback, private and
+	// link-local hosts, which are refused by default
+	AllowPrivateCallbacks bool `toml:"allow_private_callbacks"`
 }
 
 var Config EnvConfig
@@ -63,7 +102,7 @@ func InitConfig(folderPath *string, env *string) error {
 		return errors.New("Error decoding config file, " + err.Error())
 	}
 
-	if Config == (EnvConfig{}) {
+	if reflect.ValueOf(Config).IsZero() {
 		return errors.New("Failed to decode config file")
 	}
 
@@ -41,11 +41,41 @@ func TestInitConfig(t *testing.T) {
 					DisableStacktrace: false,
 				},
 				Ethereum: config.Ethereum{
-					Url: "ethereum-rpc-url",
+					ChainID: 1,
+					Url:     "ethereum-rpc-url",
+					WsUrl:   "ethereum-ws-url",
+					Providers: []config.Provider{
+						{Url: "ethereum-rpc-url-primary", Weight: 3},
+						{Url: "ethereum-rpc-url-backup", Weight: 1},
+					},
+					HealthCheckInterval:   30,
+					MaxHeadLag:            5,
+					RequestTimeout:        10,
+					RetryMaxAttempts:      4,
+					RetryInitialBackoffMs: 500,
+					RetryMaxBackoffMs:     10000,
+					RateLimit:             25,
+					RateLimitBurst:        50,
+					ResolveTokenMetadata:  true,
+					Tracer:                "callTracer",
 				},
 				Cron: config.Cron{
-					Url:    "ethereum-rpc-url",
-					Period: "@every 1s",
+					Period:            "@every 1s",
+					MaxReorgDepth:     64,
+					BackfillBatchSize: 100,
+					FetchChunkSize:    20,
+				},
+				Storage: config.Storage{
+					Type:      "bolt",
+					Path:      "./data/test.db",
+					MaxBlocks: 100,
+				},
+				Webhook: config.Webhook{
+					Timeout:               5,
+					MaxAttempts:           6,
+					InitialBackoffMs:      1000,
+					MaxBackoffMs:          60000,
+					AllowPrivateCallbacks: true,
 				},
 			},
 			expectedErr: nil,
@@ -1,70 +1,374 @@
 package cron
 
 import (
+	"context"
+	"errors"
 	"ethereum-parser/config"
+	"ethereum-parser/logger"
 	evm "ethereum-parser/pkg/ethereum-rpc-client"
 	pubsub "ethereum-parser/pkg/pub-sub"
-	"fmt"
+	"ethereum-parser/pkg/storage"
+	"ethereum-parser/util"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/robfig/cron"
 )
 
+// DefaultMismatchRetryPolicy paces refetching blocks whose parent hash does not
+// match a stored block that is still canonical, as when the providers behind
+// the client disagree on the head. The sync fails after MaxAttempts of them and
+// resumes on the next tick.
+var DefaultMismatchRetryPolicy = evm.RetryPolicy{
+	MaxAttempts:    5,
+	InitialBackoff: 500 * time.Millisecond,
+	MaxBackoff:     8 * time.Second,
+	Multiplier:     2,
+	Jitter:         0.2,
+}
+
 type ListenEthereumBlockCron struct {
-	RpcUrl    string
-	Period    string
-	Publisher *pubsub.BlockPublisher
+	Period            string
+	MaxReorgDepth     int
+	BackfillBatchSize int
+	FetchChunkSize    int
+	Tracer            string // finds internal transactions when set
+	MismatchRetry     evm.RetryPolicy
+	Publisher         *pubsub.BlockPublisher
+	Client            evm.RPCClient
+	// HeadWatcher drives the listener from pushed heads when set, leaving
+	// the schedule as a fallback while it is disconnected
+	HeadWatcher *evm.HeadWatcher
+
+	lastUpdateBlock int
+	lastHead        pubsub.HeadEvent
+	mutex           sync.Mutex
 }
 
-func NewListenEthereumBlockCron(publisher *pubsub.BlockPublisher) *ListenEthereumBlockCron {
+func NewListenEthereumBlockCron(publisher *pubsub.BlockPublisher, client evm.RPCClient) *ListenEthereumBlockCron {
 	cronConfig := config.GetConfig().Cron
 
 	return &ListenEthereumBlockCron{
-		RpcUrl:    cronConfig.Url,
-		Period:    cronConfig.Period,
-		Publisher: publisher,
+		Period:            cronConfig.Period,
+		MaxReorgDepth:     cronConfig.MaxReorgDepth,
+		BackfillBatchSize: cronConfig.BackfillBatchSize,
+		FetchChunkSize:    cronConfig.FetchChunkSize,
+		Tracer:            config.GetConfig().Ethereum.Tracer,
+		MismatchRetry:     DefaultMismatchRetryPolicy,
+		Publisher:         publisher,
+		Client:            client,
 	}
 }
 
-func (c *ListenEthereumBlockCron) Start() {
-	// Do something
-
+// Start listens for new blocks until ctx is cancelled, and returns once the
+// running sync and the head watcher stopped.
+func (c *ListenEthereumBlockCron) Start(ctx context.Context) {
+	log := logger.Logger
 	cronInstance := cron.New()
 
-	initBlockNumber, err := evm.GetBlockNumber()
+	checkpoint, err := c.Publisher.Storage().GetCheckpoint()
+	switch {
+	case err == nil:
+		c.lastUpdateBlock = checkpoint.Number
+		log.Info("Resuming from checkpoint block " + strconv.Itoa(checkpoint.Number) + " " + checkpoint.Hash)
+	case errors.Is(err, storage.ErrNoCheckpoint):
+		initBlockNumber, err := c.Client.GetBlockNumber(ctx)
+		if err != nil {
+			log.Error("Error getting block number, " + err.Error())
+			return
+		}
+
+		c.lastUpdateBlock = initBlockNumber - 1
+	default:
+		log.Error("Error loading checkpoint, " + err.Error())
+		return
+	}
+
+	err = cronInstance.AddFunc(c.Period, func() {
+		if c.HeadWatcher != nil && c.HeadWatcher.Connected() {
+			return
+		}
+
+		// Skip the tick while the previous one is still catching up
+		if !c.mutex.TryLock() {
+			return
+		}
+		defer c.mutex.Unlock()
+
+		c.sync(ctx)
+	})
 	if err != nil {
-		fmt.Printf("Error getting block number: %v\n", err)
+		log.Error("Error scheduling block listener, " + err.Error())
 		return
 	}
 
-	lastUpdateBlock := initBlockNumber - 1
+	cronInstance.Start()
 
-	period := c.Period
-	err = cronInstance.AddFunc(period, func() {
-		currentBlock, err := evm.GetBlockNumber()
-		if err != nil {
-			fmt.Printf("Error getting block number: %v\n", err)
+	var wg sync.WaitGroup
+	if c.HeadWatcher != nil {
+		heads := make(chan *evm.Block, 1)
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			c.HeadWatcher.Run(ctx, heads)
+		}()
+		go func() {
+			defer wg.Done()
+			c.listenNewHeads(ctx, heads)
+		}()
+	}
+
+	<-ctx.Done()
+
+	cronInstance.Stop()
+	wg.Wait()
+
+	// Wait for a scheduled sync still writing blocks
+	c.mutex.Lock()
+	c.mutex.Unlock()
+}
+
+// listenNewHeads syncs whenever the node pushes a new head. Unlike scheduled
+// ticks, a pushed head waits for a running sync instead of being skipped.
+func (c *ListenEthereumBlockCron) listenNewHeads(ctx context.Context, heads <-chan *evm.Block) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-heads:
+			c.mutex.Lock()
+			c.sync(ctx)
+			c.mutex.Unlock()
+		}
+	}
+}
+
+// sync publishes every block up to the current head. Callers hold the mutex.
+func (c *ListenEthereumBlockCron) sync(ctx context.Context) {
+	log := logger.Logger
+
+	currentBlock, err := c.Client.GetBlockNumber(ctx)
+	if err != nil {
+		log.Error("Error getting block number, " + err.Error())
+		return
+	}
+
+	if c.lastUpdateBlock >= currentBlock {
+		// No new blocks, but the safe and finalized blocks may still move
+		c.publishHead(ctx)
+		return
+	}
+
+	if gap := currentBlock - c.lastUpdateBlock; c.BackfillBatchSize > 0 && gap > c.BackfillBatchSize {
+		log.Info("Backfilling " + strconv.Itoa(gap) + " blocks from block " + strconv.Itoa(c.lastUpdateBlock+1) + " to " + strconv.Itoa(currentBlock))
+	}
+
+	for c.lastUpdateBlock < currentBlock {
+		batchEnd := currentBlock
+		if c.BackfillBatchSize > 0 && c.lastUpdateBlock+c.BackfillBatchSize < currentBlock {
+			batchEnd = c.lastUpdateBlock + c.BackfillBatchSize
+		}
+
+		if err := c.syncBatch(ctx, batchEnd); err != nil {
+			log.Error(err.Error())
 			return
 		}
 
-		for i := lastUpdateBlock + 1; i <= currentBlock; i++ {
-			block, err := evm.GetBlockByNumber(i)
-			if err != nil {
-				fmt.Printf("Error getting block by number: %v\n", err)
-				return
+		c.publishHead(ctx)
+
+		if batchEnd < currentBlock {
+			log.Info("Backfilled up to block " + strconv.Itoa(c.lastUpdateBlock) + ", " + strconv.Itoa(currentBlock-c.lastUpdateBlock) + " blocks remaining")
+		}
+	}
+}
+
+// syncBatch publishes every block up to end, fetching FetchChunkSize blocks
+// per batch request and rolling back first if the chain reorganized underneath
+// the blocks already published.
+func (c *ListenEthereumBlockCron) syncBatch(ctx context.Context, end int) error {
+	chunkSize := max(c.FetchChunkSize, 1)
+	mismatches := 0
+
+	for c.lastUpdateBlock < end {
+		from := c.lastUpdateBlock + 1
+		to := min(from+chunkSize-1, end)
+
+		blocks, err := c.Client.GetBlocksByRange(ctx, from, to)
+		if err != nil {
+			return errors.New("Error getting blocks by range, " + err.Error())
+		}
+
+		if err := evm.AttachReceipts(ctx, c.Client, blocks); err != nil {
+			return errors.New("Error getting receipts, " + err.Error())
+		}
+
+		if c.Tracer != "" {
+			if err := evm.AttachInternalTransactions(ctx, c.Client, blocks, c.Tracer); err != nil {
+				return errors.New("Error getting internal transactions, " + err.Error())
+			}
+		}
+
+		for _, block := range blocks {
+			number := c.lastUpdateBlock + 1
+
+			parent, err := c.Publisher.GetBlockByNumber(number - 1)
+			if err == nil && !strings.EqualFold(parent.Hash, block.ParentHash) {
+				// rollback moves the checkpoint back to the common ancestor, so
+				// the rest of this chunk has to be fetched again
+				ancestor, err := c.rollback(ctx, number-1)
+				if err != nil {
+					return errors.New("Error rolling back reorganized blocks, " + err.Error())
+				}
+
+				// The stored parent is still canonical, so the block came from
+				// another fork than the one the client reports
+				if ancestor == number-1 {
+					mismatches++
+					if mismatches >= max(c.MismatchRetry.MaxAttempts, 1) {
+						return errors.New("Error syncing block " + strconv.Itoa(number) + ", parent hash " + block.ParentHash + " does not match stored block " + parent.Hash)
+					}
+
+					logger.Logger.Warn("Block " + strconv.Itoa(number) + " does not extend stored block " + parent.Hash + ", fetching it again")
+					if err := sleep(ctx, c.MismatchRetry.Backoff(mismatches)); err != nil {
+						return err
+					}
+				}
+
+				break
 			}
 
-			c.Publisher.AddBlock(block)
+			if err := c.Publisher.AddBlock(block); err != nil {
+				return errors.New("Error storing block, " + err.Error())
+			}
 			c.Publisher.Publish(block)
 
-			lastUpdateBlock = i
+			if err := c.saveCheckpoint(number, block.Hash); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (c *ListenEthereumBlockCron) saveCheckpoint(number int, hash string) error {
+	err := c.Publisher.Storage().SetCheckpoint(storage.Checkpoint{Number: number, Hash: hash})
+	if err != nil {
+		return errors.New("Error saving checkpoint, " + err.Error())
+	}
+
+	c.lastUpdateBlock = number
+
+	return nil
+}
+
+// publishHead publishes the latest processed block together with the safe and
+// finalized blocks whenever any of them moved.
+func (c *ListenEthereumBlockCron) publishHead(ctx context.Context) {
+	head := pubsub.HeadEvent{
+		Latest:    c.lastUpdateBlock,
+		Safe:      c.getTaggedBlockNumber(ctx, evm.TagSafe, c.lastHead.Safe),
+		Finalized: c.getTaggedBlockNumber(ctx, evm.TagFinalized, c.lastHead.Finalized),
+	}
+
+	if head == c.lastHead {
+		return
+	}
+
+	c.lastHead = head
+	c.Publisher.PublishHead(&head)
+}
+
+// getTaggedBlockNumber returns the number of the block behind tag, or fallback
+// when the node cannot resolve it.
+func (c *ListenEthereumBlockCron) getTaggedBlockNumber(ctx context.Context, tag string, fallback int) int {
+	number, err := c.Client.GetBlockNumberByTag(ctx, tag)
+	if err != nil {
+		logger.Logger.Warn("Error getting " + tag + " block number, " + err.Error())
+		return fallback
+	}
+
+	return number
+}
+
+// rollback walks back from number until the stored block matches the canonical
+// chain, removes the orphaned blocks and returns the common ancestor.
+func (c *ListenEthereumBlockCron) rollback(ctx context.Context, number int) (int, error) {
+	log := logger.Logger
 
-			time.Sleep(1 * time.Second)
+	var removedBlocks []*evm.Block
+
+	for ; number >= 0; number-- {
+		if c.MaxReorgDepth > 0 && len(removedBlocks) >= c.MaxReorgDepth {
+			log.Warn("Reorg reached max depth " + strconv.Itoa(c.MaxReorgDepth) + ", assuming block " + strconv.Itoa(number) + " is canonical")
+			break
+		}
+
+		stored, err := c.Publisher.GetBlockByNumber(number)
+		if errors.Is(err, storage.ErrBlockNotFound) {
+			log.Warn("Reorg reached the oldest stored block, assuming block " + strconv.Itoa(number) + " is canonical")
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		canonical, err := c.Client.GetBlockByNumber(ctx, number)
+		if err != nil {
+			return 0, err
 		}
+
+		if strings.EqualFold(canonical.Hash, stored.Hash) {
+			break
+		}
+
+		removedBlocks = append([]*evm.Block{stored}, removedBlocks...)
+	}
+
+	if len(removedBlocks) == 0 {
+		return number, nil
+	}
+
+	for _, block := range removedBlocks {
+		blockNumber, err := util.HexToDecimal(block.Number)
+		if err != nil {
+			return 0, errors.New("error parsing block number, " + err.Error())
+		}
+
+		if err := c.Publisher.RemoveBlock(int(blockNumber)); err != nil {
+			return 0, errors.New("error removing block, " + err.Error())
+		}
+	}
+
+	log.Warn("Chain reorganization detected, removed " + strconv.Itoa(len(removedBlocks)) + " blocks after block " + strconv.Itoa(number))
+
+	c.Publisher.PublishReorg(&pubsub.ReorgEvent{
+		CommonAncestor: number,
+		RemovedBlocks:  removedBlocks,
 	})
 
-	cronInstance.Start()
+	ancestorHash := ""
+	if ancestor, err := c.Publisher.GetBlockByNumber(number); err == nil {
+		ancestorHash = ancestor.Hash
+	}
 
-	select {}
+	if err := c.saveCheckpoint(number, ancestorHash); err != nil {
+		return 0, err
+	}
 
+	return number, nil
+}
+
+func sleep(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
 }
@@ -1,13 +1,24 @@
 package main
 
 import (
+	"context"
 	"ethereum-parser/config"
 	"ethereum-parser/cron"
 	"ethereum-parser/logger"
+	ethereumParser "ethereum-parser/pkg/ethereum-parser"
+	evm "ethereum-parser/pkg/ethereum-rpc-client"
 	pubsub "ethereum-parser/pkg/pub-sub"
+	"ethereum-parser/pkg/storage"
+	"ethereum-parser/pkg/subscription"
+	watchlist "ethereum-parser/pkg/watch-list"
+	"ethereum-parser/pkg/webhook"
 	"ethereum-parser/server"
 
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -32,10 +43,71 @@ func main() {
 		panic("Error initializing logger, " + err.Error())
 	}
 
-	publisher := pubsub.DefaultPublisher
-	cron := cron.NewListenEthereumBlockCron(publisher)
-	go cron.Start()
+	store, err := storage.NewStorage(config.GetConfig().Storage)
+	if err != nil {
+		panic("Error initializing storage, " + err.Error())
+	}
+	defer store.Close()
+
+	publisher := pubsub.NewBlockPublisher(store)
+	publisher.AddressesOf = ethereumParser.InvolvedAddresses
+	pubsub.SetDefaultPublisher(publisher)
+
+	watchLists, err := watchlist.NewRegistry(store)
+	if err != nil {
+		panic("Error loading watch-lists, " + err.Error())
+	}
+	watchlist.SetDefaultRegistry(watchLists)
+
+	subscriptions, err := subscription.NewRegistry(store)
+	if err != nil {
+		panic("Error loading subscriptions, " + err.Error())
+	}
+	subscription.SetDefaultRegistry(subscriptions)
+
+	// Created before the listener starts so that it sees every block
+	webhook.SetDefaultDispatcher(webhook.NewDispatcherFromConfig(publisher, subscriptions, store))
+
+	// Stopped on SIGINT or SIGTERM, the store is closed once everything using
+	// it returned
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var wg sync.WaitGroup
+	run := func(start func(ctx context.Context)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start(ctx)
+		}()
+	}
+
+	client := evm.NewProviderPoolFromConfig()
+	client.CheckHealth(ctx)
+	run(func(ctx context.Context) {
+		client.StartHealthChecks(ctx, time.Duration(config.GetConfig().Ethereum.HealthCheckInterval)*time.Second)
+	})
+
+	pubsub.SetDefaultBlockReader(pubsub.NewBlockReader(publisher, client))
+
+	if config.GetConfig().Ethereum.ResolveTokenMetadata {
+		ethereumParser.SetDefaultTokenResolver(evm.NewTokenMetadataCache(client))
+	}
+
+	cron := cron.NewListenEthereumBlockCron(publisher, client)
+	if wsUrl := config.GetConfig().Ethereum.WsUrl; wsUrl != "" {
+		cron.HeadWatcher = evm.NewHeadWatcher(evm.NewWebSocketClient(wsUrl))
+	}
+	run(cron.Start)
+
+	run(webhook.DefaultDispatcher.Start)
 
 	// Start rest api server
-	server.StartServer()
+	if err := server.StartServer(ctx); err != nil {
+		logger.Logger.Error("Error running server, " + err.Error())
+	}
+
+	stop()
+	wg.Wait()
+	logger.Logger.Info("Server stopped")
 }
@@ -1,23 +1,57 @@
 package ethereumparser
 
 import (
+	"context"
 	"errors"
 	evm "ethereum-parser/pkg/ethereum-rpc-client"
 	pubsub "ethereum-parser/pkg/pub-sub"
+	"ethereum-parser/pkg/storage"
 	"ethereum-parser/util"
-	"strings"
 	"sync"
 )
 
+var _ EthereumParser = (*BasicEthereumParser)(nil)
+
+const DefaultSubscriptionSet = "default"
+
 type BasicEthereumParser struct {
-	Subscriptions map[string]bool
-	mutex         sync.Mutex
+	Subscriptions   *SubscriptionRegistry
+	TokenResolver   TokenResolver
+	tracker         *ConfirmationTracker
+	storage         storage.Storage
+	subscriptionSet string
+	mutex           sync.Mutex
 }
 
 func NewBasicEthereumParser() *BasicEthereumParser {
-	return &BasicEthereumParser{
-		Subscriptions: make(map[string]bool),
+	parser, _ := NewBasicEthereumParserWithStorage(storage.NewMemoryStorage(0), DefaultSubscriptionSet)
+	return parser
+}
+
+// NewBasicEthereumParserWithStorage keeps subscriptions and transaction history
+// in store, restoring the addresses already saved under subscriptionSet.
+func NewBasicEthereumParserWithStorage(store storage.Storage, subscriptionSet string) (*BasicEthereumParser, error) {
+	addresses, err := store.GetSubscriptions(subscriptionSet)
+	if err != nil {
+		return nil, errors.New("error loading subscriptions, " + err.Error())
 	}
+
+	subscriptions := make([]util.Address, len(addresses))
+	for i, address := range addresses {
+		subscribed, err := util.ParseAddress(address)
+		if err != nil {
+			return nil, errors.New("error loading subscriptions, " + err.Error())
+		}
+		subscriptions[i] = subscribed
+	}
+
+	return &BasicEthereumParser{
+		Subscriptions:   NewSubscriptionRegistry(subscriptions...),
+		TokenResolver:   DefaultTokenResolver,
+		tracker:         NewConfirmationTracker(),
+		storage:         store,
+		subscriptionSet: subscriptionSet,
+	}, nil
 }
 
 func (p *BasicEthereumParser) GetCurrentBlock() (*evm.Block, error) {
@@ -33,14 +67,25 @@ func (p *BasicEthereumParser) GetCurrentBlock() (*evm.Block, error) {
 }
 
 func (p *BasicEthereumParser) Subscribe(address string) (bool, error) {
+	return p.SubscribeWithConfirmations(address, ConfirmationPolicy{})
+}
+
+// SubscribeWithConfirmations subscribes to address and reports its transactions
+// as confirmed once policy is satisfied.
+func (p *BasicEthereumParser) SubscribeWithConfirmations(address string, policy ConfirmationPolicy) (bool, error) {
 	p.mutex.Lock()
 	defer p.mutex.Unlock()
 
-	if !util.IsValidAddress(address) {
+	subscribed, err := util.ParseAddress(address)
+	if err != nil {
 		return false, errors.New("invalid address")
 	}
 
-	p.Subscriptions[strings.ToLower(address)] = true
+	if err := p.storage.AddSubscription(p.subscriptionSet, subscribed.Lower()); err != nil {
+		return false, errors.New("error saving subscription, " + err.Error())
+	}
+
+	p.Subscriptions.Add(subscribed, policy)
 
 	return true, nil
 }
@@ -49,17 +94,261 @@ func (p *BasicEthereumParser) UnSubscribe(address string) (bool, error) {
 	p.mutex.Lock()
 	defer p.mutex.Unlock()
 
-	if !util.IsValidAddress(address) {
+	subscribed, err := util.ParseAddress(address)
+	if err != nil {
 		return false, errors.New("invalid address")
 	}
 
-	p.Subscriptions[strings.ToLower(address)] = false
+	if err := p.storage.RemoveSubscription(p.subscriptionSet, subscribed.Lower()); err != nil {
+		return false, errors.New("error removing subscription, " + err.Error())
+	}
+
+	if err := p.storage.RemoveTransactions(p.historyKey(subscribed)); err != nil {
+		return false, errors.New("error removing transactions, " + err.Error())
+	}
+
+	p.Subscriptions.Remove(subscribed)
+	p.tracker.UntrackAddress(subscribed)
 
 	return true, nil
 }
 
-func (p *BasicEthereumParser) GetTransactions() ([]evm.Transaction, error) {
-	var transactions []evm.Transaction
+// Discard removes the subscriptions saved under the subscription set of the
+// parser along with their history. Parsers of a single connection discard
+// themselves once it ends, the storage they share keeps nothing of them.
+func (p *BasicEthereumParser) Discard() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	addresses, err := p.storage.GetSubscriptions(p.subscriptionSet)
+	if err != nil {
+		return errors.New("error loading subscriptions, " + err.Error())
+	}
+
+	for _, address := range addresses {
+		subscribed, err := util.ParseAddress(address)
+		if err != nil {
+			continue
+		}
+		if err := p.storage.RemoveSubscription(p.subscriptionSet, address); err != nil {
+			return errors.New("error removing subscription, " + err.Error())
+		}
+		if err := p.storage.RemoveTransactions(p.historyKey(subscribed)); err != nil {
+			return errors.New("error removing transactions, " + err.Error())
+		}
+		p.Subscriptions.Remove(subscribed)
+	}
+	p.tracker = NewConfirmationTracker()
+
+	return nil
+}
+
+// GetTransactions returns the inbound and outbound transactions recorded for a
+// subscribed address, oldest first.
+func (p *BasicEthereumParser) GetTransactions(address string) ([]evm.Transaction, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	subscribed, err := util.ParseAddress(address)
+	if err != nil {
+		return nil, errors.New("invalid address")
+	}
+
+	if !p.Subscriptions.Contains(subscribed) {
+		return nil, errors.New("address not subscribed")
+	}
+
+	transactions, err := p.storage.GetTransactions(p.historyKey(subscribed))
+	if err != nil {
+		return nil, errors.New("error getting transactions, " + err.Error())
+	}
 
 	return transactions, nil
 }
+
+// ParseBlock records the transactions of block that involve a subscribed
+// address, directly or through a token, NFT or internal transfer, and returns
+// them.
+func (p *BasicEthereumParser) ParseBlock(block *evm.Block) ([]evm.Transaction, error) {
+	return p.parseBlock(p.Subscriptions.Snapshot(), block)
+}
+
+// BlockActivity is what a block holds for the subscribed addresses, all taken
+// from the same snapshot of the subscriptions.
+type BlockActivity struct {
+	Transactions         []evm.Transaction
+	TokenTransfers       []TokenTransfer
+	NFTTransfers         []NFTTransfer
+	InternalTransactions []evm.InternalTransaction
+}
+
+// ParseBlockActivity records the transactions of block like ParseBlock and
+// returns them along with the transfers concerning the subscribed addresses.
+// Subscriptions changing meanwhile do not affect the result.
+func (p *BasicEthereumParser) ParseBlockActivity(ctx context.Context, block *evm.Block) (*BlockActivity, error) {
+	subscriptions := p.Subscriptions.Snapshot()
+
+	transactions, err := p.parseBlock(subscriptions, block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &BlockActivity{
+		Transactions:         transactions,
+		TokenTransfers:       p.resolveTokens(ctx, subscribedTokenTransfers(subscriptions, block)),
+		NFTTransfers:         subscribedNFTTransfers(subscriptions, block),
+		InternalTransactions: subscribedInternalTransactions(subscriptions, block),
+	}, nil
+}
+
+func (p *BasicEthereumParser) parseBlock(subscriptions SubscriptionSnapshot, block *evm.Block) ([]evm.Transaction, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if block == nil || len(subscriptions) == 0 {
+		return nil, nil
+	}
+
+	var targetTxs []evm.Transaction
+
+	for _, tx := range block.Transactions {
+		matched := false
+
+		for _, address := range InvolvedAddresses(tx) {
+			if !subscriptions.Contains(address) {
+				continue
+			}
+
+			if err := p.storage.AddTransaction(p.historyKey(address), tx); err != nil {
+				return nil, errors.New("error saving transaction, " + err.Error())
+			}
+			p.tracker.Track(address, block, tx)
+			matched = true
+		}
+
+		if matched {
+			targetTxs = append(targetTxs, tx)
+		}
+	}
+
+	return targetTxs, nil
+}
+
+// InvolvedAddresses returns the sender and recipient of tx along with the
+// parties of the token, NFT and internal transfers it made, without
+// duplicates.
+func InvolvedAddresses(tx evm.Transaction) []util.Address {
+	var addresses []util.Address
+	seen := make(map[util.Address]bool)
+
+	add := func(address util.Address) {
+		if !seen[address] {
+			seen[address] = true
+			addresses = append(addresses, address)
+		}
+	}
+	addHex := func(address string) {
+		if parsed, err := util.ParseAddress(address); err == nil {
+			add(parsed)
+		}
+	}
+
+	addHex(tx.From)
+	addHex(tx.To)
+	for _, transfer := range tokenTransfers(tx) {
+		add(transfer.From)
+		add(transfer.To)
+	}
+	for _, transfer := range DecodeNFTTransfers(tx) {
+		add(transfer.From)
+		add(transfer.To)
+	}
+	for _, internalTx := range tx.InternalTransactions {
+		addHex(internalTx.From)
+		addHex(internalTx.To)
+	}
+
+	return addresses
+}
+
+// RemoveBlocks forgets the transactions of blocks that were dropped by a chain
+// reorganization and returns the ones that involved a subscribed address.
+func (p *BasicEthereumParser) RemoveBlocks(blocks []*evm.Block) ([]evm.Transaction, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	subscriptions := p.Subscriptions.Snapshot()
+
+	var removedTxs []evm.Transaction
+
+	for _, block := range blocks {
+		for _, tx := range block.Transactions {
+			matched := false
+
+			for _, address := range InvolvedAddresses(tx) {
+				if !subscriptions.Contains(address) {
+					continue
+				}
+
+				if err := p.storage.RemoveTransaction(p.historyKey(address), tx); err != nil {
+					return nil, errors.New("error removing transaction, " + err.Error())
+				}
+				p.tracker.Untrack(address, tx)
+				matched = true
+			}
+
+			if matched {
+				removedTxs = append(removedTxs, tx)
+			}
+		}
+	}
+
+	return removedTxs, nil
+}
+
+// UpdateHead re-evaluates the confirmations of every tracked transaction
+// against head and returns the ones whose status changed. Finalized
+// transactions are no longer tracked.
+func (p *BasicEthereumParser) UpdateHead(head *pubsub.HeadEvent) []TransactionStatus {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	subscriptions := p.Subscriptions.Snapshot()
+
+	return p.tracker.Update(head, func(address util.Address) ConfirmationPolicy {
+		return subscriptions[address]
+	})
+}
+
+// historyKey is the storage key of the transaction history of address. The
+// histories of parsers with their own subscription set are kept apart, so that
+// parsers sharing a storage never remove each other's.
+func (p *BasicEthereumParser) historyKey(address util.Address) string {
+	if p.subscriptionSet == DefaultSubscriptionSet {
+		return address.Lower()
+	}
+	return p.subscriptionSet + "/" + address.Lower()
+}
+
+// ParseInternalTransactions returns the internal transactions of block that
+// moved ether from or to a subscribed address.
+func (p *BasicEthereumParser) ParseInternalTransactions(block *evm.Block) []evm.InternalTransaction {
+	return subscribedInternalTransactions(p.Subscriptions.Snapshot(), block)
+}
+
+func subscribedInternalTransactions(subscriptions SubscriptionSnapshot, block *evm.Block) []evm.InternalTransaction {
+	if block == nil {
+		return nil
+	}
+
+	var internalTxs []evm.InternalTransaction
+	for _, tx := range block.Transactions {
+		for _, internalTx := range tx.InternalTransactions {
+			if subscriptions.ContainsHex(internalTx.From) || subscriptions.ContainsHex(internalTx.To) {
+				internalTxs = append(internalTxs, internalTx)
+			}
+		}
+	}
+
+	return internalTxs
+}
@@ -1,11 +1,16 @@
 package ethereumparser_test
 
 import (
+	"context"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 
 	evmparser "ethereum-parser/pkg/ethereum-parser"
+	evm "ethereum-parser/pkg/ethereum-rpc-client"
+	"ethereum-parser/pkg/storage"
+	"ethereum-parser/util"
 )
 
 func TestBasicEthereumParser(t *testing.T) {
@@ -56,9 +61,186 @@ func TestBasicEthereumParser(t *testing.T) {
 	t.Run("GetTransactions", func(t *testing.T) {
 		parser := evmparser.NewBasicEthereumParser()
 
-		transactions, err := parser.GetTransactions()
+		// Test with invalid address
+		transactions, err := parser.GetTransactions("invalid_address")
+
+		assert.Nil(t, transactions, "Transactions should be nil")
+		assert.EqualError(t, err, "invalid address")
+
+		// Test with address not subscribed
+		transactions, err = parser.GetTransactions("0x7a250d5630B4cF539739dF2C5dAcb4c659F2488D")
+
+		assert.Nil(t, transactions, "Transactions should be nil")
+		assert.EqualError(t, err, "address not subscribed")
+
+		// Test with subscribed address and no blocks
+		parser.Subscribe("0x7a250d5630B4cF539739dF2C5dAcb4c659F2488D")
+		transactions, err = parser.GetTransactions("0x7a250d5630B4cF539739dF2C5dAcb4c659F2488D")
 
 		assert.Empty(t, transactions, "Transactions should be empty")
 		assert.NoError(t, err, "No error expected")
 	})
+
+	t.Run("ParseBlock", func(t *testing.T) {
+		parser := evmparser.NewBasicEthereumParser()
+		address := "0x7a250d5630B4cF539739dF2C5dAcb4c659F2488D"
+		other := "0x0000000000000000000000000000000000000001"
+
+		inbound := evm.Transaction{Hash: "0x1", From: other, To: "0x7a250d5630b4cf539739df2c5dacb4c659f2488d", Value: util.MustParseQuantity("0x1")}
+		outbound := evm.Transaction{Hash: "0x2", From: address, To: other, Value: util.MustParseQuantity("0x2")}
+		unrelated := evm.Transaction{Hash: "0x3", From: other, To: other, Value: util.MustParseQuantity("0x3")}
+		block := &evm.Block{Number: "0x1", Hash: "0x123", Transactions: []evm.Transaction{inbound, outbound, unrelated}}
+
+		// Blocks seen before subscribing are not recorded
+		matched, err := parser.ParseBlock(block)
+
+		assert.NoError(t, err, "No error expected")
+		assert.Empty(t, matched, "No transactions expected before subscribing")
+
+		parser.Subscribe(address)
+		matched, err = parser.ParseBlock(block)
+
+		assert.NoError(t, err, "No error expected")
+		assert.Equal(t, []evm.Transaction{inbound, outbound}, matched, "Matched transactions do not match expected")
+
+		transactions, err := parser.GetTransactions(address)
+
+		assert.NoError(t, err, "No error expected")
+		assert.Equal(t, []evm.Transaction{inbound, outbound}, transactions, "Transactions do not match expected")
+
+		// History is dropped on unsubscribe
+		parser.UnSubscribe(address)
+		parser.Subscribe(address)
+		transactions, err = parser.GetTransactions(address)
+
+		assert.NoError(t, err, "No error expected")
+		assert.Empty(t, transactions, "Transactions should be empty after resubscribing")
+	})
+
+	t.Run("NewBasicEthereumParserWithStorage", func(t *testing.T) {
+		store := storage.NewMemoryStorage(0)
+		address := "0x7a250d5630B4cF539739dF2C5dAcb4c659F2488D"
+
+		parser, err := evmparser.NewBasicEthereumParserWithStorage(store, "wallet")
+		assert.NoError(t, err, "No error expected")
+
+		parser.Subscribe(address)
+		parser.ParseBlock(&evm.Block{Transactions: []evm.Transaction{{Hash: "0x1", To: address}}})
+
+		// A parser created on the same storage and set picks up where the first left off
+		restored, err := evmparser.NewBasicEthereumParserWithStorage(store, "wallet")
+		assert.NoError(t, err, "No error expected")
+		assert.True(t, restored.Subscriptions.Contains(util.MustParseAddress("0x7a250d5630b4cf539739df2c5dacb4c659f2488d")), "Subscription should be restored")
+
+		transactions, err := restored.GetTransactions(address)
+		assert.NoError(t, err, "No error expected")
+		assert.Len(t, transactions, 1, "Transaction history should be restored")
+	})
+
+	t.Run("Discard", func(t *testing.T) {
+		store := storage.NewMemoryStorage(0)
+		address := "0x7a250d5630B4cF539739dF2C5dAcb4c659F2488D"
+		block := &evm.Block{Transactions: []evm.Transaction{{Hash: "0x1", To: address}}}
+
+		first, _ := evmparser.NewBasicEthereumParserWithStorage(store, "session:a")
+		second, _ := evmparser.NewBasicEthereumParserWithStorage(store, "session:b")
+		first.Subscribe(address)
+		second.Subscribe(address)
+		first.ParseBlock(block)
+		second.ParseBlock(block)
+
+		first.UnSubscribe(address)
+		transactions, err := second.GetTransactions(address)
+		assert.NoError(t, err, "No error expected")
+		assert.Len(t, transactions, 1, "Parsers sharing a storage should keep their own history")
+
+		assert.NoError(t, second.Discard())
+		assert.False(t, second.Subscriptions.Contains(util.MustParseAddress(address)))
+
+		addresses, _ := store.GetSubscriptions("session:b")
+		assert.Empty(t, addresses, "Discarded subscriptions should be removed from storage")
+		transactions, _ = store.GetTransactions("session:b/" + strings.ToLower(address))
+		assert.Empty(t, transactions, "Discarded history should be removed from storage")
+	})
+
+	t.Run("RemoveBlocks", func(t *testing.T) {
+		parser := evmparser.NewBasicEthereumParser()
+		address := "0x7a250d5630B4cF539739dF2C5dAcb4c659F2488D"
+		other := "0x0000000000000000000000000000000000000001"
+
+		kept := evm.Transaction{Hash: "0x1", From: other, To: address, BlockNumber: "0x1"}
+		orphaned := evm.Transaction{Hash: "0x2", From: address, To: other, BlockNumber: "0x2"}
+		unrelated := evm.Transaction{Hash: "0x3", From: other, To: other, BlockNumber: "0x2"}
+		orphanBlock := &evm.Block{Number: "0x2", Hash: "0xbb", Transactions: []evm.Transaction{orphaned, unrelated}}
+
+		parser.Subscribe(address)
+		parser.ParseBlock(&evm.Block{Number: "0x1", Hash: "0xaa", Transactions: []evm.Transaction{kept}})
+		parser.ParseBlock(orphanBlock)
+
+		removed, err := parser.RemoveBlocks([]*evm.Block{orphanBlock})
+
+		assert.NoError(t, err, "No error expected")
+		assert.Equal(t, []evm.Transaction{orphaned}, removed, "Removed transactions do not match expected")
+
+		transactions, err := parser.GetTransactions(address)
+
+		assert.NoError(t, err, "No error expected")
+		assert.Equal(t, []evm.Transaction{kept}, transactions, "Only transactions from canonical blocks should remain")
+	})
+}
+
+func TestBasicEthereumParser_InternalTransactions(t *testing.T) {
+	parser := evmparser.NewBasicEthereumParser()
+	parser.Subscribe("0xdac17f958d2ee523a2206206994597c13d831ec7")
+
+	payout := evm.InternalTransaction{
+		TransactionHash: "0x1",
+		Type:            "CALL",
+		From:            "0x7a250d5630b4cf539739df2c5dacb4c659f2488d",
+		To:              "0xDAC17F958D2EE523A2206206994597C13D831EC7",
+		Value:           util.MustParseQuantity("0xde0b6b3a7640000"),
+		TraceAddress:    "0",
+	}
+	tx := evm.Transaction{
+		Hash:                 "0x1",
+		From:                 "0x1111111254eeb25477b68fb85ed929f73a960582",
+		To:                   "0x7a250d5630b4cf539739df2c5dacb4c659f2488d",
+		BlockNumber:          "0x10",
+		InternalTransactions: []evm.InternalTransaction{payout},
+	}
+	block := &evm.Block{Number: "0x10", Transactions: []evm.Transaction{tx}}
+
+	txs, err := parser.ParseBlock(block)
+
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, []evm.Transaction{tx}, txs, "Internal transfer recipient should match the transaction")
+	assert.Equal(t, []evm.InternalTransaction{payout}, parser.ParseInternalTransactions(block))
+}
+
+func TestBasicEthereumParser_ParseBlockActivity(t *testing.T) {
+	parser := evmparser.NewBasicEthereumParser()
+	parser.Subscribe(recipient)
+
+	tx := evm.Transaction{
+		Hash:        "0x1",
+		From:        sender,
+		To:          token,
+		BlockNumber: "0x10",
+		Receipt:     &evm.Receipt{Status: evm.ReceiptStatusSuccess, Logs: []evm.Log{transferLog(sender, recipient), erc721Log(sender, recipient)}},
+	}
+	block := &evm.Block{Number: "0x10", Transactions: []evm.Transaction{tx}}
+
+	activity, err := parser.ParseBlockActivity(context.Background(), block)
+
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, []evm.Transaction{tx}, activity.Transactions)
+	assert.Len(t, activity.TokenTransfers, 1, "The ERC-20 transfer should be reported")
+	assert.Len(t, activity.NFTTransfers, 1, "The ERC-721 transfer should be reported")
+	assert.Empty(t, activity.InternalTransactions)
+
+	parser.UnSubscribe(recipient)
+
+	activity, err = parser.ParseBlockActivity(context.Background(), block)
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, &evmparser.BlockActivity{}, activity, "Nothing should be reported once unsubscribed")
 }
@@ -0,0 +1,210 @@
+package ethereumparser
+
+import (
+	"errors"
+	evm "ethereum-parser/pkg/ethereum-rpc-client"
+	pubsub "ethereum-parser/pkg/pub-sub"
+	"ethereum-parser/util"
+	"sort"
+	"strconv"
+)
+
+const (
+	StatusPending   = "pending"
+	StatusConfirmed = "confirmed"
+	StatusFinalized = "finalized"
+)
+
+// Depths standing in for the safe and finalized blocks on nodes that do not
+// support those block tags, so that tag policies still resolve there and
+// transactions stop being tracked. They match one and two mainnet epochs.
+var (
+	DefaultSafeDepth      = 32
+	DefaultFinalizedDepth = 64
+)
+
+// DefaultConfirmations is the depth of subscriptions that do not set a
+// confirmation policy, so that their transactions are not confirmed as soon as
+// they are included.
+var DefaultConfirmations = 12
+
+var statusOrder = map[string]int{
+	StatusPending:   0,
+	StatusConfirmed: 1,
+	StatusFinalized: 2,
+}
+
+// ConfirmationPolicy decides when a transaction counts as confirmed for a
+// subscription: after Depth blocks including its own, or once its block is at
+// or below the safe/finalized block when Tag is set. Without those blocks,
+// DefaultSafeDepth and DefaultFinalizedDepth apply instead. The zero value
+// waits for DefaultConfirmations blocks.
+type ConfirmationPolicy struct {
+	Depth int    `json:"depth"`
+	Tag   string `json:"tag,omitempty"`
+}
+
+// ParseConfirmationPolicy accepts a block count or one of the "safe" and
+// "finalized" tags, as sent in the WebSocket Subscribe action. Without a value,
+// the policy waits for DefaultConfirmations blocks.
+func ParseConfirmationPolicy(value interface{}) (ConfirmationPolicy, error) {
+	switch v := value.(type) {
+	case nil:
+		return ConfirmationPolicy{Depth: DefaultConfirmations}, nil
+	case float64:
+		if v < 1 || v != float64(int(v)) {
+			return ConfirmationPolicy{}, errors.New("confirmations must be a positive integer")
+		}
+		return ConfirmationPolicy{Depth: int(v)}, nil
+	case int:
+		if v < 1 {
+			return ConfirmationPolicy{}, errors.New("confirmations must be a positive integer")
+		}
+		return ConfirmationPolicy{Depth: v}, nil
+	case string:
+		if v == evm.TagSafe || v == evm.TagFinalized {
+			return ConfirmationPolicy{Tag: v}, nil
+		}
+
+		depth, err := strconv.Atoi(v)
+		if err != nil || depth < 1 {
+			return ConfirmationPolicy{}, errors.New("invalid confirmations, " + v)
+		}
+		return ConfirmationPolicy{Depth: depth}, nil
+	default:
+		return ConfirmationPolicy{}, errors.New("invalid confirmations type")
+	}
+}
+
+// TransactionStatus is a status transition of a transaction delivered to a
+// subscribed address.
+type TransactionStatus struct {
+	Address       util.Address    `json:"address"`
+	Status        string          `json:"status"`
+	Confirmations int             `json:"confirmations"`
+	Transaction   evm.Transaction `json:"transaction"`
+}
+
+type trackedTransaction struct {
+	address     util.Address
+	blockNumber int
+	status      string
+	transaction evm.Transaction
+}
+
+// ConfirmationTracker follows the transactions delivered to subscribed
+// addresses until they are finalized. It is not safe for concurrent use.
+type ConfirmationTracker struct {
+	tracked map[string]*trackedTransaction
+}
+
+func NewConfirmationTracker() *ConfirmationTracker {
+	return &ConfirmationTracker{
+		tracked: make(map[string]*trackedTransaction),
+	}
+}
+
+// Track follows tx of block, delivered to address, as pending.
+func (t *ConfirmationTracker) Track(address util.Address, block *evm.Block, tx evm.Transaction) {
+	blockNumber, err := util.HexToDecimal(tx.BlockNumber)
+	if err != nil {
+		blockNumber, _ = util.HexToDecimal(block.Number)
+	}
+
+	t.tracked[trackingKey(address, tx)] = &trackedTransaction{
+		address:     address,
+		blockNumber: int(blockNumber),
+		status:      StatusPending,
+		transaction: tx,
+	}
+}
+
+// Untrack stops following tx for address, as when its block was reorganized
+// away.
+func (t *ConfirmationTracker) Untrack(address util.Address, tx evm.Transaction) {
+	delete(t.tracked, trackingKey(address, tx))
+}
+
+// UntrackAddress stops following every transaction of address.
+func (t *ConfirmationTracker) UntrackAddress(address util.Address) {
+	for key, tracked := range t.tracked {
+		if tracked.address == address {
+			delete(t.tracked, key)
+		}
+	}
+}
+
+// Update re-evaluates the confirmations of every tracked transaction against
+// head, with the policy policyOf returns for its address, and returns the ones
+// whose status changed, oldest first. Finalized transactions are no longer
+// tracked.
+func (t *ConfirmationTracker) Update(head *pubsub.HeadEvent, policyOf func(util.Address) ConfirmationPolicy) []TransactionStatus {
+	var statuses []TransactionStatus
+
+	for key, tracked := range t.tracked {
+		status := policyOf(tracked.address).status(tracked.blockNumber, head)
+		if statusOrder[status] <= statusOrder[tracked.status] {
+			continue
+		}
+
+		tracked.status = status
+		statuses = append(statuses, TransactionStatus{
+			Address:       tracked.address,
+			Status:        status,
+			Confirmations: head.Latest - tracked.blockNumber + 1,
+			Transaction:   tracked.transaction,
+		})
+
+		if status == StatusFinalized {
+			delete(t.tracked, key)
+		}
+	}
+
+	// Oldest transactions first, so clients see transitions in chain order
+	sort.Slice(statuses, func(i, j int) bool {
+		if statuses[i].Confirmations != statuses[j].Confirmations {
+			return statuses[i].Confirmations > statuses[j].Confirmations
+		}
+		if statuses[i].Transaction.Hash != statuses[j].Transaction.Hash {
+			return statuses[i].Transaction.Hash < statuses[j].Transaction.Hash
+		}
+		return statuses[i].Address.Lower() < statuses[j].Address.Lower()
+	})
+
+	return statuses
+}
+
+func trackingKey(address util.Address, tx evm.Transaction) string {
+	return address.Lower() + ":" + tx.Hash
+}
+
+func (p ConfirmationPolicy) status(blockNumber int, head *pubsub.HeadEvent) string {
+	confirmations := head.Latest - blockNumber + 1
+
+	if head.Finalized > 0 && blockNumber <= head.Finalized {
+		return StatusFinalized
+	}
+	if head.Finalized == 0 && confirmations >= DefaultFinalizedDepth {
+		return StatusFinalized
+	}
+
+	switch p.Tag {
+	case evm.TagSafe:
+		if (head.Safe > 0 && blockNumber <= head.Safe) || (head.Safe == 0 && confirmations >= DefaultSafeDepth) {
+			return StatusConfirmed
+		}
+		return StatusPending
+	case evm.TagFinalized:
+		return StatusPending
+	}
+
+	depth := p.Depth
+	if depth <= 0 {
+		depth = DefaultConfirmations
+	}
+	if confirmations >= depth {
+		return StatusConfirmed
+	}
+
+	return StatusPending
+}
@@ -0,0 +1,145 @@
+package ethereumparser_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	evmparser "ethereum-parser/pkg/ethereum-parser"
+	evm "ethereum-parser/pkg/ethereum-rpc-client"
+	pubsub "ethereum-parser/pkg/pub-sub"
+	"ethereum-parser/util"
+)
+
+func TestParseConfirmationPolicy(t *testing.T) {
+	cases := []struct {
+		name        string
+		value       interface{}
+		expected    evmparser.ConfirmationPolicy
+		expectedErr string
+	}{
+		{name: "Missing", value: nil, expected: evmparser.ConfirmationPolicy{Depth: evmparser.DefaultConfirmations}},
+		{name: "JSON number", value: float64(12), expected: evmparser.ConfirmationPolicy{Depth: 12}},
+		{name: "Numeric string", value: "6", expected: evmparser.ConfirmationPolicy{Depth: 6}},
+		{name: "Safe tag", value: "safe", expected: evmparser.ConfirmationPolicy{Tag: "safe"}},
+		{name: "Finalized tag", value: "finalized", expected: evmparser.ConfirmationPolicy{Tag: "finalized"}},
+		{name: "Zero", value: float64(0), expectedErr: "confirmations must be a positive integer"},
+		{name: "Negative", value: float64(-1), expectedErr: "confirmations must be a positive integer"},
+		{name: "Fraction", value: 1.5, expectedErr: "confirmations must be a positive integer"},
+		{name: "Zero string", value: "0", expectedErr: "invalid confirmations, 0"},
+		{name: "Unknown tag", value: "latest", expectedErr: "invalid confirmations, latest"},
+		{name: "Wrong type", value: true, expectedErr: "invalid confirmations type"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			policy, err := evmparser.ParseConfirmationPolicy(c.value)
+
+			if c.expectedErr != "" {
+				assert.EqualError(t, err, c.expectedErr)
+			} else {
+				assert.NoError(t, err, "Unexpected error")
+				assert.Equal(t, c.expected, policy, "Policy does not match expected")
+			}
+		})
+	}
+}
+
+func TestBasicEthereumParser_UpdateHead(t *testing.T) {
+	depthAddress := "0x0000000000000000000000000000000000000001"
+	safeAddress := "0x0000000000000000000000000000000000000002"
+	other := "0x0000000000000000000000000000000000000003"
+
+	parser := evmparser.NewBasicEthereumParser()
+	parser.SubscribeWithConfirmations(depthAddress, evmparser.ConfirmationPolicy{Depth: 3})
+	parser.SubscribeWithConfirmations(safeAddress, evmparser.ConfirmationPolicy{Tag: "safe"})
+
+	toDepth := evm.Transaction{Hash: "0xa", From: other, To: depthAddress, BlockNumber: "0xa"}
+	toSafe := evm.Transaction{Hash: "0xb", From: other, To: safeAddress, BlockNumber: "0xa"}
+	parser.ParseBlock(&evm.Block{Number: "0xa", Transactions: []evm.Transaction{toDepth, toSafe}})
+
+	// Included but not deep enough for either policy
+	statuses := parser.UpdateHead(&pubsub.HeadEvent{Latest: 11, Safe: 5, Finalized: 1})
+	assert.Empty(t, statuses, "No transitions expected")
+
+	// Third block on top confirms the depth policy only
+	statuses = parser.UpdateHead(&pubsub.HeadEvent{Latest: 12, Safe: 5, Finalized: 1})
+	assert.Equal(t, []evmparser.TransactionStatus{
+		{Address: util.MustParseAddress(depthAddress), Status: "confirmed", Confirmations: 3, Transaction: toDepth},
+	}, statuses)
+
+	// The same head does not repeat a transition
+	statuses = parser.UpdateHead(&pubsub.HeadEvent{Latest: 12, Safe: 5, Finalized: 1})
+	assert.Empty(t, statuses, "Transitions should not repeat")
+
+	// The safe block passing the transaction confirms the safe policy
+	statuses = parser.UpdateHead(&pubsub.HeadEvent{Latest: 20, Safe: 10, Finalized: 1})
+	assert.Equal(t, []evmparser.TransactionStatus{
+		{Address: util.MustParseAddress(safeAddress), Status: "confirmed", Confirmations: 11, Transaction: toSafe},
+	}, statuses)
+
+	// Finalization applies to every policy and ends tracking
+	statuses = parser.UpdateHead(&pubsub.HeadEvent{Latest: 80, Safe: 70, Finalized: 60})
+	assert.Len(t, statuses, 2, "Both transactions should be finalized")
+	for _, status := range statuses {
+		assert.Equal(t, "finalized", status.Status)
+	}
+
+	statuses = parser.UpdateHead(&pubsub.HeadEvent{Latest: 81, Safe: 71, Finalized: 61})
+	assert.Empty(t, statuses, "Finalized transactions should no longer be tracked")
+}
+
+func TestBasicEthereumParser_UpdateHeadDefaultPolicy(t *testing.T) {
+	address := "0x0000000000000000000000000000000000000001"
+	other := "0x0000000000000000000000000000000000000002"
+
+	parser := evmparser.NewBasicEthereumParser()
+	parser.Subscribe(address)
+
+	tx := evm.Transaction{Hash: "0xa", From: other, To: address, BlockNumber: "0xa"}
+	parser.ParseBlock(&evm.Block{Number: "0xa", Transactions: []evm.Transaction{tx}})
+
+	// Subscriptions without a policy are not confirmed on inclusion
+	statuses := parser.UpdateHead(&pubsub.HeadEvent{Latest: 10, Safe: 1, Finalized: 1})
+	assert.Empty(t, statuses, "No transitions expected")
+
+	statuses = parser.UpdateHead(&pubsub.HeadEvent{Latest: 10 + evmparser.DefaultConfirmations - 1, Safe: 1, Finalized: 1})
+	assert.Equal(t, []evmparser.TransactionStatus{
+		{Address: util.MustParseAddress(address), Status: "confirmed", Confirmations: evmparser.DefaultConfirmations, Transaction: tx},
+	}, statuses, "Default policy should wait for DefaultConfirmations")
+}
+
+func TestBasicEthereumParser_UpdateHeadWithoutTags(t *testing.T) {
+	safeAddress := "0x0000000000000000000000000000000000000001"
+	finalizedAddress := "0x0000000000000000000000000000000000000002"
+	other := "0x0000000000000000000000000000000000000003"
+
+	parser := evmparser.NewBasicEthereumParser()
+	parser.SubscribeWithConfirmations(safeAddress, evmparser.ConfirmationPolicy{Tag: "safe"})
+	parser.SubscribeWithConfirmations(finalizedAddress, evmparser.ConfirmationPolicy{Tag: "finalized"})
+
+	toSafe := evm.Transaction{Hash: "0xa", From: other, To: safeAddress, BlockNumber: "0xa"}
+	toFinalized := evm.Transaction{Hash: "0xb", From: other, To: finalizedAddress, BlockNumber: "0xa"}
+	parser.ParseBlock(&evm.Block{Number: "0xa", Transactions: []evm.Transaction{toSafe, toFinalized}})
+
+	// Nodes without the block tags report 0 for them
+	statuses := parser.UpdateHead(&pubsub.HeadEvent{Latest: 10 + evmparser.DefaultSafeDepth - 2})
+	assert.Empty(t, statuses, "No transitions expected")
+
+	statuses = parser.UpdateHead(&pubsub.HeadEvent{Latest: 10 + evmparser.DefaultSafeDepth - 1})
+	assert.Equal(t, []evmparser.TransactionStatus{
+		{Address: util.MustParseAddress(safeAddress), Status: "confirmed", Confirmations: evmparser.DefaultSafeDepth, Transaction: toSafe},
+	}, statuses, "Safe policy should fall back to DefaultSafeDepth")
+
+	statuses = parser.UpdateHead(&pubsub.HeadEvent{Latest: 10 + evmparser.DefaultFinalizedDepth - 2})
+	assert.Empty(t, statuses, "No transitions expected")
+
+	statuses = parser.UpdateHead(&pubsub.HeadEvent{Latest: 10 + evmparser.DefaultFinalizedDepth - 1})
+	assert.Len(t, statuses, 2, "Finalized policy should fall back to DefaultFinalizedDepth")
+	for _, status := range statuses {
+		assert.Equal(t, "finalized", status.Status)
+	}
+
+	statuses = parser.UpdateHead(&pubsub.HeadEvent{Latest: 10 + evmparser.DefaultFinalizedDepth})
+	assert.Empty(t, statuses, "Finalized transactions should no longer be tracked")
+}
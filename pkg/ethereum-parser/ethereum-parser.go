@@ -5,8 +5,8 @@ import (
 )
 
 type EthereumParser interface {
-	GetCurrentBlock() (int, error)
+	GetCurrentBlock() (*evm.Block, error)
 	Subscribe(address string) (bool, error)
 	UnSubscribe(address string) (bool, error)
-	GetTransactions() ([]evm.Transaction, error)
+	GetTransactions(address string) ([]evm.Transaction, error)
 }
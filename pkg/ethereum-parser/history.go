@@ -0,0 +1,327 @@
+package ethereumparser
+
+import (
+	"encoding/base64"
+	"errors"
+	evm "ethereum-parser/pkg/ethereum-rpc-client"
+	"ethereum-parser/pkg/storage"
+	"ethereum-parser/util"
+	"fmt"
+	"math"
+)
+
+const (
+	DirectionIn  = "in"
+	DirectionOut = "out"
+)
+
+const (
+	AssetEther    = "ether"
+	AssetERC20    = "erc20"
+	AssetERC721   = "erc721"
+	AssetERC1155  = "erc1155"
+	AssetInternal = "internal"
+)
+
+const (
+	DefaultHistoryLimit = 50
+	MaxHistoryLimit     = 500
+
+	// historyBatchSize is the number of blocks read from storage at a time
+	historyBatchSize = 100
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// HistoryQuery selects the transactions of Address among the stored blocks.
+type HistoryQuery struct {
+	Address    util.Address
+	FromBlock  int            // First block, included
+	ToBlock    int            // Last block, included, or -1 for the latest one
+	Direction  string         // DirectionIn, DirectionOut or empty for both
+	Asset      string         // Asset moved by the transaction, empty for any
+	MinValue   *util.Quantity // Bounds of the ether value in wei, or of the amount of Asset
+	MaxValue   *util.Quantity
+	Descending bool
+	Limit      int    // Defaults to DefaultHistoryLimit
+	Cursor     string // NextCursor of the previous page
+}
+
+// HistoryPage is a page of transactions. NextCursor points after the last one,
+// so a query repeated with it returns the following page. Once an ascending
+// query has read every stored block, NextCursor points after the latest one
+// and polling with it returns only the transactions of new blocks.
+type HistoryPage struct {
+	Transactions []evm.Transaction `json:"transactions"`
+	NextCursor   string            `json:"nextCursor"`
+	HasMore      bool              `json:"hasMore"`
+}
+
+// historyPosition locates a transaction by block number and index within the
+// block, which unlike hashes orders them.
+type historyPosition struct {
+	block int
+	index int
+}
+
+// QueryHistory returns a page of the transactions of query.Address found in
+// the blocks kept by store. Transactions are looked up through the positions
+// indexed for the address, the block range only bounds them.
+func QueryHistory(store storage.Storage, query HistoryQuery) (*HistoryPage, error) {
+	if err := query.Validate(); err != nil {
+		return nil, err
+	}
+
+	limit := query.Limit
+	if limit <= 0 {
+		limit = DefaultHistoryLimit
+	}
+	limit = min(limit, MaxHistoryLimit)
+
+	page := &HistoryPage{Transactions: []evm.Transaction{}, NextCursor: query.Cursor}
+
+	from, to := max(query.FromBlock, 0), query.ToBlock
+	if to < 0 {
+		latest, err := store.GetLatestBlock()
+		if errors.Is(err, storage.ErrNoBlocks) {
+			return page, nil
+		}
+		if err != nil {
+			return nil, errors.New("error getting latest block, " + err.Error())
+		}
+
+		if to, err = util.ParseBlockNumber(latest.Number); err != nil {
+			return nil, err
+		}
+	}
+
+	first := historyPosition{block: from}
+	last := historyPosition{block: to, index: math.MaxInt}
+
+	var after *historyPosition
+	if query.Cursor != "" {
+		position, err := decodeCursor(query.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		after = &position
+
+		if next := position.next(query.Descending); query.Descending && next.follows(last, true) {
+			last = next
+		} else if !query.Descending && next.follows(first, false) {
+			first = next
+		}
+	}
+
+	var block *evm.Block
+	blockNumber := -1
+	for {
+		positions, err := store.GetTransactionPositions(query.Address.Lower(), first.stored(), last.stored(), historyBatchSize, query.Descending)
+		if err != nil {
+			return nil, errors.New("error getting transaction positions, " + err.Error())
+		}
+
+		for _, stored := range positions {
+			position := historyPosition{block: stored.Block, index: stored.Index}
+
+			if position.block != blockNumber {
+				// Blocks may be dropped by retention while the query runs
+				block, err = store.GetBlockByNumber(position.block)
+				if err != nil && !errors.Is(err, storage.ErrBlockNotFound) {
+					return nil, errors.New("error getting block, " + err.Error())
+				}
+				blockNumber = position.block
+			}
+			if block == nil || position.index >= len(block.Transactions) || !query.matches(block.Transactions[position.index]) {
+				continue
+			}
+
+			if len(page.Transactions) == limit {
+				page.HasMore = true
+				return page, nil
+			}
+			page.Transactions = append(page.Transactions, block.Transactions[position.index])
+			page.NextCursor = position.encode()
+		}
+
+		if len(positions) < historyBatchSize {
+			break
+		}
+
+		next := historyPosition{block: positions[len(positions)-1].Block, index: positions[len(positions)-1].Index}.next(query.Descending)
+		if query.Descending {
+			last = next
+		} else {
+			first = next
+		}
+	}
+
+	if !query.Descending {
+		end := historyPosition{block: to + 1, index: -1}
+		if after == nil || end.follows(*after, false) {
+			page.NextCursor = end.encode()
+		}
+	}
+
+	return page, nil
+}
+
+// Validate checks the filters of q, which QueryHistory does before reading
+// storage.
+func (q HistoryQuery) Validate() error {
+	switch q.Direction {
+	case "", DirectionIn, DirectionOut:
+	default:
+		return errors.New("invalid direction, " + q.Direction)
+	}
+
+	switch q.Asset {
+	case "", AssetEther, AssetERC20, AssetERC721, AssetERC1155, AssetInternal:
+	default:
+		return errors.New("invalid asset, " + q.Asset)
+	}
+
+	if q.ToBlock >= 0 && q.FromBlock > q.ToBlock {
+		return errors.New("invalid block range")
+	}
+
+	if q.MinValue != nil && q.MaxValue != nil && q.MinValue.Cmp(*q.MaxValue) > 0 {
+		return errors.New("invalid value range")
+	}
+
+	return nil
+}
+
+func (q HistoryQuery) matches(tx evm.Transaction) bool {
+	// Without an asset the value bounds apply to the ether value of the
+	// transaction, otherwise to the amounts of the asset moved
+	inRange := func(util.Quantity) bool { return true }
+	if q.Asset == "" || q.Asset == AssetEther {
+		if !q.inValueRange(tx.Value) {
+			return false
+		}
+	} else {
+		inRange = q.inValueRange
+	}
+
+	received, sent := historyFlows(tx, q.Address, q.Asset, inRange)
+	switch q.Direction {
+	case DirectionIn:
+		return received
+	case DirectionOut:
+		return sent
+	default:
+		return received || sent
+	}
+}
+
+func (q HistoryQuery) inValueRange(value util.Quantity) bool {
+	return (q.MinValue == nil || value.Cmp(*q.MinValue) >= 0) && (q.MaxValue == nil || value.Cmp(*q.MaxValue) <= 0)
+}
+
+// historyFlows reports whether address received or sent asset in tx, or took
+// part in it in any way when asset is empty. Only movements whose amount is
+// inRange count.
+func historyFlows(tx evm.Transaction, address util.Address, asset string, inRange func(util.Quantity) bool) (received bool, sent bool) {
+	flow := func(from, to util.Address, amount util.Quantity) {
+		if inRange(amount) {
+			sent = sent || from == address
+			received = received || to == address
+		}
+	}
+	flowHex := func(from, to string, amount util.Quantity) {
+		if !inRange(amount) {
+			return
+		}
+		if parsed, err := util.ParseAddress(from); err == nil && parsed == address {
+			sent = true
+		}
+		if parsed, err := util.ParseAddress(to); err == nil && parsed == address {
+			received = true
+		}
+	}
+	flowAmount := func(from, to util.Address, amount string) {
+		parsed, _ := util.ParseQuantity(amount)
+		flow(from, to, parsed)
+	}
+
+	// A plain call moves no ether but still involves its parties
+	if asset == "" || (asset == AssetEther && !tx.Value.IsZero()) {
+		flowHex(tx.From, tx.To, tx.Value)
+	}
+
+	if asset == "" || asset == AssetERC20 {
+		for _, transfer := range tokenTransfers(tx) {
+			flowAmount(transfer.From, transfer.To, transfer.Amount)
+		}
+	}
+
+	if asset == "" || asset == AssetERC721 || asset == AssetERC1155 {
+		for _, transfer := range DecodeNFTTransfers(tx) {
+			if asset == "" || asset == nftAsset(transfer.Standard) {
+				flowAmount(transfer.From, transfer.To, transfer.Amount)
+			}
+		}
+	}
+
+	if asset == "" || asset == AssetInternal {
+		for _, internalTx := range tx.InternalTransactions {
+			flowHex(internalTx.From, internalTx.To, internalTx.Value)
+		}
+	}
+
+	return received, sent
+}
+
+func nftAsset(standard string) string {
+	if standard == StandardERC1155 {
+		return AssetERC1155
+	}
+
+	return AssetERC721
+}
+
+// next returns the first position after p in the order of the query.
+func (p historyPosition) next(descending bool) historyPosition {
+	switch {
+	case !descending:
+		return historyPosition{block: p.block, index: p.index + 1}
+	case p.index > 0:
+		return historyPosition{block: p.block, index: p.index - 1}
+	default:
+		return historyPosition{block: p.block - 1, index: math.MaxInt}
+	}
+}
+
+func (p historyPosition) stored() storage.TransactionPosition {
+	return storage.TransactionPosition{Block: p.block, Index: p.index}
+}
+
+// follows reports whether p comes after other in the order of the query.
+func (p historyPosition) follows(other historyPosition, descending bool) bool {
+	if p.block != other.block {
+		return (p.block > other.block) != descending
+	}
+
+	return p.index != other.index && (p.index > other.index) != descending
+}
+
+func (p historyPosition) encode() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d:%d", p.block, p.index)))
+}
+
+func decodeCursor(cursor string) (historyPosition, error) {
+	var position historyPosition
+
+	decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return position, ErrInvalidCursor
+	}
+
+	var rest string
+	if n, _ := fmt.Sscanf(string(decoded), "%d:%d%s", &position.block, &position.index, &rest); n != 2 || position.block < 0 {
+		return position, ErrInvalidCursor
+	}
+
+	return position, nil
+}
@@ -0,0 +1,214 @@
+package ethereumparser_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	evmparser "ethereum-parser/pkg/ethereum-parser"
+	evm "ethereum-parser/pkg/ethereum-rpc-client"
+	pubsub "ethereum-parser/pkg/pub-sub"
+	"ethereum-parser/pkg/storage"
+	"ethereum-parser/util"
+)
+
+const stranger = "0x1111111111111111111111111111111111111111"
+
+// historyPublisher stores the blocks of the history tests, indexed as the
+// listener does.
+func historyPublisher() *pubsub.BlockPublisher {
+	publisher := pubsub.NewBlockPublisher(storage.NewMemoryStorage(0))
+	publisher.AddressesOf = evmparser.InvolvedAddresses
+
+	publisher.AddBlock(&evm.Block{Number: "0x1", Transactions: []evm.Transaction{
+		{Hash: "0xa1", From: sender, To: recipient, Value: util.MustParseQuantity("0x10")},
+		{Hash: "0xa2", From: stranger, To: token},
+	}})
+	publisher.AddBlock(&evm.Block{Number: "0x2", Transactions: []evm.Transaction{
+		{Hash: "0xb1", From: recipient, To: sender, Value: util.MustParseQuantity("0x1")},
+		{Hash: "0xb2", From: stranger, To: token, Receipt: &evm.Receipt{Logs: []evm.Log{transferLog(stranger, sender)}}},
+	}})
+	// Block 3 was never publisherd
+	publisher.AddBlock(&evm.Block{Number: "0x4", Transactions: []evm.Transaction{
+		{Hash: "0xc1", From: sender, To: nft, Receipt: &evm.Receipt{Logs: []evm.Log{erc721Log(sender, stranger)}}},
+		{Hash: "0xc2", From: stranger, To: stranger, InternalTransactions: []evm.InternalTransaction{
+			{From: stranger, To: sender, Value: util.MustParseQuantity("0x5")},
+		}},
+	}})
+
+	return publisher
+}
+
+func hashes(page *evmparser.HistoryPage) []string {
+	result := []string{}
+	for _, tx := range page.Transactions {
+		result = append(result, tx.Hash)
+	}
+	return result
+}
+
+func TestQueryHistory(t *testing.T) {
+	store := historyPublisher().Storage()
+	minValue := util.MustParseQuantity("0x2")
+	maxValue := util.MustParseQuantity("0x1")
+	tokenAmount := util.MustParseQuantity("1000000")
+
+	tests := []struct {
+		name     string
+		query    evmparser.HistoryQuery
+		expected []string
+	}{
+		{"Newest first", evmparser.HistoryQuery{ToBlock: -1, Descending: true}, []string{"0xc2", "0xc1", "0xb2", "0xb1", "0xa1"}},
+		{"Oldest first", evmparser.HistoryQuery{ToBlock: -1}, []string{"0xa1", "0xb1", "0xb2", "0xc1", "0xc2"}},
+		{"Block range", evmparser.HistoryQuery{FromBlock: 2, ToBlock: 3}, []string{"0xb1", "0xb2"}},
+		{"Inbound", evmparser.HistoryQuery{ToBlock: -1, Direction: evmparser.DirectionIn}, []string{"0xb1", "0xb2", "0xc2"}},
+		{"Outbound", evmparser.HistoryQuery{ToBlock: -1, Direction: evmparser.DirectionOut}, []string{"0xa1", "0xc1"}},
+		{"Ether", evmparser.HistoryQuery{ToBlock: -1, Asset: evmparser.AssetEther}, []string{"0xa1", "0xb1"}},
+		{"ERC-20", evmparser.HistoryQuery{ToBlock: -1, Asset: evmparser.AssetERC20}, []string{"0xb2"}},
+		{"ERC-721", evmparser.HistoryQuery{ToBlock: -1, Asset: evmparser.AssetERC721}, []string{"0xc1"}},
+		{"ERC-1155", evmparser.HistoryQuery{ToBlock: -1, Asset: evmparser.AssetERC1155}, []string{}},
+		{"Internal", evmparser.HistoryQuery{ToBlock: -1, Asset: evmparser.AssetInternal}, []string{"0xc2"}},
+		{"Inbound ERC-721", evmparser.HistoryQuery{ToBlock: -1, Asset: evmparser.AssetERC721, Direction: evmparser.DirectionIn}, []string{}},
+		{"Minimum value", evmparser.HistoryQuery{ToBlock: -1, MinValue: &minValue}, []string{"0xa1"}},
+		{"Maximum value", evmparser.HistoryQuery{ToBlock: -1, MaxValue: &maxValue}, []string{"0xb1", "0xb2", "0xc1", "0xc2"}},
+		{"Minimum ERC-20 amount", evmparser.HistoryQuery{ToBlock: -1, Asset: evmparser.AssetERC20, MinValue: &tokenAmount}, []string{"0xb2"}},
+		{"Maximum ERC-20 amount", evmparser.HistoryQuery{ToBlock: -1, Asset: evmparser.AssetERC20, MaxValue: &maxValue}, []string{}},
+		{"Minimum internal value", evmparser.HistoryQuery{ToBlock: -1, Asset: evmparser.AssetInternal, MinValue: &minValue}, []string{"0xc2"}},
+		{"Maximum internal value", evmparser.HistoryQuery{ToBlock: -1, Asset: evmparser.AssetInternal, MaxValue: &maxValue}, []string{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.query.Address = util.MustParseAddress(sender)
+
+			page, err := evmparser.QueryHistory(store, test.query)
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, hashes(page))
+			assert.False(t, page.HasMore)
+		})
+	}
+}
+
+func TestQueryHistory_Pagination(t *testing.T) {
+	publisher := historyPublisher()
+	store := publisher.Storage()
+	query := evmparser.HistoryQuery{Address: util.MustParseAddress(sender), ToBlock: -1, Limit: 2}
+
+	var pages [][]string
+	for {
+		page, err := evmparser.QueryHistory(store, query)
+		assert.NoError(t, err)
+		pages = append(pages, hashes(page))
+
+		query.Cursor = page.NextCursor
+		if !page.HasMore {
+			break
+		}
+	}
+	assert.Equal(t, [][]string{{"0xa1", "0xb1"}, {"0xb2", "0xc1"}, {"0xc2"}}, pages)
+
+	// The last cursor resumes after the latest block
+	page, err := evmparser.QueryHistory(store, query)
+	assert.NoError(t, err)
+	assert.Empty(t, page.Transactions)
+	assert.Equal(t, query.Cursor, page.NextCursor, "An empty page should keep the cursor")
+
+	publisher.AddBlock(&evm.Block{Number: "0x5", Transactions: []evm.Transaction{
+		{Hash: "0xd1", From: sender, To: recipient},
+	}})
+	page, err = evmparser.QueryHistory(store, query)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"0xd1"}, hashes(page), "Polling should return only new transactions")
+
+	// Descending pages walk back from the latest block
+	query = evmparser.HistoryQuery{Address: util.MustParseAddress(sender), ToBlock: -1, Limit: 3, Descending: true}
+	page, err = evmparser.QueryHistory(store, query)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"0xd1", "0xc2", "0xc1"}, hashes(page))
+	assert.True(t, page.HasMore)
+
+	query.Cursor = page.NextCursor
+	page, err = evmparser.QueryHistory(store, query)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"0xb2", "0xb1", "0xa1"}, hashes(page))
+	assert.False(t, page.HasMore)
+}
+
+// countingStorage counts the blocks read from the storage it wraps.
+type countingStorage struct {
+	storage.Storage
+	reads int
+}
+
+func (s *countingStorage) GetBlockByNumber(number int) (*evm.Block, error) {
+	s.reads++
+	return s.Storage.GetBlockByNumber(number)
+}
+
+func (s *countingStorage) GetBlocks(from, to, limit int, descending bool) ([]evm.Block, error) {
+	blocks, err := s.Storage.GetBlocks(from, to, limit, descending)
+	s.reads += len(blocks)
+	return blocks, err
+}
+
+func TestQueryHistory_Index(t *testing.T) {
+	store := &countingStorage{Storage: storage.NewMemoryStorage(0)}
+	publisher := pubsub.NewBlockPublisher(store)
+	publisher.AddressesOf = evmparser.InvolvedAddresses
+
+	// A sparse address among many blocks, and a busy block
+	busy := &evm.Block{Number: "0x1"}
+	for i := 0; i < 250; i++ {
+		busy.Transactions = append(busy.Transactions, evm.Transaction{Hash: fmt.Sprintf("0xa%d", i), From: stranger, To: recipient})
+	}
+	publisher.AddBlock(busy)
+	for number := 2; number <= 1000; number++ {
+		block := &evm.Block{Number: fmt.Sprintf("0x%x", number), Transactions: []evm.Transaction{{Hash: fmt.Sprintf("0xb%d", number), From: stranger, To: recipient}}}
+		if number == 500 || number == 900 {
+			block.Transactions = append(block.Transactions, evm.Transaction{Hash: fmt.Sprintf("0xc%d", number), From: sender, To: recipient})
+		}
+		publisher.AddBlock(block)
+	}
+
+	store.reads = 0
+	page, err := evmparser.QueryHistory(store, evmparser.HistoryQuery{Address: util.MustParseAddress(sender), ToBlock: -1, Descending: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"0xc900", "0xc500"}, hashes(page))
+	assert.Equal(t, 2, store.reads, "Only the blocks of the address should be read")
+
+	// Positions of a single block span several batches
+	query := evmparser.HistoryQuery{Address: util.MustParseAddress(stranger), ToBlock: 1, Limit: 200}
+	page, err = evmparser.QueryHistory(store, query)
+	assert.NoError(t, err)
+	assert.Len(t, page.Transactions, 200)
+	assert.Equal(t, "0xa199", page.Transactions[199].Hash)
+
+	query.Cursor = page.NextCursor
+	page, err = evmparser.QueryHistory(store, query)
+	assert.NoError(t, err)
+	assert.Len(t, page.Transactions, 50)
+	assert.Equal(t, "0xa200", page.Transactions[0].Hash)
+}
+
+func TestQueryHistory_Invalid(t *testing.T) {
+	store := historyPublisher().Storage()
+	address := util.MustParseAddress(sender)
+
+	for _, query := range []evmparser.HistoryQuery{
+		{Address: address, ToBlock: -1, Direction: "sideways"},
+		{Address: address, ToBlock: -1, Asset: "gold"},
+		{Address: address, FromBlock: 5, ToBlock: 2},
+		{Address: address, ToBlock: -1, Cursor: "not a cursor"},
+	} {
+		_, err := evmparser.QueryHistory(store, query)
+		assert.Error(t, err, "Expected error for query: %+v", query)
+	}
+
+	_, err := evmparser.QueryHistory(store, evmparser.HistoryQuery{Address: address, ToBlock: -1, Cursor: "bm9wZQ"})
+	assert.ErrorIs(t, err, evmparser.ErrInvalidCursor)
+
+	page, err := evmparser.QueryHistory(storage.NewMemoryStorage(0), evmparser.HistoryQuery{Address: address, ToBlock: -1})
+	assert.NoError(t, err)
+	assert.Empty(t, page.Transactions, "No stored blocks should give an empty page")
+}
@@ -0,0 +1,265 @@
+package ethereumparser
+
+import (
+	"errors"
+	evm "ethereum-parser/pkg/ethereum-rpc-client"
+	"ethereum-parser/util"
+	"math/big"
+	"strings"
+)
+
+const (
+	// TransferSingleEventTopic is
+	// keccak256("TransferSingle(address,address,address,uint256,uint256)").
+	TransferSingleEventTopic = "0xc3d58168c5ae7397731d063d5bbf3d657854427343f4c083240f7aacaa2d0f62"
+	// TransferBatchEventTopic is
+	// keccak256("TransferBatch(address,address,address,uint256[],uint256[])").
+	TransferBatchEventTopic = "0x4a39dc06d4c0dbc64b70af90fd698a233a518aa5d07e595d983b8c0526c8f7fb"
+)
+
+const (
+	StandardERC721  = "ERC-721"
+	StandardERC1155 = "ERC-1155"
+)
+
+// NFTTransfer is the move of Amount units of token TokenID of an ERC-721 or
+// ERC-1155 contract. ERC-1155 batch transfers are split into one NFTTransfer
+// per token id, sharing the same LogIndex.
+type NFTTransfer struct {
+	Standard        string        `json:"standard"`
+	Contract        util.Address  `json:"contract"`
+	Operator        *util.Address `json:"operator,omitempty"`
+	From            util.Address  `json:"from"`
+	To              util.Address  `json:"to"`
+	TokenID         string        `json:"tokenId"`
+	Amount          string        `json:"amount"`
+	TransactionHash string        `json:"transactionHash"`
+	BlockNumber     string        `json:"blockNumber"`
+	LogIndex        string        `json:"logIndex"`
+}
+
+// DecodeERC721Transfer decodes log as an ERC-721 Transfer event, which unlike
+// the ERC-20 one indexes the token id.
+func DecodeERC721Transfer(log evm.Log) (*NFTTransfer, bool) {
+	if log.Removed || len(log.Topics) != 4 || !strings.EqualFold(log.Topics[0], TransferEventTopic) {
+		return nil, false
+	}
+
+	contract, err := util.ParseAddress(log.Address)
+	if err != nil {
+		return nil, false
+	}
+
+	from, ok := topicAddress(log.Topics[1])
+	if !ok {
+		return nil, false
+	}
+
+	to, ok := topicAddress(log.Topics[2])
+	if !ok {
+		return nil, false
+	}
+
+	tokenID, ok := new(big.Int).SetString(strings.TrimPrefix(log.Topics[3], "0x"), 16)
+	if !ok {
+		return nil, false
+	}
+
+	return &NFTTransfer{
+		Standard:        StandardERC721,
+		Contract:        contract,
+		From:            from,
+		To:              to,
+		TokenID:         tokenID.String(),
+		Amount:          "1",
+		TransactionHash: log.TransactionHash,
+		BlockNumber:     log.BlockNumber,
+		LogIndex:        log.LogIndex,
+	}, true
+}
+
+// DecodeERC1155Transfers decodes log as an ERC-1155 TransferSingle or
+// TransferBatch event.
+func DecodeERC1155Transfers(log evm.Log) ([]NFTTransfer, bool) {
+	if log.Removed || len(log.Topics) != 4 {
+		return nil, false
+	}
+
+	batch := strings.EqualFold(log.Topics[0], TransferBatchEventTopic)
+	if !batch && !strings.EqualFold(log.Topics[0], TransferSingleEventTopic) {
+		return nil, false
+	}
+
+	contract, err := util.ParseAddress(log.Address)
+	if err != nil {
+		return nil, false
+	}
+
+	operator, ok := topicAddress(log.Topics[1])
+	if !ok {
+		return nil, false
+	}
+
+	from, ok := topicAddress(log.Topics[2])
+	if !ok {
+		return nil, false
+	}
+
+	to, ok := topicAddress(log.Topics[3])
+	if !ok {
+		return nil, false
+	}
+
+	words, ok := dataWords(log.Data)
+	if !ok {
+		return nil, false
+	}
+
+	var ids, amounts []*big.Int
+	if batch {
+		if ids, ok = wordArray(words, 0); !ok {
+			return nil, false
+		}
+		if amounts, ok = wordArray(words, 1); !ok || len(amounts) != len(ids) {
+			return nil, false
+		}
+	} else {
+		if len(words) != 2 {
+			return nil, false
+		}
+		ids, amounts = words[:1], words[1:]
+	}
+
+	transfers := make([]NFTTransfer, len(ids))
+	for i := range ids {
+		transfers[i] = NFTTransfer{
+			Standard:        StandardERC1155,
+			Contract:        contract,
+			Operator:        &operator,
+			From:            from,
+			To:              to,
+			TokenID:         ids[i].String(),
+			Amount:          amounts[i].String(),
+			TransactionHash: log.TransactionHash,
+			BlockNumber:     log.BlockNumber,
+			LogIndex:        log.LogIndex,
+		}
+	}
+
+	return transfers, true
+}
+
+// dataWords splits hex encoded log data into 32 byte words.
+func dataWords(data string) ([]*big.Int, bool) {
+	data = strings.TrimPrefix(data, "0x")
+	if len(data)%64 != 0 {
+		return nil, false
+	}
+
+	words := make([]*big.Int, len(data)/64)
+	for i := range words {
+		word, ok := new(big.Int).SetString(data[i*64:(i+1)*64], 16)
+		if !ok {
+			return nil, false
+		}
+		words[i] = word
+	}
+
+	return words, true
+}
+
+// wordArray reads the dynamic uint256 array whose offset is the head word at
+// index.
+func wordArray(words []*big.Int, index int) ([]*big.Int, bool) {
+	if index >= len(words) || !words[index].IsUint64() || words[index].Uint64()%32 != 0 {
+		return nil, false
+	}
+
+	start := words[index].Uint64() / 32
+	if start >= uint64(len(words)) || !words[start].IsUint64() {
+		return nil, false
+	}
+
+	length := words[start].Uint64()
+	if start+1+length > uint64(len(words)) {
+		return nil, false
+	}
+
+	return words[start+1 : start+1+length], true
+}
+
+// DecodeNFTTransfers returns the ERC-721 and ERC-1155 transfers emitted by tx,
+// which are only known once its receipt is attached.
+func DecodeNFTTransfers(tx evm.Transaction) []NFTTransfer {
+	if tx.Receipt == nil {
+		return nil
+	}
+
+	var transfers []NFTTransfer
+	for _, log := range tx.Receipt.Logs {
+		if transfer, ok := DecodeERC721Transfer(log); ok {
+			transfers = append(transfers, *transfer)
+		} else if batch, ok := DecodeERC1155Transfers(log); ok {
+			transfers = append(transfers, batch...)
+		}
+	}
+
+	for i := range transfers {
+		if transfers[i].TransactionHash == "" {
+			transfers[i].TransactionHash = tx.Hash
+		}
+		if transfers[i].BlockNumber == "" {
+			transfers[i].BlockNumber = tx.BlockNumber
+		}
+	}
+
+	return transfers
+}
+
+// ParseNFTTransfers returns the NFT transfers of block sent from or to a
+// subscribed address.
+func (p *BasicEthereumParser) ParseNFTTransfers(block *evm.Block) []NFTTransfer {
+	return subscribedNFTTransfers(p.Subscriptions.Snapshot(), block)
+}
+
+func subscribedNFTTransfers(subscriptions SubscriptionSnapshot, block *evm.Block) []NFTTransfer {
+	if block == nil {
+		return nil
+	}
+
+	var transfers []NFTTransfer
+	for _, tx := range block.Transactions {
+		for _, transfer := range DecodeNFTTransfers(tx) {
+			if subscriptions.Contains(transfer.From) || subscriptions.Contains(transfer.To) {
+				transfers = append(transfers, transfer)
+			}
+		}
+	}
+
+	return transfers
+}
+
+// GetNFTTransfers returns the NFT transfers from or to a subscribed address
+// found in its recorded transactions, oldest first.
+func (p *BasicEthereumParser) GetNFTTransfers(address string) ([]NFTTransfer, error) {
+	transactions, err := p.GetTransactions(address)
+	if err != nil {
+		return nil, err
+	}
+
+	subscribed, err := util.ParseAddress(address)
+	if err != nil {
+		return nil, errors.New("invalid address")
+	}
+
+	var transfers []NFTTransfer
+	for _, tx := range transactions {
+		for _, transfer := range DecodeNFTTransfers(tx) {
+			if transfer.From == subscribed || transfer.To == subscribed {
+				transfers = append(transfers, transfer)
+			}
+		}
+	}
+
+	return transfers, nil
+}
@@ -0,0 +1,146 @@
+package ethereumparser_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	evmparser "ethereum-parser/pkg/ethereum-parser"
+	evm "ethereum-parser/pkg/ethereum-rpc-client"
+	"ethereum-parser/util"
+)
+
+const (
+	nft      = "0xbc4ca0eda7647a8ab7c2061c2e118a18a936f13d"
+	operator = "0x1e0049783f008a0085193e00003d00cd54003c71"
+)
+
+func word(value string) string {
+	return "000000000000000000000000000000000000000000000000000000000000000"[len(value)-1:] + value
+}
+
+func erc721Log(from string, to string) evm.Log {
+	return evm.Log{
+		Address:  nft,
+		Topics:   []string{evmparser.TransferEventTopic, topic(from), topic(to), "0x" + word("2a")},
+		Data:     "0x",
+		LogIndex: "0x1",
+	}
+}
+
+func TestDecodeERC721Transfer(t *testing.T) {
+	transfer, ok := evmparser.DecodeERC721Transfer(erc721Log(sender, recipient))
+
+	assert.True(t, ok)
+	assert.Equal(t, &evmparser.NFTTransfer{
+		Standard: evmparser.StandardERC721,
+		Contract: util.MustParseAddress(nft),
+		From:     util.MustParseAddress(sender),
+		To:       util.MustParseAddress(recipient),
+		TokenID:  "42",
+		Amount:   "1",
+		LogIndex: "0x1",
+	}, transfer)
+
+	_, ok = evmparser.DecodeERC721Transfer(transferLog(sender, recipient))
+	assert.False(t, ok, "ERC-20 transfers should be rejected")
+}
+
+func TestDecodeERC1155Transfers(t *testing.T) {
+	cases := []struct {
+		name     string
+		topic    string
+		data     string
+		expected [][2]string
+	}{
+		{
+			name:     "TransferSingle",
+			topic:    evmparser.TransferSingleEventTopic,
+			data:     "0x" + word("7") + word("3"),
+			expected: [][2]string{{"7", "3"}},
+		},
+		{
+			name:  "TransferBatch",
+			topic: evmparser.TransferBatchEventTopic,
+			// ids at 0x40 and amounts at 0xa0, two entries each
+			data:     "0x" + word("40") + word("a0") + word("2") + word("1") + word("2") + word("2") + word("a") + word("14"),
+			expected: [][2]string{{"1", "10"}, {"2", "20"}},
+		},
+		{
+			name:  "TransferBatch length mismatch",
+			topic: evmparser.TransferBatchEventTopic,
+			data:  "0x" + word("40") + word("a0") + word("2") + word("1") + word("2") + word("1") + word("a"),
+		},
+		{
+			name:  "Truncated data",
+			topic: evmparser.TransferSingleEventTopic,
+			data:  "0x" + word("7"),
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			log := evm.Log{
+				Address: nft,
+				Topics:  []string{c.topic, topic(operator), topic(sender), topic(recipient)},
+				Data:    c.data,
+			}
+
+			transfers, ok := evmparser.DecodeERC1155Transfers(log)
+
+			assert.Equal(t, c.expected != nil, ok)
+			assert.Len(t, transfers, len(c.expected))
+			operatorAddress := util.MustParseAddress(operator)
+			for i, expected := range c.expected {
+				assert.Equal(t, evmparser.NFTTransfer{
+					Standard: evmparser.StandardERC1155,
+					Contract: util.MustParseAddress(nft),
+					Operator: &operatorAddress,
+					From:     util.MustParseAddress(sender),
+					To:       util.MustParseAddress(recipient),
+					TokenID:  expected[0],
+					Amount:   expected[1],
+				}, transfers[i])
+			}
+		})
+	}
+}
+
+func TestBasicEthereumParser_NFTTransfers(t *testing.T) {
+	parser := evmparser.NewBasicEthereumParser()
+	parser.Subscribe(recipient)
+
+	// A marketplace settles the sale, so only the log mentions the recipient
+	tx := evm.Transaction{
+		Hash:        "0x1",
+		From:        sender,
+		To:          "0x00000000000000adc04c56bf30ac9d3c0aaf14dc",
+		BlockNumber: "0x10",
+		Receipt:     &evm.Receipt{Status: evm.ReceiptStatusSuccess, Logs: []evm.Log{erc721Log(sender, recipient)}},
+	}
+	block := &evm.Block{Number: "0x10", Transactions: []evm.Transaction{tx}}
+
+	txs, err := parser.ParseBlock(block)
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, []evm.Transaction{tx}, txs, "NFT recipient should match the transaction")
+
+	expected := []evmparser.NFTTransfer{{
+		Standard:        evmparser.StandardERC721,
+		Contract:        util.MustParseAddress(nft),
+		From:            util.MustParseAddress(sender),
+		To:              util.MustParseAddress(recipient),
+		TokenID:         "42",
+		Amount:          "1",
+		TransactionHash: "0x1",
+		BlockNumber:     "0x10",
+		LogIndex:        "0x1",
+	}}
+	assert.Equal(t, expected, parser.ParseNFTTransfers(block))
+
+	transfers, err := parser.GetNFTTransfers(recipient)
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, expected, transfers, "NFT transfers should be served from the recorded transactions")
+
+	_, err = parser.GetNFTTransfers(sender)
+	assert.EqualError(t, err, "address not subscribed")
+}
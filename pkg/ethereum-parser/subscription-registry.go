@@ -0,0 +1,88 @@
+package ethereumparser
+
+import (
+	"ethereum-parser/util"
+	"sync"
+	"sync/atomic"
+)
+
+// SubscriptionRegistry is the set of subscribed addresses of a parser along
+// with their confirmation policies. It is safe for concurrent use: writers
+// replace the whole set, so a Snapshot is a consistent view that later
+// subscriptions do not change.
+type SubscriptionRegistry struct {
+	mutex    sync.Mutex
+	snapshot atomic.Pointer[SubscriptionSnapshot]
+}
+
+// SubscriptionSnapshot is an immutable view of a SubscriptionRegistry.
+type SubscriptionSnapshot map[util.Address]ConfirmationPolicy
+
+func NewSubscriptionRegistry(addresses ...util.Address) *SubscriptionRegistry {
+	snapshot := make(SubscriptionSnapshot, len(addresses))
+	for _, address := range addresses {
+		snapshot[address] = ConfirmationPolicy{}
+	}
+
+	r := &SubscriptionRegistry{}
+	r.snapshot.Store(&snapshot)
+	return r
+}
+
+// Snapshot returns the current subscriptions, it must not be modified.
+func (r *SubscriptionRegistry) Snapshot() SubscriptionSnapshot {
+	return *r.snapshot.Load()
+}
+
+// Add subscribes address with policy, replacing the policy of an existing
+// subscription.
+func (r *SubscriptionRegistry) Add(address util.Address, policy ConfirmationPolicy) {
+	r.update(func(snapshot SubscriptionSnapshot) {
+		snapshot[address] = policy
+	})
+}
+
+// Remove unsubscribes address and reports whether it was subscribed.
+func (r *SubscriptionRegistry) Remove(address util.Address) bool {
+	removed := false
+	r.update(func(snapshot SubscriptionSnapshot) {
+		_, removed = snapshot[address]
+		delete(snapshot, address)
+	})
+
+	return removed
+}
+
+func (r *SubscriptionRegistry) Contains(address util.Address) bool {
+	return r.Snapshot().Contains(address)
+}
+
+func (r *SubscriptionRegistry) Len() int {
+	return len(r.Snapshot())
+}
+
+// update applies change to a copy of the subscriptions and publishes it.
+func (r *SubscriptionRegistry) update(change func(SubscriptionSnapshot)) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	current := r.Snapshot()
+	next := make(SubscriptionSnapshot, len(current)+1)
+	for address, policy := range current {
+		next[address] = policy
+	}
+	change(next)
+
+	r.snapshot.Store(&next)
+}
+
+func (s SubscriptionSnapshot) Contains(address util.Address) bool {
+	_, ok := s[address]
+	return ok
+}
+
+// ContainsHex reports whether the hex address is subscribed.
+func (s SubscriptionSnapshot) ContainsHex(address string) bool {
+	parsed, err := util.ParseAddress(address)
+	return err == nil && s.Contains(parsed)
+}
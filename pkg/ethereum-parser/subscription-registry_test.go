@@ -0,0 +1,62 @@
+package ethereumparser_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	evmparser "ethereum-parser/pkg/ethereum-parser"
+	"ethereum-parser/util"
+)
+
+func TestSubscriptionRegistry(t *testing.T) {
+	first := util.MustParseAddress("0x0000000000000000000000000000000000000001")
+	second := util.MustParseAddress("0x0000000000000000000000000000000000000002")
+
+	registry := evmparser.NewSubscriptionRegistry(first)
+	assert.True(t, registry.Contains(first), "Initial addresses should be subscribed")
+
+	snapshot := registry.Snapshot()
+	registry.Add(second, evmparser.ConfirmationPolicy{Depth: 3})
+
+	assert.False(t, snapshot.Contains(second), "Snapshots should not see later subscriptions")
+	assert.Equal(t, evmparser.ConfirmationPolicy{Depth: 3}, registry.Snapshot()[second])
+	assert.Equal(t, 2, registry.Len())
+
+	assert.True(t, registry.Remove(first))
+	assert.False(t, registry.Remove(first), "Removing twice should report nothing removed")
+	assert.True(t, snapshot.Contains(first), "Snapshots should not see later removals")
+	assert.True(t, registry.Snapshot().ContainsHex("0x0000000000000000000000000000000000000002"))
+	assert.False(t, registry.Snapshot().ContainsHex("0x2"), "Invalid addresses are never subscribed")
+}
+
+func TestSubscriptionRegistry_Concurrent(t *testing.T) {
+	registry := evmparser.NewSubscriptionRegistry()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+
+		address := util.Address{byte(i + 1)}
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				registry.Add(address, evmparser.ConfirmationPolicy{Depth: j})
+				registry.Remove(address)
+			}
+			registry.Add(address, evmparser.ConfirmationPolicy{})
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				for subscribed := range registry.Snapshot() {
+					registry.Contains(subscribed)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 8, registry.Len(), "Every address should end up subscribed")
+}
@@ -0,0 +1,176 @@
+package ethereumparser
+
+import (
+	"context"
+	evm "ethereum-parser/pkg/ethereum-rpc-client"
+	"ethereum-parser/util"
+	"math/big"
+	"strings"
+)
+
+// TransferEventTopic is keccak256("Transfer(address,address,uint256)").
+const TransferEventTopic = "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"
+
+// TokenResolver looks up the symbol and decimals of a token contract.
+type TokenResolver interface {
+	ResolveToken(ctx context.Context, token string) (*evm.TokenMetadata, error)
+}
+
+// DefaultTokenResolver is used by parsers created with NewBasicEthereumParser.
+// Token metadata is not resolved while it is nil.
+var DefaultTokenResolver TokenResolver
+
+func SetDefaultTokenResolver(resolver TokenResolver) {
+	DefaultTokenResolver = resolver
+}
+
+// TokenTransfer is an ERC-20 Transfer event. Amount is the raw integer amount
+// in the token's smallest unit, AmountFormatted the same amount scaled by the
+// token's decimals once they are known.
+type TokenTransfer struct {
+	Token           util.Address `json:"token"`
+	From            util.Address `json:"from"`
+	To              util.Address `json:"to"`
+	Amount          string       `json:"amount"`
+	AmountFormatted string       `json:"amountFormatted,omitempty"`
+	Symbol          string       `json:"symbol,omitempty"`
+	Decimals        *int         `json:"decimals,omitempty"`
+	TransactionHash string       `json:"transactionHash"`
+	BlockNumber     string       `json:"blockNumber"`
+	LogIndex        string       `json:"logIndex"`
+}
+
+// DecodeERC20Transfer decodes log as an ERC-20 Transfer event. ERC-721
+// transfers share the signature but index the token id as a fourth topic, so
+// they are rejected.
+func DecodeERC20Transfer(log evm.Log) (*TokenTransfer, bool) {
+	if log.Removed || len(log.Topics) != 3 || !strings.EqualFold(log.Topics[0], TransferEventTopic) {
+		return nil, false
+	}
+
+	token, err := util.ParseAddress(log.Address)
+	if err != nil {
+		return nil, false
+	}
+
+	from, ok := topicAddress(log.Topics[1])
+	if !ok {
+		return nil, false
+	}
+
+	to, ok := topicAddress(log.Topics[2])
+	if !ok {
+		return nil, false
+	}
+
+	data := strings.TrimPrefix(log.Data, "0x")
+	if len(data) != 64 {
+		return nil, false
+	}
+
+	amount, ok := new(big.Int).SetString(data, 16)
+	if !ok {
+		return nil, false
+	}
+
+	return &TokenTransfer{
+		Token:           token,
+		From:            from,
+		To:              to,
+		Amount:          amount.String(),
+		TransactionHash: log.TransactionHash,
+		BlockNumber:     log.BlockNumber,
+		LogIndex:        log.LogIndex,
+	}, true
+}
+
+// topicAddress reads an address left padded to 32 bytes in an indexed topic.
+func topicAddress(topic string) (util.Address, bool) {
+	topic = strings.TrimPrefix(topic, "0x")
+	if len(topic) != 64 || strings.Trim(topic[:24], "0") != "" {
+		return util.Address{}, false
+	}
+
+	address, err := util.ParseAddress("0x" + strings.ToLower(topic[24:]))
+	return address, err == nil
+}
+
+// tokenTransfers decodes the ERC-20 transfers emitted by tx, which are only
+// known once its receipt is attached.
+func tokenTransfers(tx evm.Transaction) []*TokenTransfer {
+	if tx.Receipt == nil {
+		return nil
+	}
+
+	var transfers []*TokenTransfer
+	for _, log := range tx.Receipt.Logs {
+		if transfer, ok := DecodeERC20Transfer(log); ok {
+			if transfer.TransactionHash == "" {
+				transfer.TransactionHash = tx.Hash
+			}
+			if transfer.BlockNumber == "" {
+				transfer.BlockNumber = tx.BlockNumber
+			}
+			transfers = append(transfers, transfer)
+		}
+	}
+
+	return transfers
+}
+
+// ParseTokenTransfers returns the ERC-20 transfers of block sent from or to a
+// subscribed address, with the token symbol and decimals when a resolver is
+// set and the token exposes them.
+func (p *BasicEthereumParser) ParseTokenTransfers(ctx context.Context, block *evm.Block) []TokenTransfer {
+	return p.resolveTokens(ctx, subscribedTokenTransfers(p.Subscriptions.Snapshot(), block))
+}
+
+func subscribedTokenTransfers(subscriptions SubscriptionSnapshot, block *evm.Block) []TokenTransfer {
+	if block == nil {
+		return nil
+	}
+
+	var transfers []TokenTransfer
+	for _, tx := range block.Transactions {
+		for _, transfer := range tokenTransfers(tx) {
+			if subscriptions.Contains(transfer.From) || subscriptions.Contains(transfer.To) {
+				transfers = append(transfers, *transfer)
+			}
+		}
+	}
+
+	return transfers
+}
+
+// resolveTokens sets the token metadata of transfers when a resolver is set.
+func (p *BasicEthereumParser) resolveTokens(ctx context.Context, transfers []TokenTransfer) []TokenTransfer {
+	p.mutex.Lock()
+	resolver := p.TokenResolver
+	p.mutex.Unlock()
+
+	if resolver == nil {
+		return transfers
+	}
+
+	// Resolve outside the lock, it may call the node. Transfers keep their raw
+	// amounts once it fails, so a slow node does not hold up delivery.
+	for i := range transfers {
+		metadata, err := resolver.ResolveToken(ctx, transfers[i].Token.Lower())
+		if err != nil {
+			break
+		}
+		if metadata == nil {
+			continue
+		}
+
+		decimals := metadata.Decimals
+		transfers[i].Symbol = metadata.Symbol
+		transfers[i].Decimals = &decimals
+
+		if amount, err := util.ParseQuantity(transfers[i].Amount); err == nil {
+			transfers[i].AmountFormatted = amount.Format(decimals)
+		}
+	}
+
+	return transfers
+}
@@ -0,0 +1,136 @@
+package ethereumparser_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	evmparser "ethereum-parser/pkg/ethereum-parser"
+	evm "ethereum-parser/pkg/ethereum-rpc-client"
+	"ethereum-parser/util"
+)
+
+const (
+	sender    = "0x7a250d5630b4cf539739df2c5dacb4c659f2488d"
+	recipient = "0xdac17f958d2ee523a2206206994597c13d831ec7"
+	token     = "0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48"
+)
+
+func topic(address string) string {
+	return "0x000000000000000000000000" + address[2:]
+}
+
+func transferLog(from string, to string) evm.Log {
+	return evm.Log{
+		Address:  token,
+		Topics:   []string{evmparser.TransferEventTopic, topic(from), topic(to)},
+		Data:     "0x00000000000000000000000000000000000000000000000000000000000f4240",
+		LogIndex: "0x3",
+	}
+}
+
+type staticResolver map[string]*evm.TokenMetadata
+
+func (r staticResolver) ResolveToken(ctx context.Context, token string) (*evm.TokenMetadata, error) {
+	return r[token], nil
+}
+
+type failingResolver struct{}
+
+func (failingResolver) ResolveToken(ctx context.Context, token string) (*evm.TokenMetadata, error) {
+	return nil, context.DeadlineExceeded
+}
+
+func TestDecodeERC20Transfer(t *testing.T) {
+	erc721 := transferLog(sender, recipient)
+	erc721.Topics = append(erc721.Topics, "0x0000000000000000000000000000000000000000000000000000000000000001")
+	erc721.Data = "0x"
+
+	otherEvent := transferLog(sender, recipient)
+	otherEvent.Topics[0] = "0x8c5be1e5ebec7d5bd14f71427d1e84f3dd0314c0f7b2291e5b200ac8c7c3b925"
+
+	removed := transferLog(sender, recipient)
+	removed.Removed = true
+
+	cases := []struct {
+		name     string
+		log      evm.Log
+		expected *evmparser.TokenTransfer
+	}{
+		{
+			name: "ERC-20 transfer",
+			log:  transferLog(sender, recipient),
+			expected: &evmparser.TokenTransfer{
+				Token:    util.MustParseAddress(token),
+				From:     util.MustParseAddress(sender),
+				To:       util.MustParseAddress(recipient),
+				Amount:   "1000000",
+				LogIndex: "0x3",
+			},
+		},
+		{name: "ERC-721 transfer", log: erc721},
+		{name: "Other event", log: otherEvent},
+		{name: "Removed log", log: removed},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			transfer, ok := evmparser.DecodeERC20Transfer(c.log)
+
+			assert.Equal(t, c.expected != nil, ok)
+			assert.Equal(t, c.expected, transfer)
+		})
+	}
+}
+
+func TestBasicEthereumParser_TokenTransfers(t *testing.T) {
+	parser := evmparser.NewBasicEthereumParser()
+	parser.Subscribe(recipient)
+	parser.TokenResolver = staticResolver{token: {Symbol: "USDC", Decimals: 6}}
+
+	// The recipient only shows up in the Transfer log of a router call
+	tx := evm.Transaction{
+		Hash:        "0x1",
+		From:        sender,
+		To:          "0x1111111254eeb25477b68fb85ed929f73a960582",
+		BlockNumber: "0x10",
+		Receipt:     &evm.Receipt{Status: evm.ReceiptStatusSuccess, Logs: []evm.Log{transferLog(sender, recipient)}},
+	}
+	block := &evm.Block{Number: "0x10", Transactions: []evm.Transaction{tx, {Hash: "0x2", From: sender}}}
+
+	txs, err := parser.ParseBlock(block)
+
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, []evm.Transaction{tx}, txs, "Token recipient should match the transaction")
+
+	history, err := parser.GetTransactions(recipient)
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, []evm.Transaction{tx}, history, "Transaction should be recorded for the token recipient")
+
+	decimals := 6
+	assert.Equal(t, []evmparser.TokenTransfer{
+		{
+			Token:           util.MustParseAddress(token),
+			From:            util.MustParseAddress(sender),
+			To:              util.MustParseAddress(recipient),
+			Amount:          "1000000",
+			AmountFormatted: "1",
+			Symbol:          "USDC",
+			Decimals:        &decimals,
+			TransactionHash: "0x1",
+			BlockNumber:     "0x10",
+			LogIndex:        "0x3",
+		},
+	}, parser.ParseTokenTransfers(context.Background(), block))
+
+	parser.TokenResolver = nil
+	transfers := parser.ParseTokenTransfers(context.Background(), block)
+	assert.Empty(t, transfers[0].Symbol, "Metadata should be left out without a resolver")
+	assert.Nil(t, transfers[0].Decimals, "Metadata should be left out without a resolver")
+
+	parser.TokenResolver = failingResolver{}
+	transfers = parser.ParseTokenTransfers(context.Background(), block)
+	assert.Equal(t, "1000000", transfers[0].Amount, "Failed lookups should keep the raw amount")
+	assert.Empty(t, transfers[0].AmountFormatted, "Failed lookups should keep the raw amount")
+}
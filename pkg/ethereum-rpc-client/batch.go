@@ -0,0 +1,166 @@
+package ethereumrpcclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// BatchElem is a single call of a batch request. Result and Error are filled
+// in from the matching response once the batch returns.
+type BatchElem struct {
+	Method string
+	Params []interface{}
+	Result json.RawMessage
+	Error  error
+}
+
+// BatchCallJSONRPC sends every element in one JSON-RPC batch request. The
+// returned error covers the request as a whole; failures of individual calls
+// are reported on each element.
+func (c *EthereumRPCClient) BatchCallJSONRPC(ctx context.Context, elems []BatchElem) error {
+	if c.Url == "" {
+		return errors.New("ethereum url is empty")
+	}
+
+	if len(elems) == 0 {
+		return nil
+	}
+
+	requests := make([]JSONRPCRequest, len(elems))
+	indexByID := make(map[int]int, len(elems))
+	for i, elem := range elems {
+		if elem.Method == "" {
+			return errors.New("method is empty")
+		}
+
+		requests[i] = JSONRPCRequest{
+			JSONRPC: "2.0",
+			Method:  elem.Method,
+			Params:  elem.Params,
+			ID:      c.nextID(),
+		}
+		indexByID[requests[i].ID] = i
+	}
+
+	reqBody, err := json.Marshal(requests)
+	if err != nil {
+		return errors.New("error marshalling request body, " + err.Error())
+	}
+
+	return c.withRetry(ctx, func() error {
+		for i := range elems {
+			elems[i].Result = nil
+			elems[i].Error = nil
+		}
+
+		body, err := c.post(ctx, reqBody)
+		if err != nil {
+			return err
+		}
+
+		if err := matchBatchResponse(body, elems, indexByID); err != nil {
+			return err
+		}
+
+		// Retry the whole batch when any call hit a transient failure
+		for _, elem := range elems {
+			if IsRetryable(elem.Error) {
+				return elem.Error
+			}
+		}
+
+		return nil
+	})
+}
+
+// matchBatchResponse fills elems from the batch response body.
+func matchBatchResponse(body []byte, elems []BatchElem, indexByID map[int]int) error {
+	// Nodes that reject the whole batch answer with a single error object
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '{' {
+		var rpcResp JSONRPCResponse
+		if err := json.Unmarshal(body, &rpcResp); err != nil {
+			return errors.New("error decoding response body, " + err.Error())
+		}
+		if rpcResp.Error.Code != 0 {
+			rpcErr := rpcResp.Error
+			return &rpcErr
+		}
+		return errors.New("error decoding response body, expected an array")
+	}
+
+	var rpcResps []JSONRPCResponse
+	if err := json.Unmarshal(body, &rpcResps); err != nil {
+		return errors.New("error decoding response body, " + err.Error())
+	}
+
+	// Responses may come back in any order, so match them up by id
+	answered := make([]bool, len(elems))
+	for _, rpcResp := range rpcResps {
+		i, ok := indexByID[rpcResp.ID]
+		if !ok || answered[i] {
+			continue
+		}
+		answered[i] = true
+
+		if rpcResp.Error.Code != 0 {
+			rpcErr := rpcResp.Error
+			elems[i].Error = &rpcErr
+			continue
+		}
+		elems[i].Result = rpcResp.Result
+	}
+
+	for i := range elems {
+		if !answered[i] {
+			elems[i].Error = errors.New("missing response for " + elems[i].Method)
+		}
+	}
+
+	return nil
+}
+
+// GetBlocksByRange fetches blocks from..to inclusive with a single batch
+// request and returns them in ascending order.
+func (c *EthereumRPCClient) GetBlocksByRange(ctx context.Context, from int, to int) ([]*Block, error) {
+	if from > to {
+		return nil, errors.New("invalid block range, " + strconv.Itoa(from) + " > " + strconv.Itoa(to))
+	}
+
+	elems := make([]BatchElem, 0, to-from+1)
+	for number := from; number <= to; number++ {
+		elems = append(elems, BatchElem{
+			Method: "eth_getBlockByNumber",
+			Params: []interface{}{"0x" + strconv.FormatInt(int64(number), 16), true},
+		})
+	}
+
+	if err := c.BatchCallJSONRPC(ctx, elems); err != nil {
+		return nil, fmt.Errorf("error getting blocks, %w", err)
+	}
+
+	blocks := make([]*Block, len(elems))
+	for i, elem := range elems {
+		number := strconv.Itoa(from + i)
+
+		if elem.Error != nil {
+			return nil, fmt.Errorf("error getting block %s, %w", number, elem.Error)
+		}
+
+		if len(elem.Result) == 0 || string(elem.Result) == "null" {
+			return nil, notFoundError("block " + number + " not found")
+		}
+
+		var block Block
+		if err := json.Unmarshal(elem.Result, &block); err != nil {
+			return nil, errors.New("error unmarshalling block " + number + ", " + err.Error())
+		}
+		blocks[i] = &block
+	}
+
+	return blocks, nil
+}
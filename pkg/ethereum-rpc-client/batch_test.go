@@ -0,0 +1,161 @@
+package ethereumrpcclient_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	ethereumrpcclient "ethereum-parser/pkg/ethereum-rpc-client"
+)
+
+// newBatchServer answers each request of a batch with respond, in reverse
+// order to make sure the client matches responses by id.
+func newBatchServer(t *testing.T, respond func(request ethereumrpcclient.JSONRPCRequest) string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var requests []ethereumrpcclient.JSONRPCRequest
+		if err := json.NewDecoder(r.Body).Decode(&requests); err != nil {
+			t.Errorf("Request should be a batch, %v", err)
+		}
+
+		var responses []json.RawMessage
+		for i := len(requests) - 1; i >= 0; i-- {
+			if response := respond(requests[i]); response != "" {
+				responses = append(responses, json.RawMessage(response))
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(responses)
+	}))
+}
+
+func TestBatchCallJSONRPC(t *testing.T) {
+	server := newBatchServer(t, func(request ethereumrpcclient.JSONRPCRequest) string {
+		id := strconv.Itoa(request.ID)
+		switch request.Method {
+		case "eth_blockNumber":
+			return `{"jsonrpc":"2.0","result":"0x10","id":` + id + `}`
+		case "eth_chainId":
+			return `{"jsonrpc":"2.0","error":{"code":-32601,"message":"Method not found"},"id":` + id + `}`
+		default:
+			return ""
+		}
+	})
+	defer server.Close()
+
+	client := ethereumrpcclient.NewEthereumRPCClient(server.URL, nil)
+	elems := []ethereumrpcclient.BatchElem{
+		{Method: "eth_blockNumber", Params: []interface{}{}},
+		{Method: "eth_chainId", Params: []interface{}{}},
+		{Method: "eth_gasPrice", Params: []interface{}{}},
+	}
+
+	err := client.BatchCallJSONRPC(context.Background(), elems)
+
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, json.RawMessage(`"0x10"`), elems[0].Result, "Result should be matched by id")
+	assert.NoError(t, elems[0].Error, "Unexpected error")
+	assert.EqualError(t, elems[1].Error, "Method not found", "Per-item error should be reported")
+	assert.EqualError(t, elems[2].Error, "missing response for eth_gasPrice", "Missing response should be reported")
+}
+
+func TestBatchCallJSONRPC_Errors(t *testing.T) {
+	cases := []struct {
+		name        string
+		url         bool
+		elems       []ethereumrpcclient.BatchElem
+		status      int
+		response    string
+		expectedErr string
+	}{
+		{
+			name:        "Empty Ethereum URL",
+			url:         false,
+			elems:       []ethereumrpcclient.BatchElem{{Method: "eth_blockNumber"}},
+			expectedErr: "ethereum url is empty",
+		},
+		{
+			name:        "Empty method",
+			url:         true,
+			elems:       []ethereumrpcclient.BatchElem{{Method: ""}},
+			expectedErr: "method is empty",
+		},
+		{
+			name:        "Batch rejected",
+			url:         true,
+			elems:       []ethereumrpcclient.BatchElem{{Method: "eth_blockNumber"}},
+			status:      http.StatusOK,
+			response:    `{"jsonrpc":"2.0","error":{"code":-32600,"message":"batch too large"},"id":null}`,
+			expectedErr: "batch too large",
+		},
+		{
+			name:        "HTTP error",
+			url:         true,
+			elems:       []ethereumrpcclient.BatchElem{{Method: "eth_blockNumber"}},
+			status:      http.StatusBadGateway,
+			expectedErr: "error sending request, status code: 502",
+		},
+		{
+			name:        "Invalid json",
+			url:         true,
+			elems:       []ethereumrpcclient.BatchElem{{Method: "eth_blockNumber"}},
+			status:      http.StatusOK,
+			response:    `invalid json`,
+			expectedErr: "error decoding response body",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(c.status)
+				w.Write([]byte(c.response))
+			}))
+			defer server.Close()
+
+			url := ""
+			if c.url {
+				url = server.URL
+			}
+			client := ethereumrpcclient.NewEthereumRPCClient(url, nil)
+
+			err := client.BatchCallJSONRPC(context.Background(), c.elems)
+
+			assert.Error(t, err, "Expected an error")
+			assert.ErrorContains(t, err, c.expectedErr, "Unexpected error")
+		})
+	}
+}
+
+func TestGetBlocksByRange(t *testing.T) {
+	server := newBatchServer(t, func(request ethereumrpcclient.JSONRPCRequest) string {
+		number := request.Params[0].(string)
+		if number == "0x4" {
+			return `{"jsonrpc":"2.0","result":null,"id":` + strconv.Itoa(request.ID) + `}`
+		}
+		return `{"jsonrpc":"2.0","result":{"number":"` + number + `","hash":"0x` + number[2:] + number[2:] + `"},"id":` + strconv.Itoa(request.ID) + `}`
+	})
+	defer server.Close()
+
+	client := ethereumrpcclient.NewEthereumRPCClient(server.URL, nil)
+
+	blocks, err := client.GetBlocksByRange(context.Background(), 1, 3)
+
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, []*ethereumrpcclient.Block{
+		{Number: "0x1", Hash: "0x11"},
+		{Number: "0x2", Hash: "0x22"},
+		{Number: "0x3", Hash: "0x33"},
+	}, blocks, "Blocks should be returned in ascending order")
+
+	_, err = client.GetBlocksByRange(context.Background(), 3, 4)
+	assert.EqualError(t, err, "block 4 not found")
+
+	_, err = client.GetBlocksByRange(context.Background(), 3, 2)
+	assert.EqualError(t, err, "invalid block range, 3 > 2")
+}
@@ -2,20 +2,45 @@ package ethereumrpcclient
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"ethereum-parser/config"
+	"ethereum-parser/util"
+	"fmt"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
 )
 
-type Transaction struct {
-	Hash  string
-	From  string
-	To    string
-	Value string
+const DefaultTimeout = 30 * time.Second
+
+var (
+	// ErrNotFound matches the errors of calls the node has no result for,
+	// such as blocks it has not caught up with yet.
+	ErrNotFound            = errors.New("not found")
+	ErrBlockNotFound error = notFoundError("block not found")
+)
+
+type notFoundError string
+
+func (e notFoundError) Error() string {
+	return string(e)
+}
+
+func (e notFoundError) Is(target error) bool {
+	return target == ErrNotFound
 }
 
+const (
+	TagLatest    = "latest"
+	TagSafe      = "safe"
+	TagFinalized = "finalized"
+)
+
 type Block struct {
 	Number           string
 	Hash             string
@@ -38,6 +63,13 @@ type Block struct {
 	Uncles           []string
 }
 
+// MarshalJSON writes the miner address with its EIP-55 checksum.
+func (b Block) MarshalJSON() ([]byte, error) {
+	type block Block
+	b.Miner = util.ChecksumAddress(b.Miner)
+	return json.Marshal(block(b))
+}
+
 type JSONRPCRequest struct {
 	JSONRPC string        `json:"jsonrpc"`
 	Method  string        `json:"method"`
@@ -49,16 +81,107 @@ type JSONRPCResponse struct {
 	JSONRPC string          `json:"jsonrpc"`
 	Result  json.RawMessage `json:"result"`
 	ID      int             `json:"id"`
-	Error   struct {
-		Code    int    `json:"code"`
-		Message string `json:"message"`
-	} `json:"error"`
+	Error   RPCError        `json:"error"`
+}
+
+// RPCError is the error object of a JSON-RPC response.
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
 }
 
-func CallJSONRPC(method string, params []interface{}) (json.RawMessage, error) {
+func (e *RPCError) Error() string {
+	return e.Message
+}
+
+// RPCClient is implemented by EthereumRPCClient for a single endpoint and by
+// ProviderPool for several.
+type RPCClient interface {
+	CallJSONRPC(ctx context.Context, method string, params []interface{}) (json.RawMessage, error)
+	BatchCallJSONRPC(ctx context.Context, elems []BatchElem) error
+	GetChainID(ctx context.Context) (int, error)
+	GetBlockNumber(ctx context.Context) (int, error)
+	GetBlockByNumber(ctx context.Context, blockNumber int) (*Block, error)
+	GetBlockByHash(ctx context.Context, hash string) (*Block, error)
+	GetBlockNumberByTag(ctx context.Context, tag string) (int, error)
+	GetBlocksByRange(ctx context.Context, from int, to int) ([]*Block, error)
+	GetTransactionReceipt(ctx context.Context, hash string) (*Receipt, error)
+	GetTransactionReceipts(ctx context.Context, hashes []string) ([]*Receipt, error)
+	GetBlockReceipts(ctx context.Context, blockNumber int) ([]*Receipt, error)
+	GetBlockReceiptsByRange(ctx context.Context, from int, to int) ([][]*Receipt, error)
+	GetInternalTransactions(ctx context.Context, blockNumber int, blockHash string, tracer string) ([][]InternalTransaction, error)
+}
+
+var _ RPCClient = (*EthereumRPCClient)(nil)
+
+type EthereumRPCClient struct {
+	Url         string
+	HttpClient  *http.Client
+	RetryPolicy RetryPolicy
+	// RateLimiter throttles outgoing requests when set
+	RateLimiter *RateLimiter
+	requestID   atomic.Int64
+}
+
+// NewEthereumRPCClient creates a client for the JSON-RPC endpoint at url that
+// makes a single attempt per call. A nil httpClient is replaced by one with
+// DefaultTimeout.
+func NewEthereumRPCClient(url string, httpClient *http.Client) *EthereumRPCClient {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: DefaultTimeout}
+	}
+
+	return &EthereumRPCClient{
+		Url:         url,
+		HttpClient:  httpClient,
+		RetryPolicy: NoRetry,
+	}
+}
+
+func NewEthereumRPCClientFromConfig() *EthereumRPCClient {
 	ethereumConfig := config.GetConfig().Ethereum
 
-	if ethereumConfig.Url == "" {
+	return newEthereumRPCClientFromConfig(ethereumConfig, ethereumConfig.Url)
+}
+
+func newEthereumRPCClientFromConfig(ethereumConfig config.Ethereum, url string) *EthereumRPCClient {
+	timeout := DefaultTimeout
+	if ethereumConfig.RequestTimeout > 0 {
+		timeout = time.Duration(ethereumConfig.RequestTimeout) * time.Second
+	}
+
+	client := NewEthereumRPCClient(url, &http.Client{Timeout: timeout})
+	client.RetryPolicy = retryPolicyFromConfig(ethereumConfig)
+
+	if ethereumConfig.RateLimit > 0 {
+		client.RateLimiter = NewRateLimiter(ethereumConfig.RateLimit, ethereumConfig.RateLimitBurst)
+	}
+
+	return client
+}
+
+func retryPolicyFromConfig(ethereumConfig config.Ethereum) RetryPolicy {
+	policy := DefaultRetryPolicy()
+
+	if ethereumConfig.RetryMaxAttempts > 0 {
+		policy.MaxAttempts = ethereumConfig.RetryMaxAttempts
+	}
+	if ethereumConfig.RetryInitialBackoffMs > 0 {
+		policy.InitialBackoff = time.Duration(ethereumConfig.RetryInitialBackoffMs) * time.Millisecond
+	}
+	if ethereumConfig.RetryMaxBackoffMs > 0 {
+		policy.MaxBackoff = time.Duration(ethereumConfig.RetryMaxBackoffMs) * time.Millisecond
+	}
+
+	return policy
+}
+
+func (c *EthereumRPCClient) nextID() int {
+	return int(c.requestID.Add(1))
+}
+
+func (c *EthereumRPCClient) CallJSONRPC(ctx context.Context, method string, params []interface{}) (json.RawMessage, error) {
+	if c.Url == "" {
 		return nil, errors.New("ethereum url is empty")
 	}
 
@@ -70,38 +193,92 @@ func CallJSONRPC(method string, params []interface{}) (json.RawMessage, error) {
 		JSONRPC: "2.0",
 		Method:  method,
 		Params:  params,
-		ID:      1,
+		ID:      c.nextID(),
 	})
 
 	if err != nil {
 		return nil, errors.New("error marshalling request body, " + err.Error())
 	}
 
-	resp, err := http.Post(ethereumConfig.Url, "application/json", bytes.NewBuffer(reqBody))
+	var rpcResp JSONRPCResponse
+	err = c.withRetry(ctx, func() error {
+		body, err := c.post(ctx, reqBody)
+		if err != nil {
+			return err
+		}
+
+		rpcResp = JSONRPCResponse{}
+		if err := json.Unmarshal(body, &rpcResp); err != nil {
+			return errors.New("error decoding response body, " + err.Error())
+		}
+
+		if rpcResp.Error.Code != 0 {
+			rpcErr := rpcResp.Error
+			return &rpcErr
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return rpcResp.Result, nil
+}
+
+// post sends a JSON-RPC payload to the endpoint and returns the response body.
+func (c *EthereumRPCClient) post(ctx context.Context, reqBody []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.Url, bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, errors.New("error creating request, " + err.Error())
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.HttpClient.Do(req)
 	if err != nil {
-		return nil, errors.New("error sending request, " + err.Error())
+		return nil, fmt.Errorf("error sending request, %w", err)
 	}
+	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
-		return nil, errors.New("error sending request, status code: " + strconv.Itoa(resp.StatusCode))
+		io.Copy(io.Discard, resp.Body)
+		return nil, &HTTPError{
+			StatusCode: resp.StatusCode,
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
 	}
 
-	var rpcResp JSONRPCResponse
-	if err := json.NewDecoder(resp.Body).Decode(&rpcResp); err != nil {
-		return nil, errors.New("error decoding response body, " + err.Error())
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.New("error reading response body, " + err.Error())
 	}
 
-	if rpcResp.Error.Code != 0 {
-		return nil, errors.New(rpcResp.Error.Message)
+	return body, nil
+}
+
+func (c *EthereumRPCClient) GetChainID(ctx context.Context) (int, error) {
+	result, err := c.CallJSONRPC(ctx, "eth_chainId", []interface{}{})
+	if err != nil {
+		return 0, fmt.Errorf("error getting chain id, %w", err)
 	}
 
-	return rpcResp.Result, nil
+	var hexChainID string
+	if err := json.Unmarshal(result, &hexChainID); err != nil {
+		return 0, errors.New("error unmarshalling chain id, " + err.Error())
+	}
+
+	chainID, err := strconv.ParseInt(strings.TrimPrefix(hexChainID, "0x"), 16, 64)
+	if err != nil {
+		return 0, errors.New("error parsing chain id, " + err.Error())
+	}
+
+	return int(chainID), nil
 }
 
-func GetBlockNumber() (int, error) {
-	result, err := CallJSONRPC("eth_blockNumber", []interface{}{})
+func (c *EthereumRPCClient) GetBlockNumber(ctx context.Context) (int, error) {
+	result, err := c.CallJSONRPC(ctx, "eth_blockNumber", []interface{}{})
 	if err != nil {
-		return 0, errors.New("error getting block number, " + err.Error())
+		return 0, fmt.Errorf("error getting block number, %w", err)
 	}
 
 	if result == nil {
@@ -113,20 +290,41 @@ func GetBlockNumber() (int, error) {
 		return 0, errors.New("error unmarshalling block number, " + err.Error())
 	}
 
-	blockNumber, err := strconv.ParseInt(hexBlockNumber[2:], 16, 64)
+	blockNumber, err := util.ParseBlockNumber(hexBlockNumber)
 	if err != nil {
 		return 0, errors.New("error parsing block number, " + err.Error())
 	}
 
-	return int(blockNumber), nil
+	return blockNumber, nil
 }
 
-func GetBlockByNumber(blockNumber int) (*Block, error) {
-	result, err := CallJSONRPC("eth_getBlockByNumber", []interface{}{
+// GetBlockByNumber returns the block with its transactions, or
+// ErrBlockNotFound when the node does not have it yet.
+func (c *EthereumRPCClient) GetBlockByNumber(ctx context.Context, blockNumber int) (*Block, error) {
+	result, err := c.CallJSONRPC(ctx, "eth_getBlockByNumber", []interface{}{
 		"0x" + strconv.FormatInt(int64(blockNumber), 16), true},
 	)
 	if err != nil {
-		return nil, errors.New("error getting block, " + err.Error())
+		return nil, fmt.Errorf("error getting block, %w", err)
+	}
+
+	return decodeBlock(result)
+}
+
+// GetBlockByHash returns the block with its transactions, or ErrBlockNotFound
+// when the node does not know the hash.
+func (c *EthereumRPCClient) GetBlockByHash(ctx context.Context, hash string) (*Block, error) {
+	result, err := c.CallJSONRPC(ctx, "eth_getBlockByHash", []interface{}{hash, true})
+	if err != nil {
+		return nil, fmt.Errorf("error getting block, %w", err)
+	}
+
+	return decodeBlock(result)
+}
+
+func decodeBlock(result json.RawMessage) (*Block, error) {
+	if string(result) == "null" {
+		return nil, ErrBlockNotFound
 	}
 
 	var block Block
@@ -136,3 +334,30 @@ func GetBlockByNumber(blockNumber int) (*Block, error) {
 
 	return &block, nil
 }
+
+// GetBlockNumberByTag resolves a block tag such as "safe" or "finalized" to
+// its block number without fetching the transactions.
+func (c *EthereumRPCClient) GetBlockNumberByTag(ctx context.Context, tag string) (int, error) {
+	result, err := c.CallJSONRPC(ctx, "eth_getBlockByNumber", []interface{}{tag, false})
+	if err != nil {
+		return 0, fmt.Errorf("error getting %s block, %w", tag, err)
+	}
+
+	var header struct {
+		Number string
+	}
+	if err := json.Unmarshal(result, &header); err != nil {
+		return 0, errors.New("error unmarshalling " + tag + " block, " + err.Error())
+	}
+
+	if !strings.HasPrefix(header.Number, "0x") {
+		return 0, errors.New(tag + " block is not available")
+	}
+
+	blockNumber, err := util.ParseBlockNumber(header.Number)
+	if err != nil {
+		return 0, errors.New("error parsing " + tag + " block number, " + err.Error())
+	}
+
+	return blockNumber, nil
+}
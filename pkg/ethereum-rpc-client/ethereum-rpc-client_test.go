@@ -1,16 +1,20 @@
 package ethereumrpcclient_test
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
 	"ethereum-parser/config"
 	ethereumrpcclient "ethereum-parser/pkg/ethereum-rpc-client"
+	"ethereum-parser/util"
 )
 
 func TestCallJSONRPC(t *testing.T) {
@@ -85,7 +89,7 @@ func TestCallJSONRPC(t *testing.T) {
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
 			// Set up a mock HTTP server
-			config.Config.Ethereum.Url = ""
+			url := ""
 
 			if c.responseStatus != 0 {
 				server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -95,10 +99,11 @@ func TestCallJSONRPC(t *testing.T) {
 				}))
 				defer server.Close()
 
-				// Set Ethereum URL in configuration
-				config.Config.Ethereum.Url = server.URL
+				url = server.URL
 			}
 
+			client := ethereumrpcclient.NewEthereumRPCClient(url, nil)
+
 			// Call CallJSONRPC and capture panic if any
 			defer func() {
 				if r := recover(); r != nil {
@@ -106,7 +111,7 @@ func TestCallJSONRPC(t *testing.T) {
 				}
 			}()
 
-			result, err := ethereumrpcclient.CallJSONRPC(c.method, c.params)
+			result, err := client.CallJSONRPC(context.Background(), c.method, c.params)
 
 			if c.expectedErr != nil {
 				assert.Error(t, err, "Expected an error")
@@ -144,8 +149,8 @@ func TestGetBlockNumber(t *testing.T) {
 			name:          "Empty result",
 			response:      `{"jsonrpc":"2.0","result":"","id":1}`,
 			expected:      0,
-			expectedErr:   errors.New("error unmarshalling block number"),
-			expectedPanic: true,
+			expectedErr:   errors.New("error parsing block number"),
+			expectedPanic: false,
 		},
 		{
 			name:          "Invalid response format",
@@ -180,8 +185,7 @@ func TestGetBlockNumber(t *testing.T) {
 			}))
 			defer server.Close()
 
-			// Set Ethereum URL in configuration
-			config.Config.Ethereum.Url = server.URL
+			client := ethereumrpcclient.NewEthereumRPCClient(server.URL, nil)
 
 			// Call GetBlockNumber and capture panic if any
 			defer func() {
@@ -190,7 +194,7 @@ func TestGetBlockNumber(t *testing.T) {
 				}
 			}()
 
-			blockNumber, err := ethereumrpcclient.GetBlockNumber()
+			blockNumber, err := client.GetBlockNumber(context.Background())
 
 			if c.expectedErr != nil {
 				assert.Error(t, err, "Expected an error")
@@ -249,6 +253,14 @@ func TestGetBlockByNumber(t *testing.T) {
 			expectedErr:   errors.New("error decoding response body"),
 			expectedPanic: false,
 		},
+		{
+			name:          "Null block",
+			blockNumber:   1,
+			response:      `{"jsonrpc":"2.0","result":null,"id":1}`,
+			expected:      nil,
+			expectedErr:   ethereumrpcclient.ErrBlockNotFound,
+			expectedPanic: false,
+		},
 		{
 			name:        "Valid block with transactions",
 			blockNumber: 1,
@@ -261,7 +273,7 @@ func TestGetBlockByNumber(t *testing.T) {
 						Hash:  "0xabc",
 						From:  "0xdef",
 						To:    "0x456",
-						Value: "100",
+						Value: util.MustParseQuantity("100"),
 					},
 				},
 			},
@@ -296,8 +308,7 @@ func TestGetBlockByNumber(t *testing.T) {
 			}))
 			defer server.Close()
 
-			// Set Ethereum URL in configuration
-			config.Config.Ethereum.Url = server.URL
+			client := ethereumrpcclient.NewEthereumRPCClient(server.URL, nil)
 
 			// Call GetBlockByNumber and capture panic if any
 			defer func() {
@@ -306,7 +317,7 @@ func TestGetBlockByNumber(t *testing.T) {
 				}
 			}()
 
-			block, err := ethereumrpcclient.GetBlockByNumber(c.blockNumber)
+			block, err := client.GetBlockByNumber(context.Background(), c.blockNumber)
 
 			if c.expectedErr != nil {
 				assert.Error(t, err, "Expected an error")
@@ -318,3 +329,163 @@ func TestGetBlockByNumber(t *testing.T) {
 		})
 	}
 }
+
+func TestGetBlockByHash(t *testing.T) {
+	cases := []struct {
+		name        string
+		response    string
+		expected    *ethereumrpcclient.Block
+		expectedErr error
+	}{
+		{
+			name:     "Valid block",
+			response: `{"jsonrpc":"2.0","result":{"number":"0x1","hash":"0x123"},"id":1}`,
+			expected: &ethereumrpcclient.Block{Number: "0x1", Hash: "0x123"},
+		},
+		{
+			name:        "Unknown hash",
+			response:    `{"jsonrpc":"2.0","result":null,"id":1}`,
+			expectedErr: ethereumrpcclient.ErrBlockNotFound,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var request ethereumrpcclient.JSONRPCRequest
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				json.NewDecoder(r.Body).Decode(&request)
+
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(c.response))
+			}))
+			defer server.Close()
+
+			client := ethereumrpcclient.NewEthereumRPCClient(server.URL, nil)
+
+			block, err := client.GetBlockByHash(context.Background(), "0x123")
+
+			assert.Equal(t, "eth_getBlockByHash", request.Method)
+			assert.Equal(t, []interface{}{"0x123", true}, request.Params, "Transactions should be requested")
+			if c.expectedErr != nil {
+				assert.ErrorIs(t, err, c.expectedErr, "Unexpected error")
+			} else {
+				assert.NoError(t, err, "Unexpected error")
+				assert.Equal(t, c.expected, block, "Block does not match expected")
+			}
+		})
+	}
+}
+
+func TestGetBlockNumberByTag(t *testing.T) {
+	cases := []struct {
+		name        string
+		response    string
+		expected    int
+		expectedErr error
+	}{
+		{
+			name:     "Valid block",
+			response: `{"jsonrpc":"2.0","result":{"number":"0x10","hash":"0x123"},"id":1}`,
+			expected: 16,
+		},
+		{
+			name:        "Tag not supported",
+			response:    `{"jsonrpc":"2.0","error":{"code":-32000,"message":"finalized block not found"},"id":1}`,
+			expectedErr: errors.New("error getting finalized block, finalized block not found"),
+		},
+		{
+			name:        "Null block",
+			response:    `{"jsonrpc":"2.0","result":null,"id":1}`,
+			expectedErr: errors.New("finalized block is not available"),
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var params []interface{}
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var request ethereumrpcclient.JSONRPCRequest
+				json.NewDecoder(r.Body).Decode(&request)
+				params = request.Params
+
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(c.response))
+			}))
+			defer server.Close()
+
+			client := ethereumrpcclient.NewEthereumRPCClient(server.URL, nil)
+
+			blockNumber, err := client.GetBlockNumberByTag(context.Background(), ethereumrpcclient.TagFinalized)
+
+			assert.Equal(t, []interface{}{"finalized", false}, params, "Tag should be sent as the block parameter")
+			if c.expectedErr != nil {
+				assert.EqualError(t, err, c.expectedErr.Error(), "Unexpected error")
+			} else {
+				assert.NoError(t, err, "Unexpected error")
+				assert.Equal(t, c.expected, blockNumber, "Block number does not match expected")
+			}
+		})
+	}
+}
+
+func TestNewEthereumRPCClientFromConfig(t *testing.T) {
+	config.Config.Ethereum = config.Ethereum{Url: "ethereum-rpc-url", RequestTimeout: 5}
+
+	client := ethereumrpcclient.NewEthereumRPCClientFromConfig()
+
+	assert.Equal(t, "ethereum-rpc-url", client.Url, "Url should come from config")
+	assert.Equal(t, 5*time.Second, client.HttpClient.Timeout, "Timeout should come from config")
+
+	config.Config.Ethereum = config.Ethereum{Url: "ethereum-rpc-url"}
+
+	client = ethereumrpcclient.NewEthereumRPCClientFromConfig()
+
+	assert.Equal(t, ethereumrpcclient.DefaultTimeout, client.HttpClient.Timeout, "Timeout should default when unset")
+}
+
+func TestEthereumRPCClient_RequestID(t *testing.T) {
+	var ids []int
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request ethereumrpcclient.JSONRPCRequest
+		json.NewDecoder(r.Body).Decode(&request)
+		ids = append(ids, request.ID)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"jsonrpc":"2.0","result":"0x1","id":` + strconv.Itoa(request.ID) + `}`))
+	}))
+	defer server.Close()
+
+	client := ethereumrpcclient.NewEthereumRPCClient(server.URL, nil)
+
+	for i := 0; i < 3; i++ {
+		_, err := client.CallJSONRPC(context.Background(), "eth_blockNumber", []interface{}{})
+		assert.NoError(t, err, "Unexpected error")
+	}
+
+	assert.Equal(t, []int{1, 2, 3}, ids, "Request IDs should increment")
+}
+
+func TestEthereumRPCClient_Context(t *testing.T) {
+	release := make(chan struct{})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client := ethereumrpcclient.NewEthereumRPCClient(server.URL, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := client.CallJSONRPC(ctx, "eth_blockNumber", []interface{}{})
+
+	assert.Error(t, err, "Expected an error")
+	assert.ErrorContains(t, err, "context deadline exceeded", "Slow call should be cancelled")
+}
@@ -0,0 +1,529 @@
+package ethereumrpcclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"ethereum-parser/config"
+	"ethereum-parser/logger"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultMaxHeadLag          = 5
+	DefaultHealthCheckInterval = 30 * time.Second
+
+	// Weight of the newest sample in the latency and error rate averages
+	healthSmoothing = 0.2
+)
+
+var ErrNoProviders = errors.New("no healthy providers available")
+
+// Provider is one endpoint of a ProviderPool with its health statistics.
+type Provider struct {
+	Client *EthereumRPCClient
+	Weight int
+
+	mutex     sync.Mutex
+	latency   time.Duration
+	errorRate float64
+	head      int
+	// chainID is 0 until the endpoint has answered eth_chainId
+	chainID int
+}
+
+// ProviderHealth is a snapshot of the health statistics of a provider.
+type ProviderHealth struct {
+	Url       string
+	Weight    int
+	Latency   time.Duration
+	ErrorRate float64
+	HeadLag   int
+	ChainID   int
+	Score     float64
+}
+
+// record folds the outcome of a call into the provider statistics.
+func (p *Provider) record(latency time.Duration, err error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	failed := 0.0
+	if err != nil && IsRetryable(err) {
+		failed = 1
+	}
+	p.errorRate += healthSmoothing * (failed - p.errorRate)
+
+	if err == nil {
+		if p.latency == 0 {
+			p.latency = latency
+		} else {
+			p.latency += time.Duration(healthSmoothing * float64(latency-p.latency))
+		}
+	}
+}
+
+// ProviderPool spreads calls over several endpoints of the same chain,
+// preferring the healthiest one and failing over to the next on transient
+// errors. Endpoints that report a different chain id are never used.
+type ProviderPool struct {
+	Providers   []*Provider
+	ChainID     int
+	MaxHeadLag  int
+	RetryPolicy RetryPolicy
+
+	mutex sync.Mutex
+	head  int
+}
+
+var _ RPCClient = (*ProviderPool)(nil)
+
+// NewProviderPool creates a pool over providers. With chainID 0 the pool
+// adopts the chain id of the first endpoint that answers.
+func NewProviderPool(chainID int, providers ...*Provider) *ProviderPool {
+	for _, provider := range providers {
+		if provider.Weight <= 0 {
+			provider.Weight = 1
+		}
+	}
+
+	return &ProviderPool{
+		Providers:   providers,
+		ChainID:     chainID,
+		MaxHeadLag:  DefaultMaxHeadLag,
+		RetryPolicy: NoRetry,
+	}
+}
+
+// NewProviderPoolFromConfig builds a pool from the [[ethereum.providers]]
+// entries, falling back to the single ethereum url.
+func NewProviderPoolFromConfig() *ProviderPool {
+	ethereumConfig := config.GetConfig().Ethereum
+
+	providerConfigs := ethereumConfig.Providers
+	if len(providerConfigs) == 0 && ethereumConfig.Url != "" {
+		providerConfigs = []config.Provider{{Url: ethereumConfig.Url, Weight: 1}}
+	}
+
+	providers := make([]*Provider, 0, len(providerConfigs))
+	for _, providerConfig := range providerConfigs {
+		client := newEthereumRPCClientFromConfig(ethereumConfig, providerConfig.Url)
+		// The pool retries across providers instead
+		client.RetryPolicy = NoRetry
+
+		providers = append(providers, &Provider{Client: client, Weight: providerConfig.Weight})
+	}
+
+	pool := NewProviderPool(ethereumConfig.ChainID, providers...)
+	pool.RetryPolicy = retryPolicyFromConfig(ethereumConfig)
+	if ethereumConfig.MaxHeadLag > 0 {
+		pool.MaxHeadLag = ethereumConfig.MaxHeadLag
+	}
+
+	return pool
+}
+
+// CheckHealth verifies the chain id of every provider and samples its latency
+// and head block.
+func (p *ProviderPool) CheckHealth(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, provider := range p.Providers {
+		wg.Add(1)
+		go func(provider *Provider) {
+			defer wg.Done()
+			p.checkProvider(ctx, provider)
+		}(provider)
+	}
+	wg.Wait()
+}
+
+// StartHealthChecks runs CheckHealth every interval until ctx is cancelled.
+func (p *ProviderPool) StartHealthChecks(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultHealthCheckInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.CheckHealth(ctx)
+		}
+	}
+}
+
+func (p *ProviderPool) checkProvider(ctx context.Context, provider *Provider) {
+	if !p.verifyChainID(ctx, provider) {
+		return
+	}
+
+	start := time.Now()
+	head, err := provider.Client.GetBlockNumber(ctx)
+	provider.record(time.Since(start), err)
+	if err != nil {
+		logger.Logger.Warn("Provider " + provider.Client.Url + " health check failed, " + err.Error())
+		return
+	}
+
+	provider.mutex.Lock()
+	provider.head = head
+	provider.mutex.Unlock()
+
+	p.mutex.Lock()
+	p.head = max(p.head, head)
+	p.mutex.Unlock()
+}
+
+// verifyChainID asks the provider for its chain id once and reports whether it
+// may serve requests.
+func (p *ProviderPool) verifyChainID(ctx context.Context, provider *Provider) bool {
+	provider.mutex.Lock()
+	chainID := provider.chainID
+	provider.mutex.Unlock()
+
+	if chainID == 0 {
+		start := time.Now()
+		id, err := provider.Client.GetChainID(ctx)
+		provider.record(time.Since(start), err)
+		if err != nil {
+			return false
+		}
+
+		provider.mutex.Lock()
+		provider.chainID = id
+		provider.mutex.Unlock()
+		chainID = id
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.ChainID == 0 {
+		p.ChainID = chainID
+	}
+
+	if chainID != p.ChainID {
+		logger.Logger.Error("Provider " + provider.Client.Url + " is on chain " + strconv.Itoa(chainID) +
+			", expected " + strconv.Itoa(p.ChainID))
+		return false
+	}
+
+	return true
+}
+
+// Health returns the statistics of every provider, best score first.
+func (p *ProviderPool) Health() []ProviderHealth {
+	p.mutex.Lock()
+	poolHead := p.head
+	chainID := p.ChainID
+	p.mutex.Unlock()
+
+	health := make([]ProviderHealth, len(p.Providers))
+	for i, provider := range p.Providers {
+		provider.mutex.Lock()
+		health[i] = ProviderHealth{
+			Url:       provider.Client.Url,
+			Weight:    provider.Weight,
+			Latency:   provider.latency,
+			ErrorRate: provider.errorRate,
+			ChainID:   provider.chainID,
+		}
+		if provider.head > 0 {
+			health[i].HeadLag = max(poolHead-provider.head, 0)
+		}
+		provider.mutex.Unlock()
+
+		if health[i].ChainID == 0 || health[i].ChainID == chainID || chainID == 0 {
+			health[i].Score = score(health[i])
+		}
+	}
+
+	sort.SliceStable(health, func(i, j int) bool {
+		return health[i].Score > health[j].Score
+	})
+
+	return health
+}
+
+// score rates a provider by its weight, penalized by latency, error rate and
+// how far its head is behind the best known head.
+func score(health ProviderHealth) float64 {
+	latencyPenalty := 1 + float64(health.Latency.Milliseconds())/100
+	errorPenalty := 1 + 10*health.ErrorRate
+	lagPenalty := 1 + float64(health.HeadLag)
+
+	return float64(health.Weight) / (latencyPenalty * errorPenalty * lagPenalty)
+}
+
+// ranked returns the providers to try in order: healthy ones by score, then
+// those lagging more than MaxHeadLag. Providers on the wrong chain are left out.
+func (p *ProviderPool) ranked() []*Provider {
+	p.mutex.Lock()
+	poolHead := p.head
+	chainID := p.ChainID
+	p.mutex.Unlock()
+
+	type candidate struct {
+		provider *Provider
+		lagging  bool
+		score    float64
+	}
+
+	candidates := make([]candidate, 0, len(p.Providers))
+	for _, provider := range p.Providers {
+		provider.mutex.Lock()
+		health := ProviderHealth{
+			Weight:    provider.Weight,
+			Latency:   provider.latency,
+			ErrorRate: provider.errorRate,
+			ChainID:   provider.chainID,
+		}
+		if provider.head > 0 {
+			health.HeadLag = max(poolHead-provider.head, 0)
+		}
+		provider.mutex.Unlock()
+
+		if health.ChainID != 0 && chainID != 0 && health.ChainID != chainID {
+			continue
+		}
+
+		candidates = append(candidates, candidate{
+			provider: provider,
+			lagging:  health.HeadLag > p.MaxHeadLag,
+			score:    score(health),
+		})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].lagging != candidates[j].lagging {
+			return !candidates[i].lagging
+		}
+		return candidates[i].score > candidates[j].score
+	})
+
+	providers := make([]*Provider, len(candidates))
+	for i, c := range candidates {
+		providers[i] = c.provider
+	}
+
+	return providers
+}
+
+// do runs call against the providers in ranked order, moving on to the next
+// one on transient errors. Results a provider has no data for move on to the
+// next provider too, as it may only lag behind the others, and so do methods a
+// provider does not support, but neither is retried unless another provider
+// failed. Full rounds are retried according to
+// RetryPolicy.
+func (p *ProviderPool) do(ctx context.Context, call func(client *EthereumRPCClient) error) error {
+	attempts := max(p.RetryPolicy.MaxAttempts, 1)
+
+	err := ErrNoProviders
+	for attempt := 1; attempt <= attempts; attempt++ {
+		var notFound, unsupported error
+		failed := false
+		for _, provider := range p.ranked() {
+			if !p.verifyChainID(ctx, provider) {
+				continue
+			}
+
+			start := time.Now()
+			err = call(provider.Client)
+
+			if errors.Is(err, ErrNotFound) {
+				// The provider answered, it is not a failure
+				provider.record(time.Since(start), nil)
+				notFound = err
+				logger.Logger.Debug("Provider " + provider.Client.Url + " has no result, " + err.Error())
+				continue
+			}
+
+			if IsUnsupported(err) {
+				// The provider answered, another one may support the method
+				provider.record(time.Since(start), nil)
+				unsupported = err
+				logger.Logger.Debug("Provider " + provider.Client.Url + " does not support the method, " + err.Error())
+				continue
+			}
+
+			provider.record(time.Since(start), err)
+
+			if err == nil || !IsRetryable(err) {
+				return err
+			}
+
+			failed = true
+			logger.Logger.Warn("Provider " + provider.Client.Url + " failed, " + err.Error())
+		}
+
+		if notFound != nil && !failed {
+			return notFound
+		}
+		if unsupported != nil && !failed {
+			return unsupported
+		}
+
+		if attempt == attempts {
+			break
+		}
+
+		if err := sleep(ctx, p.RetryPolicy.delay(attempt, err)); err != nil {
+			return err
+		}
+	}
+
+	return err
+}
+
+func (p *ProviderPool) CallJSONRPC(ctx context.Context, method string, params []interface{}) (json.RawMessage, error) {
+	var result json.RawMessage
+	err := p.do(ctx, func(client *EthereumRPCClient) error {
+		var err error
+		result, err = client.CallJSONRPC(ctx, method, params)
+		return err
+	})
+
+	return result, err
+}
+
+func (p *ProviderPool) BatchCallJSONRPC(ctx context.Context, elems []BatchElem) error {
+	return p.do(ctx, func(client *EthereumRPCClient) error {
+		return client.BatchCallJSONRPC(ctx, elems)
+	})
+}
+
+// GetChainID returns the chain id the pool is bound to.
+func (p *ProviderPool) GetChainID(ctx context.Context) (int, error) {
+	p.mutex.Lock()
+	chainID := p.ChainID
+	p.mutex.Unlock()
+
+	if chainID != 0 {
+		return chainID, nil
+	}
+
+	err := p.do(ctx, func(client *EthereumRPCClient) error {
+		var err error
+		chainID, err = client.GetChainID(ctx)
+		return err
+	})
+
+	return chainID, err
+}
+
+func (p *ProviderPool) GetBlockNumber(ctx context.Context) (int, error) {
+	var blockNumber int
+	err := p.do(ctx, func(client *EthereumRPCClient) error {
+		var err error
+		blockNumber, err = client.GetBlockNumber(ctx)
+		return err
+	})
+
+	return blockNumber, err
+}
+
+func (p *ProviderPool) GetBlockByNumber(ctx context.Context, blockNumber int) (*Block, error) {
+	var block *Block
+	err := p.do(ctx, func(client *EthereumRPCClient) error {
+		var err error
+		block, err = client.GetBlockByNumber(ctx, blockNumber)
+		return err
+	})
+
+	return block, err
+}
+
+func (p *ProviderPool) GetBlockByHash(ctx context.Context, hash string) (*Block, error) {
+	var block *Block
+	err := p.do(ctx, func(client *EthereumRPCClient) error {
+		var err error
+		block, err = client.GetBlockByHash(ctx, hash)
+		return err
+	})
+
+	return block, err
+}
+
+func (p *ProviderPool) GetBlockNumberByTag(ctx context.Context, tag string) (int, error) {
+	var blockNumber int
+	err := p.do(ctx, func(client *EthereumRPCClient) error {
+		var err error
+		blockNumber, err = client.GetBlockNumberByTag(ctx, tag)
+		return err
+	})
+
+	return blockNumber, err
+}
+
+func (p *ProviderPool) GetBlocksByRange(ctx context.Context, from int, to int) ([]*Block, error) {
+	var blocks []*Block
+	err := p.do(ctx, func(client *EthereumRPCClient) error {
+		var err error
+		blocks, err = client.GetBlocksByRange(ctx, from, to)
+		return err
+	})
+
+	return blocks, err
+}
+
+func (p *ProviderPool) GetTransactionReceipt(ctx context.Context, hash string) (*Receipt, error) {
+	var receipt *Receipt
+	err := p.do(ctx, func(client *EthereumRPCClient) error {
+		var err error
+		receipt, err = client.GetTransactionReceipt(ctx, hash)
+		return err
+	})
+
+	return receipt, err
+}
+
+func (p *ProviderPool) GetTransactionReceipts(ctx context.Context, hashes []string) ([]*Receipt, error) {
+	var receipts []*Receipt
+	err := p.do(ctx, func(client *EthereumRPCClient) error {
+		var err error
+		receipts, err = client.GetTransactionReceipts(ctx, hashes)
+		return err
+	})
+
+	return receipts, err
+}
+
+func (p *ProviderPool) GetBlockReceipts(ctx context.Context, blockNumber int) ([]*Receipt, error) {
+	var receipts []*Receipt
+	err := p.do(ctx, func(client *EthereumRPCClient) error {
+		var err error
+		receipts, err = client.GetBlockReceipts(ctx, blockNumber)
+		return err
+	})
+
+	return receipts, err
+}
+
+func (p *ProviderPool) GetBlockReceiptsByRange(ctx context.Context, from int, to int) ([][]*Receipt, error) {
+	var receipts [][]*Receipt
+	err := p.do(ctx, func(client *EthereumRPCClient) error {
+		var err error
+		receipts, err = client.GetBlockReceiptsByRange(ctx, from, to)
+		return err
+	})
+
+	return receipts, err
+}
+
+func (p *ProviderPool) GetInternalTransactions(ctx context.Context, blockNumber int, blockHash string, tracer string) ([][]InternalTransaction, error) {
+	var internalTxs [][]InternalTransaction
+	err := p.do(ctx, func(client *EthereumRPCClient) error {
+		var err error
+		internalTxs, err = client.GetInternalTransactions(ctx, blockNumber, blockHash, tracer)
+		return err
+	})
+
+	return internalTxs, err
+}
@@ -0,0 +1,255 @@
+package ethereumrpcclient_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"ethereum-parser/logger"
+	ethereumrpcclient "ethereum-parser/pkg/ethereum-rpc-client"
+)
+
+// newNodeServer serves eth_chainId and eth_blockNumber for a node on chainID at
+// head, counting the eth_blockNumber calls it receives.
+func newNodeServer(chainID int, head int, delay time.Duration, calls *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request ethereumrpcclient.JSONRPCRequest
+		json.NewDecoder(r.Body).Decode(&request)
+
+		time.Sleep(delay)
+
+		id := strconv.Itoa(request.ID)
+		switch request.Method {
+		case "eth_chainId":
+			w.Write([]byte(`{"jsonrpc":"2.0","result":"0x` + strconv.FormatInt(int64(chainID), 16) + `","id":` + id + `}`))
+		case "eth_blockNumber":
+			if calls != nil {
+				atomic.AddInt32(calls, 1)
+			}
+			w.Write([]byte(`{"jsonrpc":"2.0","result":"0x` + strconv.FormatInt(int64(head), 16) + `","id":` + id + `}`))
+		}
+	}))
+}
+
+func newProvider(url string, weight int) *ethereumrpcclient.Provider {
+	return &ethereumrpcclient.Provider{Client: ethereumrpcclient.NewEthereumRPCClient(url, nil), Weight: weight}
+}
+
+func TestProviderPool_ChainMismatch(t *testing.T) {
+	logger.Logger = zap.NewNop()
+
+	var wrongCalls, rightCalls int32
+	wrongChain := newNodeServer(5, 100, 0, &wrongCalls)
+	defer wrongChain.Close()
+	rightChain := newNodeServer(1, 100, 0, &rightCalls)
+	defer rightChain.Close()
+
+	pool := ethereumrpcclient.NewProviderPool(1, newProvider(wrongChain.URL, 10), newProvider(rightChain.URL, 1))
+
+	for i := 0; i < 3; i++ {
+		blockNumber, err := pool.GetBlockNumber(context.Background())
+		assert.NoError(t, err, "Unexpected error")
+		assert.Equal(t, 100, blockNumber)
+	}
+
+	assert.Equal(t, int32(0), atomic.LoadInt32(&wrongCalls), "Provider on another chain should never be used")
+	assert.Equal(t, int32(3), atomic.LoadInt32(&rightCalls))
+
+	pool = ethereumrpcclient.NewProviderPool(1, newProvider(wrongChain.URL, 1))
+	_, err := pool.GetBlockNumber(context.Background())
+	assert.ErrorIs(t, err, ethereumrpcclient.ErrNoProviders)
+}
+
+func TestProviderPool_Failover(t *testing.T) {
+	logger.Logger = zap.NewNop()
+
+	var failures int32
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request ethereumrpcclient.JSONRPCRequest
+		json.NewDecoder(r.Body).Decode(&request)
+		if request.Method == "eth_chainId" {
+			w.Write([]byte(`{"jsonrpc":"2.0","result":"0x1","id":` + strconv.Itoa(request.ID) + `}`))
+			return
+		}
+		atomic.AddInt32(&failures, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+	healthy := newNodeServer(1, 200, 0, nil)
+	defer healthy.Close()
+
+	pool := ethereumrpcclient.NewProviderPool(1, newProvider(failing.URL, 2), newProvider(healthy.URL, 1))
+
+	blockNumber, err := pool.GetBlockNumber(context.Background())
+
+	assert.NoError(t, err, "Call should fail over to the next provider")
+	assert.Equal(t, 200, blockNumber)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&failures))
+
+	// The failing provider has lost its lead after the error
+	_, err = pool.GetBlockNumber(context.Background())
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&failures), "Unhealthy provider should be ranked last")
+}
+
+func TestProviderPool_RetryAfterLimit(t *testing.T) {
+	logger.Logger = zap.NewNop()
+
+	var attempts int32
+	limited := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request ethereumrpcclient.JSONRPCRequest
+		json.NewDecoder(r.Body).Decode(&request)
+
+		id := strconv.Itoa(request.ID)
+		if request.Method == "eth_blockNumber" && atomic.AddInt32(&attempts, 1) == 1 {
+			w.Header().Set("Retry-After", "3600")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(`{"jsonrpc":"2.0","result":"0x1","id":` + id + `}`))
+	}))
+	defer limited.Close()
+
+	pool := ethereumrpcclient.NewProviderPool(1, newProvider(limited.URL, 1))
+	pool.RetryPolicy = ethereumrpcclient.RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond, MaxBackoff: 20 * time.Millisecond}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	_, err := pool.GetBlockNumber(ctx)
+
+	assert.NoError(t, err, "Retry-After should be bounded by MaxBackoff")
+	assert.Equal(t, int32(2), atomic.LoadInt32(&attempts))
+}
+
+// newBlockServer answers eth_getBlockByNumber with block, null for a node that
+// does not have it, counting the calls it receives.
+func newBlockServer(block string, calls *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request ethereumrpcclient.JSONRPCRequest
+		json.NewDecoder(r.Body).Decode(&request)
+
+		id := strconv.Itoa(request.ID)
+		switch request.Method {
+		case "eth_chainId":
+			w.Write([]byte(`{"jsonrpc":"2.0","result":"0x1","id":` + id + `}`))
+		case "eth_getBlockByNumber":
+			atomic.AddInt32(calls, 1)
+			w.Write([]byte(`{"jsonrpc":"2.0","result":` + block + `,"id":` + id + `}`))
+		}
+	}))
+}
+
+func TestProviderPool_LaggingProvider(t *testing.T) {
+	logger.Logger = zap.NewNop()
+
+	var laggingCalls, syncedCalls int32
+	lagging := newBlockServer("null", &laggingCalls)
+	defer lagging.Close()
+	synced := newBlockServer(`{"number":"0x64","hash":"0xaa","parentHash":"0x99","transactions":[]}`, &syncedCalls)
+	defer synced.Close()
+
+	pool := ethereumrpcclient.NewProviderPool(1, newProvider(lagging.URL, 2), newProvider(synced.URL, 1))
+	pool.RetryPolicy = ethereumrpcclient.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+
+	block, err := pool.GetBlockByNumber(context.Background(), 100)
+
+	assert.NoError(t, err, "Call should fail over to a provider with the block")
+	assert.Equal(t, "0xaa", block.Hash)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&laggingCalls), "Missing block should not be retried on the same provider")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&syncedCalls))
+
+	for _, health := range pool.Health() {
+		assert.Zero(t, health.ErrorRate, "Missing block should not count as a failure")
+	}
+
+	pool = ethereumrpcclient.NewProviderPool(1, newProvider(lagging.URL, 1))
+	pool.RetryPolicy = ethereumrpcclient.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+
+	_, err = pool.GetBlockByNumber(context.Background(), 100)
+
+	assert.ErrorIs(t, err, ethereumrpcclient.ErrBlockNotFound)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&laggingCalls), "Blocks no provider has should not be retried")
+}
+
+func TestProviderPool_UnsupportedMethod(t *testing.T) {
+	logger.Logger = zap.NewNop()
+
+	var unsupportedCalls int32
+	unsupported := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request ethereumrpcclient.JSONRPCRequest
+		json.NewDecoder(r.Body).Decode(&request)
+
+		id := strconv.Itoa(request.ID)
+		if request.Method == "eth_chainId" {
+			w.Write([]byte(`{"jsonrpc":"2.0","result":"0x1","id":` + id + `}`))
+			return
+		}
+		atomic.AddInt32(&unsupportedCalls, 1)
+		w.Write([]byte(`{"jsonrpc":"2.0","error":{"code":-32601,"message":"the method debug_traceBlockByHash does not exist/is not available"},"id":` + id + `}`))
+	}))
+	defer unsupported.Close()
+	tracing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request ethereumrpcclient.JSONRPCRequest
+		json.NewDecoder(r.Body).Decode(&request)
+
+		id := strconv.Itoa(request.ID)
+		if request.Method == "eth_chainId" {
+			w.Write([]byte(`{"jsonrpc":"2.0","result":"0x1","id":` + id + `}`))
+			return
+		}
+		w.Write([]byte(`{"jsonrpc":"2.0","result":[],"id":` + id + `}`))
+	}))
+	defer tracing.Close()
+
+	pool := ethereumrpcclient.NewProviderPool(1, newProvider(unsupported.URL, 2), newProvider(tracing.URL, 1))
+	pool.RetryPolicy = ethereumrpcclient.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+
+	_, err := pool.CallJSONRPC(context.Background(), "debug_traceBlockByHash", []interface{}{"0xaa"})
+
+	assert.NoError(t, err, "Call should fail over to a provider supporting the method")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&unsupportedCalls))
+
+	pool = ethereumrpcclient.NewProviderPool(1, newProvider(unsupported.URL, 1))
+	pool.RetryPolicy = ethereumrpcclient.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+
+	_, err = pool.CallJSONRPC(context.Background(), "debug_traceBlockByHash", []interface{}{"0xaa"})
+
+	assert.True(t, ethereumrpcclient.IsUnsupported(err), "Unsupported methods should be returned")
+	assert.Equal(t, int32(2), atomic.LoadInt32(&unsupportedCalls), "Unsupported methods should not be retried")
+}
+
+func TestProviderPool_CheckHealth(t *testing.T) {
+	logger.Logger = zap.NewNop()
+
+	var slowCalls, laggingCalls, fastCalls int32
+	slow := newNodeServer(1, 100, 50*time.Millisecond, &slowCalls)
+	defer slow.Close()
+	lagging := newNodeServer(1, 80, 0, &laggingCalls)
+	defer lagging.Close()
+	fast := newNodeServer(1, 100, 0, &fastCalls)
+	defer fast.Close()
+
+	pool := ethereumrpcclient.NewProviderPool(0,
+		newProvider(slow.URL, 1), newProvider(lagging.URL, 1), newProvider(fast.URL, 1))
+
+	pool.CheckHealth(context.Background())
+
+	health := pool.Health()
+	assert.Equal(t, 1, pool.ChainID, "Pool should adopt the chain id of its providers")
+	assert.Equal(t, fast.URL, health[0].Url, "Fastest provider should have the best score")
+	assert.Equal(t, lagging.URL, health[2].Url, "Lagging provider should have the worst score")
+	assert.Equal(t, 20, health[2].HeadLag)
+
+	_, err := pool.GetBlockNumber(context.Background())
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, int32(2), atomic.LoadInt32(&fastCalls), "Healthiest provider should serve the call")
+}
@@ -0,0 +1,260 @@
+package ethereumrpcclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"ethereum-parser/util"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	ReceiptStatusFailed  = "0x0"
+	ReceiptStatusSuccess = "0x1"
+
+	// receiptBatchSize is the number of transaction receipts requested per
+	// batch when nodes cannot return the receipts of whole blocks
+	receiptBatchSize = 100
+)
+
+// Receipt is the outcome of an executed transaction as returned by
+// eth_getTransactionReceipt.
+type Receipt struct {
+	TransactionHash   string
+	TransactionIndex  string
+	BlockHash         string
+	BlockNumber       string
+	From              string
+	To                string
+	Type              string `json:",omitempty"`
+	Status            string
+	GasUsed           string
+	CumulativeGasUsed string
+	EffectiveGasPrice string
+	// ContractAddress is set when the transaction created a contract
+	ContractAddress string `json:",omitempty"`
+	BlobGasUsed     string `json:",omitempty"`
+	BlobGasPrice    string `json:",omitempty"`
+	Logs            []Log
+}
+
+// MarshalJSON writes the addresses of the receipt with their EIP-55 checksum.
+func (r Receipt) MarshalJSON() ([]byte, error) {
+	type receipt Receipt
+	r.From = util.ChecksumAddress(r.From)
+	r.To = util.ChecksumAddress(r.To)
+	r.ContractAddress = util.ChecksumAddress(r.ContractAddress)
+	return json.Marshal(receipt(r))
+}
+
+// Log is an event emitted during the execution of a transaction.
+type Log struct {
+	Address          string
+	Topics           []string
+	Data             string
+	BlockNumber      string
+	BlockHash        string
+	TransactionHash  string
+	TransactionIndex string
+	LogIndex         string
+	Removed          bool
+}
+
+// MarshalJSON writes the emitting contract with its EIP-55 checksum.
+func (l Log) MarshalJSON() ([]byte, error) {
+	type log Log
+	l.Address = util.ChecksumAddress(l.Address)
+	return json.Marshal(log(l))
+}
+
+// Succeeded reports whether the transaction executed without reverting.
+func (r *Receipt) Succeeded() bool {
+	return r.Status == ReceiptStatusSuccess
+}
+
+func (c *EthereumRPCClient) GetTransactionReceipt(ctx context.Context, hash string) (*Receipt, error) {
+	result, err := c.CallJSONRPC(ctx, "eth_getTransactionReceipt", []interface{}{hash})
+	if err != nil {
+		return nil, fmt.Errorf("error getting transaction receipt, %w", err)
+	}
+
+	if len(result) == 0 || string(result) == "null" {
+		return nil, notFoundError("receipt for " + hash + " not found")
+	}
+
+	var receipt Receipt
+	if err := json.Unmarshal(result, &receipt); err != nil {
+		return nil, errors.New("error unmarshalling receipt, " + err.Error())
+	}
+
+	return &receipt, nil
+}
+
+// GetTransactionReceipts fetches the receipts of hashes with a single batch
+// request, in the same order.
+func (c *EthereumRPCClient) GetTransactionReceipts(ctx context.Context, hashes []string) ([]*Receipt, error) {
+	elems := make([]BatchElem, len(hashes))
+	for i, hash := range hashes {
+		elems[i] = BatchElem{Method: "eth_getTransactionReceipt", Params: []interface{}{hash}}
+	}
+
+	if err := c.BatchCallJSONRPC(ctx, elems); err != nil {
+		return nil, fmt.Errorf("error getting transaction receipts, %w", err)
+	}
+
+	receipts := make([]*Receipt, len(elems))
+	for i, elem := range elems {
+		if elem.Error != nil {
+			return nil, fmt.Errorf("error getting receipt for %s, %w", hashes[i], elem.Error)
+		}
+
+		if len(elem.Result) == 0 || string(elem.Result) == "null" {
+			return nil, notFoundError("receipt for " + hashes[i] + " not found")
+		}
+
+		var receipt Receipt
+		if err := json.Unmarshal(elem.Result, &receipt); err != nil {
+			return nil, errors.New("error unmarshalling receipt for " + hashes[i] + ", " + err.Error())
+		}
+		receipts[i] = &receipt
+	}
+
+	return receipts, nil
+}
+
+func (c *EthereumRPCClient) GetBlockReceipts(ctx context.Context, blockNumber int) ([]*Receipt, error) {
+	result, err := c.CallJSONRPC(ctx, "eth_getBlockReceipts", []interface{}{"0x" + strconv.FormatInt(int64(blockNumber), 16)})
+	if err != nil {
+		return nil, fmt.Errorf("error getting block receipts, %w", err)
+	}
+
+	if len(result) == 0 || string(result) == "null" {
+		return nil, notFoundError("receipts for block " + strconv.Itoa(blockNumber) + " not found")
+	}
+
+	var receipts []*Receipt
+	if err := json.Unmarshal(result, &receipts); err != nil {
+		return nil, errors.New("error unmarshalling block receipts, " + err.Error())
+	}
+
+	return receipts, nil
+}
+
+// GetBlockReceiptsByRange fetches the receipts of blocks from..to inclusive
+// with a single batch request, one slice per block in ascending order.
+func (c *EthereumRPCClient) GetBlockReceiptsByRange(ctx context.Context, from int, to int) ([][]*Receipt, error) {
+	if from > to {
+		return nil, errors.New("invalid block range, " + strconv.Itoa(from) + " > " + strconv.Itoa(to))
+	}
+
+	elems := make([]BatchElem, 0, to-from+1)
+	for number := from; number <= to; number++ {
+		elems = append(elems, BatchElem{
+			Method: "eth_getBlockReceipts",
+			Params: []interface{}{"0x" + strconv.FormatInt(int64(number), 16)},
+		})
+	}
+
+	if err := c.BatchCallJSONRPC(ctx, elems); err != nil {
+		return nil, fmt.Errorf("error getting block receipts, %w", err)
+	}
+
+	receipts := make([][]*Receipt, len(elems))
+	for i, elem := range elems {
+		number := strconv.Itoa(from + i)
+
+		if elem.Error != nil {
+			return nil, fmt.Errorf("error getting receipts for block %s, %w", number, elem.Error)
+		}
+
+		if len(elem.Result) == 0 || string(elem.Result) == "null" {
+			return nil, notFoundError("receipts for block " + number + " not found")
+		}
+
+		if err := json.Unmarshal(elem.Result, &receipts[i]); err != nil {
+			return nil, errors.New("error unmarshalling receipts for block " + number + ", " + err.Error())
+		}
+	}
+
+	return receipts, nil
+}
+
+// AttachReceipts sets the receipt of every transaction in blocks, which must be
+// consecutive and in ascending order. Nodes without eth_getBlockReceipts are
+// asked for each transaction receipt instead.
+func AttachReceipts(ctx context.Context, client RPCClient, blocks []*Block) error {
+	if len(blocks) == 0 {
+		return nil
+	}
+
+	from, err := strconv.ParseInt(strings.TrimPrefix(blocks[0].Number, "0x"), 16, 64)
+	if err != nil {
+		return errors.New("error parsing block number, " + err.Error())
+	}
+
+	receipts, err := client.GetBlockReceiptsByRange(ctx, int(from), int(from)+len(blocks)-1)
+
+	var rpcErr *RPCError
+	if errors.As(err, &rpcErr) && rpcErr.Code == ErrCodeMethodNotFound {
+		receipts, err = getReceiptsByTransaction(ctx, client, blocks)
+	}
+	if err != nil {
+		return err
+	}
+
+	for i, block := range blocks {
+		if err := block.attachReceipts(receipts[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func getReceiptsByTransaction(ctx context.Context, client RPCClient, blocks []*Block) ([][]*Receipt, error) {
+	var hashes []string
+	for _, block := range blocks {
+		for _, tx := range block.Transactions {
+			hashes = append(hashes, tx.Hash)
+		}
+	}
+
+	if len(hashes) == 0 {
+		return make([][]*Receipt, len(blocks)), nil
+	}
+
+	flat := make([]*Receipt, 0, len(hashes))
+	for start := 0; start < len(hashes); start += receiptBatchSize {
+		batch, err := client.GetTransactionReceipts(ctx, hashes[start:min(start+receiptBatchSize, len(hashes))])
+		if err != nil {
+			return nil, err
+		}
+		flat = append(flat, batch...)
+	}
+
+	receipts := make([][]*Receipt, len(blocks))
+	for i, block := range blocks {
+		receipts[i], flat = flat[:len(block.Transactions)], flat[len(block.Transactions):]
+	}
+
+	return receipts, nil
+}
+
+func (b *Block) attachReceipts(receipts []*Receipt) error {
+	byHash := make(map[string]*Receipt, len(receipts))
+	for _, receipt := range receipts {
+		byHash[strings.ToLower(receipt.TransactionHash)] = receipt
+	}
+
+	for i := range b.Transactions {
+		receipt, ok := byHash[strings.ToLower(b.Transactions[i].Hash)]
+		if !ok {
+			return errors.New("missing receipt for transaction " + b.Transactions[i].Hash)
+		}
+		b.Transactions[i].Receipt = receipt
+	}
+
+	return nil
+}
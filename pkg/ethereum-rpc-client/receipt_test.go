@@ -0,0 +1,201 @@
+package ethereumrpcclient_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	ethereumrpcclient "ethereum-parser/pkg/ethereum-rpc-client"
+)
+
+const receiptJSON = `{"transactionHash":"0xabc","blockNumber":"0x1","status":"0x1","gasUsed":"0x5208",
+	"effectiveGasPrice":"0x3b9aca00","contractAddress":null,
+	"logs":[{"address":"0xtoken","topics":["0xddf2"],"data":"0x01","logIndex":"0x0"}]}`
+
+func TestGetTransactionReceipt(t *testing.T) {
+	cases := []struct {
+		name        string
+		response    string
+		expected    *ethereumrpcclient.Receipt
+		expectedErr string
+	}{
+		{
+			name:     "Receipt",
+			response: `{"jsonrpc":"2.0","result":` + receiptJSON + `,"id":1}`,
+			expected: &ethereumrpcclient.Receipt{
+				TransactionHash:   "0xabc",
+				BlockNumber:       "0x1",
+				Status:            ethereumrpcclient.ReceiptStatusSuccess,
+				GasUsed:           "0x5208",
+				EffectiveGasPrice: "0x3b9aca00",
+				Logs: []ethereumrpcclient.Log{
+					{Address: "0xtoken", Topics: []string{"0xddf2"}, Data: "0x01", LogIndex: "0x0"},
+				},
+			},
+		},
+		{
+			name:        "Pending transaction",
+			response:    `{"jsonrpc":"2.0","result":null,"id":1}`,
+			expectedErr: "receipt for 0xabc not found",
+		},
+		{
+			name:        "Error getting receipt",
+			response:    `{"jsonrpc":"2.0","error":{"code":-32000,"message":"unknown block"},"id":1}`,
+			expectedErr: "error getting transaction receipt, unknown block",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(c.response))
+			}))
+			defer server.Close()
+
+			client := ethereumrpcclient.NewEthereumRPCClient(server.URL, nil)
+
+			receipt, err := client.GetTransactionReceipt(context.Background(), "0xabc")
+
+			if c.expectedErr != "" {
+				assert.EqualError(t, err, c.expectedErr)
+			} else {
+				assert.NoError(t, err, "Unexpected error")
+				assert.Equal(t, c.expected, receipt, "Receipt does not match expected")
+				assert.True(t, receipt.Succeeded())
+			}
+		})
+	}
+}
+
+func TestGetBlockReceipts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"jsonrpc":"2.0","result":[` + receiptJSON + `],"id":1}`))
+	}))
+	defer server.Close()
+
+	client := ethereumrpcclient.NewEthereumRPCClient(server.URL, nil)
+
+	receipts, err := client.GetBlockReceipts(context.Background(), 1)
+
+	assert.NoError(t, err, "Unexpected error")
+	assert.Len(t, receipts, 1)
+	assert.Equal(t, "0xabc", receipts[0].TransactionHash)
+}
+
+func TestAttachReceipts(t *testing.T) {
+	receipt := func(hash string, status string) string {
+		return `{"transactionHash":"` + hash + `","status":"` + status + `","gasUsed":"0x1","logs":[]}`
+	}
+
+	newBlocks := func() []*ethereumrpcclient.Block {
+		return []*ethereumrpcclient.Block{
+			{Number: "0x1", Transactions: []ethereumrpcclient.Transaction{{Hash: "0xa1"}, {Hash: "0xa2"}}},
+			{Number: "0x2"},
+			{Number: "0x3", Transactions: []ethereumrpcclient.Transaction{{Hash: "0xc1"}}},
+		}
+	}
+
+	receiptsByBlock := map[string]string{
+		"0x1": `[` + receipt("0xa2", "0x0") + `,` + receipt("0xa1", "0x1") + `]`,
+		"0x2": `[]`,
+		"0x3": `[` + receipt("0xc1", "0x1") + `]`,
+	}
+
+	t.Run("Block receipts", func(t *testing.T) {
+		server := newBatchServer(t, func(request ethereumrpcclient.JSONRPCRequest) string {
+			assert.Equal(t, "eth_getBlockReceipts", request.Method)
+			return `{"jsonrpc":"2.0","result":` + receiptsByBlock[request.Params[0].(string)] + `,"id":` + strconv.Itoa(request.ID) + `}`
+		})
+		defer server.Close()
+
+		blocks := newBlocks()
+		err := ethereumrpcclient.AttachReceipts(context.Background(), ethereumrpcclient.NewEthereumRPCClient(server.URL, nil), blocks)
+
+		assert.NoError(t, err, "Unexpected error")
+		assert.True(t, blocks[0].Transactions[0].Receipt.Succeeded(), "Receipts should be matched by hash")
+		assert.False(t, blocks[0].Transactions[1].Receipt.Succeeded(), "Receipts should be matched by hash")
+		assert.Equal(t, "0xc1", blocks[2].Transactions[0].Receipt.TransactionHash)
+	})
+
+	t.Run("Fallback to transaction receipts", func(t *testing.T) {
+		server := newBatchServer(t, func(request ethereumrpcclient.JSONRPCRequest) string {
+			id := strconv.Itoa(request.ID)
+			if request.Method == "eth_getBlockReceipts" {
+				return `{"jsonrpc":"2.0","error":{"code":-32601,"message":"Method not found"},"id":` + id + `}`
+			}
+			return `{"jsonrpc":"2.0","result":` + receipt(request.Params[0].(string), "0x1") + `,"id":` + id + `}`
+		})
+		defer server.Close()
+
+		blocks := newBlocks()
+		err := ethereumrpcclient.AttachReceipts(context.Background(), ethereumrpcclient.NewEthereumRPCClient(server.URL, nil), blocks)
+
+		assert.NoError(t, err, "Unexpected error")
+		assert.Equal(t, "0xa1", blocks[0].Transactions[0].Receipt.TransactionHash)
+		assert.Equal(t, "0xa2", blocks[0].Transactions[1].Receipt.TransactionHash)
+		assert.Equal(t, "0xc1", blocks[2].Transactions[0].Receipt.TransactionHash)
+	})
+
+	t.Run("Transaction receipts in batches", func(t *testing.T) {
+		server := newBatchServer(t, func(request ethereumrpcclient.JSONRPCRequest) string {
+			id := strconv.Itoa(request.ID)
+			if request.Method == "eth_getBlockReceipts" {
+				return `{"jsonrpc":"2.0","error":{"code":-32601,"message":"Method not found"},"id":` + id + `}`
+			}
+			return `{"jsonrpc":"2.0","result":` + receipt(request.Params[0].(string), "0x1") + `,"id":` + id + `}`
+		})
+		defer server.Close()
+
+		var mutex sync.Mutex
+		var batches []int
+		counting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			var requests []ethereumrpcclient.JSONRPCRequest
+			json.Unmarshal(body, &requests)
+			if len(requests) > 0 && requests[0].Method == "eth_getTransactionReceipt" {
+				mutex.Lock()
+				batches = append(batches, len(requests))
+				mutex.Unlock()
+			}
+
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			server.Config.Handler.ServeHTTP(w, r)
+		}))
+		defer counting.Close()
+
+		blocks := []*ethereumrpcclient.Block{{Number: "0x1"}, {Number: "0x2"}, {Number: "0x3"}}
+		for i := 0; i < 250; i++ {
+			block := blocks[2*(i%2)]
+			block.Transactions = append(block.Transactions, ethereumrpcclient.Transaction{Hash: "0x" + strconv.FormatInt(int64(i), 16)})
+		}
+
+		err := ethereumrpcclient.AttachReceipts(context.Background(), ethereumrpcclient.NewEthereumRPCClient(counting.URL, nil), blocks)
+
+		assert.NoError(t, err, "Unexpected error")
+		assert.Equal(t, []int{100, 100, 50}, batches, "Receipts should be requested in bounded batches")
+		for _, block := range blocks {
+			for _, tx := range block.Transactions {
+				assert.Equal(t, tx.Hash, tx.Receipt.TransactionHash)
+			}
+		}
+	})
+
+	t.Run("Missing receipt", func(t *testing.T) {
+		server := newBatchServer(t, func(request ethereumrpcclient.JSONRPCRequest) string {
+			return `{"jsonrpc":"2.0","result":[],"id":` + strconv.Itoa(request.ID) + `}`
+		})
+		defer server.Close()
+
+		err := ethereumrpcclient.AttachReceipts(context.Background(), ethereumrpcclient.NewEthereumRPCClient(server.URL, nil), newBlocks())
+
+		assert.EqualError(t, err, "missing receipt for transaction 0xa1")
+	})
+}
@@ -0,0 +1,256 @@
+package ethereumrpcclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// ErrCodeLimitExceeded is returned by providers such as Infura and Alchemy
+	// when the request quota is used up.
+	ErrCodeLimitExceeded  = -32005
+	ErrCodeMethodNotFound = -32601
+	ErrCodeInternal       = -32603
+)
+
+// HTTPError is a non-200 response from the endpoint.
+type HTTPError struct {
+	StatusCode int
+	RetryAfter time.Duration
+}
+
+func (e *HTTPError) Error() string {
+	return "error sending request, status code: " + strconv.Itoa(e.StatusCode)
+}
+
+// IsRetryable reports whether err is a transient failure worth retrying:
+// timeouts, refused or reset connections, connections closed early, HTTP 429
+// and 5xx responses, and JSON-RPC limit or internal errors. Cancelled
+// contexts, malformed requests and other transport errors are fatal.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode == http.StatusTooManyRequests || httpErr.StatusCode >= 500
+	}
+
+	var rpcErr *RPCError
+	if errors.As(err, &rpcErr) {
+		return rpcErr.Code == ErrCodeLimitExceeded || rpcErr.Code == ErrCodeInternal
+	}
+
+	// Only failures of the connection itself, requests to a bad url or a host
+	// with an invalid certificate fail the same way every time
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	return errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// IsUnsupported reports whether err is a provider refusing the method, as nodes
+// do for the debug_ and trace_ namespaces they don't expose. Other providers
+// may still support it, the same one never will.
+func IsUnsupported(err error) bool {
+	var rpcErr *RPCError
+	if !errors.As(err, &rpcErr) {
+		return false
+	}
+	if rpcErr.Code == ErrCodeMethodNotFound {
+		return true
+	}
+
+	message := strings.ToLower(rpcErr.Message)
+	for _, unsupported := range []string{"does not exist/is not available", "method not found", "method not supported", "unsupported method"} {
+		if strings.Contains(message, unsupported) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// retryAfter returns the delay requested by the server, if any.
+func retryAfter(err error) time.Duration {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.RetryAfter
+	}
+
+	return 0
+}
+
+// parseRetryAfter reads a Retry-After header given either in seconds or as an
+// HTTP date.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		if delay := time.Until(date); delay > 0 {
+			return delay
+		}
+	}
+
+	return 0
+}
+
+type RetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+	// Jitter randomizes each backoff by up to this fraction, between 0 and 1
+	Jitter float64
+}
+
+// MaxRetryAfter bounds the Retry-After honored by policies without MaxBackoff.
+const MaxRetryAfter = time.Minute
+
+// NoRetry makes a single attempt per call.
+var NoRetry = RetryPolicy{MaxAttempts: 1}
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    4,
+		InitialBackoff: 500 * time.Millisecond,
+		MaxBackoff:     10 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+	}
+}
+
+// delay returns the wait before retry number attempt. A Retry-After asked for
+// by err is honored up to MaxBackoff, or MaxRetryAfter without one, so that a
+// provider cannot hold calls back indefinitely.
+func (p RetryPolicy) delay(attempt int, err error) time.Duration {
+	limit := p.MaxBackoff
+	if limit <= 0 {
+		limit = MaxRetryAfter
+	}
+
+	return max(p.Backoff(attempt), min(retryAfter(err), limit))
+}
+
+// Backoff returns the delay before retry number attempt, starting from 1.
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+
+	backoff := float64(p.InitialBackoff) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxBackoff > 0 && backoff > float64(p.MaxBackoff) {
+		backoff = float64(p.MaxBackoff)
+	}
+
+	if p.Jitter > 0 {
+		backoff += backoff * p.Jitter * (2*rand.Float64() - 1)
+	}
+
+	return time.Duration(backoff)
+}
+
+// RateLimiter is a token bucket refilled at Rate tokens per second, holding at
+// most Burst tokens.
+type RateLimiter struct {
+	mutex  sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &RateLimiter{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Wait blocks until a token is available or ctx is done.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	for {
+		l.mutex.Lock()
+		now := time.Now()
+		l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+		l.last = now
+
+		if l.tokens >= 1 {
+			l.tokens--
+			l.mutex.Unlock()
+			return nil
+		}
+
+		wait := time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+		l.mutex.Unlock()
+
+		if err := sleep(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+// withRetry runs call until it succeeds, fails with an error that is not
+// retryable, or the policy runs out of attempts.
+func (c *EthereumRPCClient) withRetry(ctx context.Context, call func() error) error {
+	attempts := max(c.RetryPolicy.MaxAttempts, 1)
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if c.RateLimiter != nil {
+			if err := c.RateLimiter.Wait(ctx); err != nil {
+				return err
+			}
+		}
+
+		err = call()
+		if err == nil || !IsRetryable(err) || attempt == attempts {
+			return err
+		}
+
+		if err := sleep(ctx, c.RetryPolicy.delay(attempt, err)); err != nil {
+			return err
+		}
+	}
+
+	return err
+}
+
+func sleep(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
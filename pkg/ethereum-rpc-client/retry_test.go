@@ -0,0 +1,250 @@
+package ethereumrpcclient_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	ethereumrpcclient "ethereum-parser/pkg/ethereum-rpc-client"
+)
+
+// transportError returns the error of a request to rawURL sent with client.
+func transportError(client *http.Client, rawURL string) error {
+	response, err := client.Post(rawURL, "application/json", strings.NewReader("{}"))
+	if err == nil {
+		response.Body.Close()
+	}
+
+	return err
+}
+
+func TestIsRetryable(t *testing.T) {
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer slow.Close()
+
+	selfSigned := httptest.NewUnstartedServer(http.NotFoundHandler())
+	selfSigned.Config.ErrorLog = log.New(io.Discard, "", 0)
+	selfSigned.StartTLS()
+	defer selfSigned.Close()
+
+	_, invalidURL := url.Parse("http://node:port")
+
+	cases := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{"Nil", nil, false},
+		{"Cancelled", context.Canceled, false},
+		{"Too many requests", &ethereumrpcclient.HTTPError{StatusCode: http.StatusTooManyRequests}, true},
+		{"Bad gateway", &ethereumrpcclient.HTTPError{StatusCode: http.StatusBadGateway}, true},
+		{"Bad request", &ethereumrpcclient.HTTPError{StatusCode: http.StatusBadRequest}, false},
+		{"Limit exceeded", &ethereumrpcclient.RPCError{Code: -32005, Message: "limit exceeded"}, true},
+		{"Method not found", &ethereumrpcclient.RPCError{Code: -32601, Message: "Method not found"}, false},
+		{"Decoding error", errors.New("error decoding response body"), false},
+		{"Connection refused", transportError(http.DefaultClient, closed.URL), true},
+		{"Timeout", transportError(&http.Client{Timeout: 10 * time.Millisecond}, slow.URL), true},
+		{"Connection reset", &url.Error{Op: "Post", URL: "http://node", Err: &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}}, true},
+		{"Connection closed", &url.Error{Op: "Post", URL: "http://node", Err: io.EOF}, true},
+		{"Unsupported protocol scheme", transportError(http.DefaultClient, "ftp://node"), false},
+		{"Bad certificate", transportError(http.DefaultClient, selfSigned.URL), false},
+		{"Invalid url", invalidURL, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, c.expected, ethereumrpcclient.IsRetryable(c.err))
+		})
+	}
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := ethereumrpcclient.RetryPolicy{
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     500 * time.Millisecond,
+		Multiplier:     2,
+	}
+
+	assert.Equal(t, 100*time.Millisecond, policy.Backoff(1))
+	assert.Equal(t, 200*time.Millisecond, policy.Backoff(2))
+	assert.Equal(t, 400*time.Millisecond, policy.Backoff(3))
+	assert.Equal(t, 500*time.Millisecond, policy.Backoff(4), "Backoff should be capped")
+
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		backoff := policy.Backoff(2)
+		assert.GreaterOrEqual(t, backoff, 100*time.Millisecond, "Jitter should stay within bounds")
+		assert.LessOrEqual(t, backoff, 300*time.Millisecond, "Jitter should stay within bounds")
+	}
+}
+
+func TestEthereumRPCClient_Retry(t *testing.T) {
+	fastRetry := ethereumrpcclient.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, Multiplier: 1}
+
+	cases := []struct {
+		name             string
+		failures         []func(w http.ResponseWriter)
+		expectedAttempts int32
+		expectedErr      string
+	}{
+		{
+			name: "Retry rate limit then succeed",
+			failures: []func(w http.ResponseWriter){
+				func(w http.ResponseWriter) { w.WriteHeader(http.StatusTooManyRequests) },
+				func(w http.ResponseWriter) {
+					w.Write([]byte(`{"jsonrpc":"2.0","error":{"code":-32005,"message":"limit exceeded"},"id":1}`))
+				},
+			},
+			expectedAttempts: 3,
+		},
+		{
+			name: "Give up after max attempts",
+			failures: []func(w http.ResponseWriter){
+				func(w http.ResponseWriter) { w.WriteHeader(http.StatusServiceUnavailable) },
+				func(w http.ResponseWriter) { w.WriteHeader(http.StatusServiceUnavailable) },
+				func(w http.ResponseWriter) { w.WriteHeader(http.StatusServiceUnavailable) },
+			},
+			expectedAttempts: 3,
+			expectedErr:      "error sending request, status code: 503",
+		},
+		{
+			name: "Do not retry fatal errors",
+			failures: []func(w http.ResponseWriter){
+				func(w http.ResponseWriter) {
+					w.Write([]byte(`{"jsonrpc":"2.0","error":{"code":-32601,"message":"Method not found"},"id":1}`))
+				},
+			},
+			expectedAttempts: 1,
+			expectedErr:      "Method not found",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var attempts int32
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempt := atomic.AddInt32(&attempts, 1)
+				if int(attempt) <= len(c.failures) {
+					c.failures[attempt-1](w)
+					return
+				}
+				w.Write([]byte(`{"jsonrpc":"2.0","result":"0x1","id":1}`))
+			}))
+			defer server.Close()
+
+			client := ethereumrpcclient.NewEthereumRPCClient(server.URL, nil)
+			client.RetryPolicy = fastRetry
+
+			_, err := client.CallJSONRPC(context.Background(), "eth_blockNumber", []interface{}{})
+
+			assert.Equal(t, c.expectedAttempts, atomic.LoadInt32(&attempts), "Unexpected number of attempts")
+			if c.expectedErr != "" {
+				assert.EqualError(t, err, c.expectedErr)
+			} else {
+				assert.NoError(t, err, "Unexpected error")
+			}
+		})
+	}
+}
+
+func TestEthereumRPCClient_RetryAfter(t *testing.T) {
+	var attempts int32
+	var firstAttempt, secondAttempt time.Time
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			firstAttempt = time.Now()
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		secondAttempt = time.Now()
+		w.Write([]byte(`{"jsonrpc":"2.0","result":"0x1","id":1}`))
+	}))
+	defer server.Close()
+
+	client := ethereumrpcclient.NewEthereumRPCClient(server.URL, nil)
+	client.RetryPolicy = ethereumrpcclient.RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond}
+
+	_, err := client.CallJSONRPC(context.Background(), "eth_blockNumber", []interface{}{})
+
+	assert.NoError(t, err, "Unexpected error")
+	assert.GreaterOrEqual(t, secondAttempt.Sub(firstAttempt), time.Second, "Retry-After should be honored")
+}
+
+func TestEthereumRPCClient_RetryAfterLimit(t *testing.T) {
+	var attempts int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			w.Header().Set("Retry-After", "3600")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(`{"jsonrpc":"2.0","result":"0x1","id":1}`))
+	}))
+	defer server.Close()
+
+	client := ethereumrpcclient.NewEthereumRPCClient(server.URL, nil)
+	client.RetryPolicy = ethereumrpcclient.RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond, MaxBackoff: 20 * time.Millisecond}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	_, err := client.CallJSONRPC(ctx, "eth_blockNumber", []interface{}{})
+
+	assert.NoError(t, err, "Retry-After should be bounded by MaxBackoff")
+	assert.Equal(t, int32(2), atomic.LoadInt32(&attempts))
+}
+
+func TestEthereumRPCClient_RetryCancelled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := ethereumrpcclient.NewEthereumRPCClient(server.URL, nil)
+	client.RetryPolicy = ethereumrpcclient.RetryPolicy{MaxAttempts: 10, InitialBackoff: time.Hour}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := client.CallJSONRPC(ctx, "eth_blockNumber", []interface{}{})
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "Backoff should stop when the context is done")
+}
+
+func TestRateLimiter(t *testing.T) {
+	limiter := ethereumrpcclient.NewRateLimiter(20, 2)
+
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		assert.NoError(t, limiter.Wait(context.Background()))
+	}
+
+	// Two tokens come from the burst, the other two take 50ms each
+	assert.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond, "Limiter should throttle after the burst")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, limiter.Wait(ctx), context.Canceled, "Wait should stop when the context is done")
+}
@@ -0,0 +1,217 @@
+package ethereumrpcclient
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Selectors of the optional ERC-20 metadata getters
+const (
+	symbolSelector   = "0x95d89b41"
+	decimalsSelector = "0x313ce567"
+)
+
+type TokenMetadata struct {
+	Symbol   string `json:"symbol"`
+	Decimals int    `json:"decimals"`
+}
+
+// Call runs a read-only eth_call of data against the contract at to on the
+// latest block and returns the raw return data.
+func Call(ctx context.Context, client RPCClient, to string, data string) ([]byte, error) {
+	result, err := client.CallJSONRPC(ctx, "eth_call", []interface{}{
+		map[string]string{"to": to, "data": data},
+		TagLatest,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error calling %s, %w", to, err)
+	}
+
+	var hexData string
+	if err := json.Unmarshal(result, &hexData); err != nil {
+		return nil, errors.New("error unmarshalling call result, " + err.Error())
+	}
+
+	returnData, err := hex.DecodeString(strings.TrimPrefix(hexData, "0x"))
+	if err != nil {
+		return nil, errors.New("error decoding call result, " + err.Error())
+	}
+
+	return returnData, nil
+}
+
+// GetTokenMetadata reads symbol() and decimals() from an ERC-20 contract.
+func GetTokenMetadata(ctx context.Context, client RPCClient, token string) (*TokenMetadata, error) {
+	symbolData, err := Call(ctx, client, token, symbolSelector)
+	if err != nil {
+		return nil, err
+	}
+
+	symbol, err := decodeABIString(symbolData)
+	if err != nil {
+		return nil, errors.New("error decoding symbol of " + token + ", " + err.Error())
+	}
+
+	decimalsData, err := Call(ctx, client, token, decimalsSelector)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(decimalsData) != 32 {
+		return nil, errors.New("error decoding decimals of " + token + ", unexpected length")
+	}
+
+	decimals := new(big.Int).SetBytes(decimalsData)
+	if !decimals.IsUint64() || decimals.Uint64() > 255 {
+		return nil, errors.New("error decoding decimals of " + token + ", out of range")
+	}
+
+	return &TokenMetadata{Symbol: symbol, Decimals: int(decimals.Uint64())}, nil
+}
+
+// decodeABIString decodes an ABI encoded string return value. Some older
+// tokens return a NUL padded bytes32 instead, which is accepted too.
+func decodeABIString(data []byte) (string, error) {
+	if len(data) == 32 {
+		return string(bytes.TrimRight(data, "\x00")), nil
+	}
+
+	if len(data) < 64 {
+		return "", errors.New("unexpected length")
+	}
+
+	offset := new(big.Int).SetBytes(data[:32])
+	if !offset.IsUint64() || offset.Uint64()+32 > uint64(len(data)) {
+		return "", errors.New("invalid offset")
+	}
+
+	start := offset.Uint64() + 32
+	length := new(big.Int).SetBytes(data[offset.Uint64():start])
+	if !length.IsUint64() || start+length.Uint64() > uint64(len(data)) {
+		return "", errors.New("invalid length")
+	}
+
+	return string(data[start : start+length.Uint64()]), nil
+}
+
+// Defaults of NewTokenMetadataCache.
+const (
+	DefaultTokenMetadataTimeout    = 2 * time.Second
+	DefaultTokenMetadataCacheSize  = 10000
+	DefaultTokenMetadataRetryDelay = time.Minute
+)
+
+// TokenMetadataCache resolves token metadata once per contract. Contracts
+// that do not implement the getters are remembered too, so they are not asked
+// again, and so are transient failures for RetryDelay. Each resolution is
+// bounded by Timeout, and the least recently used contracts are evicted past
+// MaxEntries.
+type TokenMetadataCache struct {
+	Client     RPCClient
+	Timeout    time.Duration
+	MaxEntries int
+	RetryDelay time.Duration
+
+	mutex  sync.Mutex
+	tokens map[string]*list.Element
+	order  *list.List // most recently used first
+}
+
+type tokenMetadataEntry struct {
+	token    string
+	metadata *TokenMetadata
+	err      error
+	failedAt time.Time
+}
+
+func NewTokenMetadataCache(client RPCClient) *TokenMetadataCache {
+	return &TokenMetadataCache{
+		Client:     client,
+		Timeout:    DefaultTokenMetadataTimeout,
+		MaxEntries: DefaultTokenMetadataCacheSize,
+		RetryDelay: DefaultTokenMetadataRetryDelay,
+		tokens:     make(map[string]*list.Element),
+		order:      list.New(),
+	}
+}
+
+// ResolveToken returns the metadata of token, or nil when the contract does
+// not expose it.
+func (c *TokenMetadataCache) ResolveToken(ctx context.Context, token string) (*TokenMetadata, error) {
+	token = strings.ToLower(token)
+
+	if entry, ok := c.get(token); ok {
+		return entry.metadata, entry.err
+	}
+
+	callCtx := ctx
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		callCtx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+
+	entry := &tokenMetadataEntry{token: token}
+	metadata, err := GetTokenMetadata(callCtx, c.Client, token)
+	switch {
+	case err == nil:
+		entry.metadata = metadata
+	case ctx.Err() != nil:
+		// The caller gave up, the token says nothing about the node
+		return nil, err
+	case IsRetryable(err) || errors.Is(err, context.DeadlineExceeded):
+		// Transient failures are retried once RetryDelay passed
+		entry.err = err
+		entry.failedAt = time.Now()
+	}
+
+	c.put(entry)
+
+	return entry.metadata, entry.err
+}
+
+func (c *TokenMetadataCache) get(token string) (*tokenMetadataEntry, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	element, ok := c.tokens[token]
+	if !ok {
+		return nil, false
+	}
+
+	entry := element.Value.(*tokenMetadataEntry)
+	if entry.err != nil && time.Since(entry.failedAt) >= c.RetryDelay {
+		c.order.Remove(element)
+		delete(c.tokens, token)
+		return nil, false
+	}
+
+	c.order.MoveToFront(element)
+
+	return entry, true
+}
+
+func (c *TokenMetadataCache) put(entry *tokenMetadataEntry) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if element, ok := c.tokens[entry.token]; ok {
+		c.order.Remove(element)
+	}
+	c.tokens[entry.token] = c.order.PushFront(entry)
+
+	for c.MaxEntries > 0 && c.order.Len() > c.MaxEntries {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.tokens, oldest.Value.(*tokenMetadataEntry).token)
+	}
+}
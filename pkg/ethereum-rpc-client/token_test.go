@@ -0,0 +1,150 @@
+package ethereumrpcclient_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	ethereumrpcclient "ethereum-parser/pkg/ethereum-rpc-client"
+)
+
+const (
+	// ABI encoded "USDC"
+	usdcSymbol = "0x0000000000000000000000000000000000000000000000000000000000000020" +
+		"0000000000000000000000000000000000000000000000000000000000000004" +
+		"5553444300000000000000000000000000000000000000000000000000000000"
+	// bytes32 "MKR", as returned by older tokens
+	mkrSymbol = "0x4d4b520000000000000000000000000000000000000000000000000000000000"
+	decimals6 = "0x0000000000000000000000000000000000000000000000000000000000000006"
+)
+
+// newTokenServer answers eth_call with the return data registered for each
+// contract and selector, and reverts otherwise.
+func newTokenServer(t *testing.T, returns map[string]string, calls *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			ID     int               `json:"id"`
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		json.NewDecoder(r.Body).Decode(&request)
+		atomic.AddInt32(calls, 1)
+
+		var call struct{ To, Data string }
+		json.Unmarshal(request.Params[0], &call)
+
+		id := strconv.Itoa(request.ID)
+		if result, ok := returns[call.To+call.Data]; ok {
+			w.Write([]byte(`{"jsonrpc":"2.0","result":"` + result + `","id":` + id + `}`))
+			return
+		}
+		w.Write([]byte(`{"jsonrpc":"2.0","error":{"code":3,"message":"execution reverted"},"id":` + id + `}`))
+	}))
+}
+
+func TestGetTokenMetadata(t *testing.T) {
+	var calls int32
+	server := newTokenServer(t, map[string]string{
+		"0xusdc0x95d89b41": usdcSymbol,
+		"0xusdc0x313ce567": decimals6,
+		"0xmkr0x95d89b41":  mkrSymbol,
+		"0xmkr0x313ce567":  decimals6,
+	}, &calls)
+	defer server.Close()
+
+	client := ethereumrpcclient.NewEthereumRPCClient(server.URL, nil)
+
+	metadata, err := ethereumrpcclient.GetTokenMetadata(context.Background(), client, "0xusdc")
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, &ethereumrpcclient.TokenMetadata{Symbol: "USDC", Decimals: 6}, metadata)
+
+	metadata, err = ethereumrpcclient.GetTokenMetadata(context.Background(), client, "0xmkr")
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, &ethereumrpcclient.TokenMetadata{Symbol: "MKR", Decimals: 6}, metadata, "bytes32 symbols should be decoded")
+
+	_, err = ethereumrpcclient.GetTokenMetadata(context.Background(), client, "0xnft")
+	assert.EqualError(t, err, "error calling 0xnft, execution reverted")
+}
+
+func TestTokenMetadataCache(t *testing.T) {
+	var calls int32
+	server := newTokenServer(t, map[string]string{
+		"0xusdc0x95d89b41": usdcSymbol,
+		"0xusdc0x313ce567": decimals6,
+	}, &calls)
+	defer server.Close()
+
+	cache := ethereumrpcclient.NewTokenMetadataCache(ethereumrpcclient.NewEthereumRPCClient(server.URL, nil))
+
+	for i := 0; i < 3; i++ {
+		metadata, err := cache.ResolveToken(context.Background(), "0xUSDC")
+		assert.NoError(t, err, "Unexpected error")
+		assert.Equal(t, "USDC", metadata.Symbol)
+
+		metadata, err = cache.ResolveToken(context.Background(), "0xnft")
+		assert.NoError(t, err, "Contracts without metadata should not be an error")
+		assert.Nil(t, metadata)
+	}
+
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls), "Metadata should be fetched once per token")
+}
+
+func TestTokenMetadataCache_Failures(t *testing.T) {
+	var calls int32
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(100 * time.Millisecond)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer slow.Close()
+
+	cache := ethereumrpcclient.NewTokenMetadataCache(ethereumrpcclient.NewEthereumRPCClient(slow.URL, nil))
+	cache.Timeout = 10 * time.Millisecond
+
+	for i := 0; i < 3; i++ {
+		start := time.Now()
+		metadata, err := cache.ResolveToken(context.Background(), "0xusdc")
+		assert.ErrorIs(t, err, context.DeadlineExceeded, "Resolution should be bounded by Timeout")
+		assert.Nil(t, metadata)
+		assert.Less(t, time.Since(start), 100*time.Millisecond)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls), "Failures should be cached")
+
+	cache.RetryDelay = 0
+	cache.ResolveToken(context.Background(), "0xusdc")
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls), "Failures should be retried after RetryDelay")
+}
+
+func TestTokenMetadataCache_MaxEntries(t *testing.T) {
+	var calls int32
+	server := newTokenServer(t, map[string]string{
+		"0xusdc0x95d89b41": usdcSymbol,
+		"0xusdc0x313ce567": decimals6,
+	}, &calls)
+	defer server.Close()
+
+	cache := ethereumrpcclient.NewTokenMetadataCache(ethereumrpcclient.NewEthereumRPCClient(server.URL, nil))
+	cache.MaxEntries = 2
+
+	cache.ResolveToken(context.Background(), "0xusdc")
+	cache.ResolveToken(context.Background(), "0xa")
+	// Using usdc again makes 0xa the least recently used
+	cache.ResolveToken(context.Background(), "0xusdc")
+	cache.ResolveToken(context.Background(), "0xb")
+	assert.Equal(t, int32(4), atomic.LoadInt32(&calls))
+
+	metadata, err := cache.ResolveToken(context.Background(), "0xusdc")
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, "USDC", metadata.Symbol)
+	assert.Equal(t, int32(4), atomic.LoadInt32(&calls), "Recently used tokens should be kept")
+
+	cache.ResolveToken(context.Background(), "0xa")
+	assert.Equal(t, int32(5), atomic.LoadInt32(&calls), "Least recently used tokens should be evicted")
+}
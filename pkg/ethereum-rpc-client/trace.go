@@ -0,0 +1,287 @@
+package ethereumrpcclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"ethereum-parser/util"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Tracers that can be used to find internal transactions
+const (
+	// TracerCall uses debug_traceBlockByHash with geth's callTracer
+	TracerCall = "callTracer"
+	// TracerParity uses trace_block, as served by Erigon, Nethermind and Reth
+	TracerParity = "parity"
+)
+
+// InternalTransaction is a value transfer made by a contract while executing
+// a transaction. TraceAddress locates the call in the call tree, e.g. "0.1" is
+// the second subcall of the first subcall.
+type InternalTransaction struct {
+	TransactionHash string
+	Type            string
+	From            string
+	To              string
+	Value           util.Quantity
+	TraceAddress    string
+}
+
+// MarshalJSON adds the value in ether next to the raw amount of wei and writes
+// addresses with their EIP-55 checksum.
+func (tx InternalTransaction) MarshalJSON() ([]byte, error) {
+	type internalTransaction InternalTransaction
+	tx.From = util.ChecksumAddress(tx.From)
+	tx.To = util.ChecksumAddress(tx.To)
+	return json.Marshal(struct {
+		internalTransaction
+		ValueEther string
+	}{internalTransaction(tx), tx.Value.Format(util.EtherDecimals)})
+}
+
+type callFrame struct {
+	Type  string        `json:"type"`
+	From  string        `json:"from"`
+	To    string        `json:"to"`
+	Value util.Quantity `json:"value"`
+	Error string        `json:"error"`
+	Calls []callFrame   `json:"calls"`
+}
+
+type callTrace struct {
+	TxHash string    `json:"txHash"`
+	Result callFrame `json:"result"`
+	Error  string    `json:"error"`
+}
+
+type parityTrace struct {
+	Type   string `json:"type"`
+	Action struct {
+		CallType      string        `json:"callType"`
+		From          string        `json:"from"`
+		To            string        `json:"to"`
+		Value         util.Quantity `json:"value"`
+		Address       string        `json:"address"`
+		RefundAddress string        `json:"refundAddress"`
+		Balance       util.Quantity `json:"balance"`
+	} `json:"action"`
+	Result *struct {
+		Address string `json:"address"`
+	} `json:"result"`
+	TraceAddress    []int  `json:"traceAddress"`
+	TransactionHash string `json:"transactionHash"`
+	BlockHash       string `json:"blockHash"`
+	Error           string `json:"error"`
+}
+
+// GetInternalTransactions traces every transaction of a block with tracer and
+// returns the internal calls that moved ether, skipping the top level calls
+// and anything that reverted. The callTracer results carry no hash on older
+// geth versions, in which case TransactionHash is left empty and the traces
+// are in transaction order. The block is traced by blockHash where the tracer
+// allows it, otherwise the traces are checked to belong to it, so that a
+// reorganized block number never yields the traces of another block.
+func (c *EthereumRPCClient) GetInternalTransactions(ctx context.Context, blockNumber int, blockHash string, tracer string) ([][]InternalTransaction, error) {
+	number := "0x" + strconv.FormatInt(int64(blockNumber), 16)
+
+	switch tracer {
+	case TracerCall:
+		result, err := c.CallJSONRPC(ctx, "debug_traceBlockByHash", []interface{}{blockHash, map[string]string{"tracer": TracerCall}})
+		if err != nil {
+			return nil, fmt.Errorf("error tracing block, %w", err)
+		}
+
+		var traces []callTrace
+		if err := json.Unmarshal(result, &traces); err != nil {
+			return nil, errors.New("error unmarshalling block traces, " + err.Error())
+		}
+
+		return flattenCallTraces(traces), nil
+	case TracerParity:
+		result, err := c.CallJSONRPC(ctx, "trace_block", []interface{}{number})
+		if err != nil {
+			return nil, fmt.Errorf("error tracing block, %w", err)
+		}
+
+		var traces []parityTrace
+		if err := json.Unmarshal(result, &traces); err != nil {
+			return nil, errors.New("error unmarshalling block traces, " + err.Error())
+		}
+
+		// trace_block only takes a number, the node may be on another fork
+		for _, trace := range traces {
+			if trace.BlockHash != "" && !strings.EqualFold(trace.BlockHash, blockHash) {
+				return nil, errors.New("error tracing block " + blockHash + ", node traced block " + trace.BlockHash)
+			}
+		}
+
+		return flattenParityTraces(traces), nil
+	default:
+		return nil, errors.New("unknown tracer, " + tracer)
+	}
+}
+
+func flattenCallTraces(traces []callTrace) [][]InternalTransaction {
+	internalTxs := make([][]InternalTransaction, len(traces))
+
+	var walk func(i int, hash string, frame callFrame, traceAddress string)
+	walk = func(i int, hash string, frame callFrame, traceAddress string) {
+		// A reverted call undoes every transfer made below it
+		if frame.Error != "" {
+			return
+		}
+
+		if traceAddress != "" && movesValue(frame.Type, frame.Value) {
+			internalTxs[i] = append(internalTxs[i], InternalTransaction{
+				TransactionHash: hash,
+				Type:            frame.Type,
+				From:            frame.From,
+				To:              frame.To,
+				Value:           frame.Value,
+				TraceAddress:    traceAddress,
+			})
+		}
+
+		for j, call := range frame.Calls {
+			walk(i, hash, call, strings.TrimPrefix(traceAddress+"."+strconv.Itoa(j), "."))
+		}
+	}
+
+	for i, trace := range traces {
+		if trace.Error == "" {
+			walk(i, trace.TxHash, trace.Result, "")
+		}
+	}
+
+	return internalTxs
+}
+
+func flattenParityTraces(traces []parityTrace) [][]InternalTransaction {
+	var internalTxs [][]InternalTransaction
+	index := make(map[string]int)
+	reverted := make(map[string][]string)
+
+	for _, trace := range traces {
+		if trace.Type == "reward" || trace.TransactionHash == "" {
+			continue
+		}
+
+		i, ok := index[trace.TransactionHash]
+		if !ok {
+			i = len(internalTxs)
+			index[trace.TransactionHash] = i
+			internalTxs = append(internalTxs, nil)
+		}
+
+		parts := make([]string, len(trace.TraceAddress))
+		for j, position := range trace.TraceAddress {
+			parts[j] = strconv.Itoa(position)
+		}
+		traceAddress := strings.Join(parts, ".")
+
+		// Parent frames come first, so reverted ancestors are already known
+		if trace.Error != "" || hasRevertedAncestor(reverted[trace.TransactionHash], traceAddress) {
+			reverted[trace.TransactionHash] = append(reverted[trace.TransactionHash], traceAddress)
+			continue
+		}
+
+		if traceAddress == "" {
+			continue
+		}
+
+		internalTx := InternalTransaction{
+			TransactionHash: trace.TransactionHash,
+			Type:            strings.ToUpper(trace.Type),
+			From:            trace.Action.From,
+			To:              trace.Action.To,
+			Value:           trace.Action.Value,
+			TraceAddress:    traceAddress,
+		}
+
+		switch trace.Type {
+		case "call":
+			internalTx.Type = strings.ToUpper(trace.Action.CallType)
+		case "create":
+			if trace.Result != nil {
+				internalTx.To = trace.Result.Address
+			}
+		case "suicide":
+			internalTx.Type = "SELFDESTRUCT"
+			internalTx.From = trace.Action.Address
+			internalTx.To = trace.Action.RefundAddress
+			internalTx.Value = trace.Action.Balance
+		}
+
+		if movesValue(internalTx.Type, internalTx.Value) {
+			internalTxs[i] = append(internalTxs[i], internalTx)
+		}
+	}
+
+	return internalTxs
+}
+
+func hasRevertedAncestor(reverted []string, traceAddress string) bool {
+	for _, ancestor := range reverted {
+		if ancestor == "" || strings.HasPrefix(traceAddress, ancestor+".") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// movesValue reports whether a call of callType carrying value transfers
+// ether. Delegate and static calls only report the value of their caller.
+func movesValue(callType string, value util.Quantity) bool {
+	switch strings.ToUpper(callType) {
+	case "DELEGATECALL", "STATICCALL":
+		return false
+	}
+
+	return !value.IsZero()
+}
+
+// AttachInternalTransactions traces each of blocks with tracer and sets the
+// internal transactions of their transactions.
+func AttachInternalTransactions(ctx context.Context, client RPCClient, blocks []*Block, tracer string) error {
+	for _, block := range blocks {
+		if len(block.Transactions) == 0 {
+			continue
+		}
+
+		number, err := strconv.ParseInt(strings.TrimPrefix(block.Number, "0x"), 16, 64)
+		if err != nil {
+			return errors.New("error parsing block number, " + err.Error())
+		}
+
+		internalTxs, err := client.GetInternalTransactions(ctx, int(number), block.Hash, tracer)
+		if err != nil {
+			return err
+		}
+
+		byHash := make(map[string][]InternalTransaction, len(internalTxs))
+		for i, txs := range internalTxs {
+			if len(txs) == 0 {
+				continue
+			}
+
+			hash := txs[0].TransactionHash
+			if hash == "" && i < len(block.Transactions) {
+				hash = block.Transactions[i].Hash
+				for j := range txs {
+					txs[j].TransactionHash = hash
+				}
+			}
+			byHash[strings.ToLower(hash)] = txs
+		}
+
+		for i := range block.Transactions {
+			block.Transactions[i].InternalTransactions = byHash[strings.ToLower(block.Transactions[i].Hash)]
+		}
+	}
+
+	return nil
+}
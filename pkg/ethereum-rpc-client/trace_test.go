@@ -0,0 +1,135 @@
+package ethereumrpcclient_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	ethereumrpcclient "ethereum-parser/pkg/ethereum-rpc-client"
+	"ethereum-parser/util"
+)
+
+// A multisig paying out 1 ether to 0xb and 2 ether to 0xc, where the second
+// payout reverted, followed by a transaction without internal transfers.
+const (
+	callTracerResult = `[
+		{"txHash":"0xt1","result":{"type":"CALL","from":"0xa","to":"0xsafe","value":"0x0","calls":[
+			{"type":"DELEGATECALL","from":"0xsafe","to":"0xlib","value":"0x0","calls":[
+				{"type":"CALL","from":"0xsafe","to":"0xb","value":"0xde0b6b3a7640000"},
+				{"type":"CALL","from":"0xsafe","to":"0xc","value":"0x1bc16d674ec80000","error":"execution reverted"},
+				{"type":"STATICCALL","from":"0xsafe","to":"0xoracle","value":"0x0"}
+			]}
+		]}},
+		{"txHash":"0xt2","result":{"type":"CALL","from":"0xa","to":"0xd","value":"0x5"}}
+	]`
+	parityResult = `[
+		{"type":"call","action":{"callType":"call","from":"0xa","to":"0xsafe","value":"0x0"},"traceAddress":[],"transactionHash":"0xt1","blockHash":"0xb1"},
+		{"type":"call","action":{"callType":"delegatecall","from":"0xsafe","to":"0xlib","value":"0x0"},"traceAddress":[0],"transactionHash":"0xt1","blockHash":"0xb1"},
+		{"type":"call","action":{"callType":"call","from":"0xsafe","to":"0xb","value":"0xde0b6b3a7640000"},"traceAddress":[0,0],"transactionHash":"0xt1","blockHash":"0xb1"},
+		{"type":"call","action":{"callType":"call","from":"0xsafe","to":"0xc","value":"0x1bc16d674ec80000"},"traceAddress":[0,1],"transactionHash":"0xt1","blockHash":"0xb1","error":"Reverted"},
+		{"type":"suicide","action":{"address":"0xsafe","refundAddress":"0xe","balance":"0x7"},"traceAddress":[1],"transactionHash":"0xt1","blockHash":"0xb1"},
+		{"type":"call","action":{"callType":"call","from":"0xa","to":"0xd","value":"0x5"},"traceAddress":[],"transactionHash":"0xt2","blockHash":"0xb1"},
+		{"type":"reward","action":{"author":"0xminer","value":"0x1"},"traceAddress":[]}
+	]`
+)
+
+// newTraceServer answers method calls for block, the hash or number the block
+// should be traced by, with result.
+func newTraceServer(t *testing.T, method string, block string, result string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request ethereumrpcclient.JSONRPCRequest
+		json.NewDecoder(r.Body).Decode(&request)
+		if request.Method != method {
+			t.Errorf("Unexpected method %s", request.Method)
+		}
+		if len(request.Params) == 0 || request.Params[0] != block {
+			t.Errorf("Unexpected block %v", request.Params)
+		}
+		w.Write([]byte(`{"jsonrpc":"2.0","result":` + result + `,"id":1}`))
+	}))
+}
+
+func TestGetInternalTransactions(t *testing.T) {
+	payout := ethereumrpcclient.InternalTransaction{
+		TransactionHash: "0xt1",
+		Type:            "CALL",
+		From:            "0xsafe",
+		To:              "0xb",
+		Value:           util.MustParseQuantity("0xde0b6b3a7640000"),
+		TraceAddress:    "0.0",
+	}
+
+	t.Run("callTracer", func(t *testing.T) {
+		server := newTraceServer(t, "debug_traceBlockByHash", "0xb1", callTracerResult)
+		defer server.Close()
+
+		client := ethereumrpcclient.NewEthereumRPCClient(server.URL, nil)
+		internalTxs, err := client.GetInternalTransactions(context.Background(), 1, "0xb1", ethereumrpcclient.TracerCall)
+
+		assert.NoError(t, err, "Unexpected error")
+		assert.Equal(t, [][]ethereumrpcclient.InternalTransaction{{payout}, nil}, internalTxs)
+	})
+
+	t.Run("trace_block", func(t *testing.T) {
+		server := newTraceServer(t, "trace_block", "0x1", parityResult)
+		defer server.Close()
+
+		client := ethereumrpcclient.NewEthereumRPCClient(server.URL, nil)
+		internalTxs, err := client.GetInternalTransactions(context.Background(), 1, "0xb1", ethereumrpcclient.TracerParity)
+
+		assert.NoError(t, err, "Unexpected error")
+		assert.Equal(t, [][]ethereumrpcclient.InternalTransaction{{payout, {
+			TransactionHash: "0xt1",
+			Type:            "SELFDESTRUCT",
+			From:            "0xsafe",
+			To:              "0xe",
+			Value:           util.MustParseQuantity("0x7"),
+			TraceAddress:    "1",
+		}}, nil}, internalTxs)
+	})
+
+	t.Run("trace_block of another block", func(t *testing.T) {
+		server := newTraceServer(t, "trace_block", "0x1", `[
+			{"type":"call","action":{"callType":"call","from":"0xa","to":"0xd","value":"0x5"},"traceAddress":[],"transactionHash":"0xt3","blockHash":"0xb2"}
+		]`)
+		defer server.Close()
+
+		client := ethereumrpcclient.NewEthereumRPCClient(server.URL, nil)
+		_, err := client.GetInternalTransactions(context.Background(), 1, "0xb1", ethereumrpcclient.TracerParity)
+
+		assert.EqualError(t, err, "error tracing block 0xb1, node traced block 0xb2", "Traces of a reorganized block should be rejected")
+	})
+
+	t.Run("Unknown tracer", func(t *testing.T) {
+		client := ethereumrpcclient.NewEthereumRPCClient("http://localhost", nil)
+		_, err := client.GetInternalTransactions(context.Background(), 1, "0xb1", "prestateTracer")
+
+		assert.EqualError(t, err, "unknown tracer, prestateTracer")
+	})
+}
+
+func TestAttachInternalTransactions(t *testing.T) {
+	// Older geth versions do not report the transaction hash
+	server := newTraceServer(t, "debug_traceBlockByHash", "0xb1", `[
+		{"result":{"type":"CALL","from":"0xa","to":"0xsafe","value":"0x0","calls":[{"type":"CALL","from":"0xsafe","to":"0xb","value":"0x1"}]}},
+		{"result":{"type":"CALL","from":"0xa","to":"0xd","value":"0x5"}}
+	]`)
+	defer server.Close()
+
+	blocks := []*ethereumrpcclient.Block{
+		{Number: "0x1", Hash: "0xb1", Transactions: []ethereumrpcclient.Transaction{{Hash: "0xt1"}, {Hash: "0xt2"}}},
+	}
+
+	err := ethereumrpcclient.AttachInternalTransactions(context.Background(),
+		ethereumrpcclient.NewEthereumRPCClient(server.URL, nil), blocks, ethereumrpcclient.TracerCall)
+
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, []ethereumrpcclient.InternalTransaction{
+		{TransactionHash: "0xt1", Type: "CALL", From: "0xsafe", To: "0xb", Value: util.MustParseQuantity("0x1"), TraceAddress: "0"},
+	}, blocks[0].Transactions[0].InternalTransactions, "Traces should be matched by position")
+	assert.Empty(t, blocks[0].Transactions[1].InternalTransactions)
+}
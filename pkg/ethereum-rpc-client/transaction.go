@@ -0,0 +1,82 @@
+package ethereumrpcclient
+
+import (
+	"encoding/json"
+	"ethereum-parser/util"
+)
+
+// Transaction types as reported in the type field
+const (
+	LegacyTxType     = "0x0"
+	AccessListTxType = "0x1" // EIP-2930
+	DynamicFeeTxType = "0x2" // EIP-1559
+	BlobTxType       = "0x3" // EIP-4844
+	SetCodeTxType    = "0x4" // EIP-7702
+)
+
+// Transaction is a transaction as returned by eth_getBlockByNumber. Fields that
+// only exist for some transaction types are left out of the JSON encoding when
+// the node did not return them.
+type Transaction struct {
+	Hash             string
+	From             string
+	To               string
+	Value            util.Quantity
+	BlockHash        string
+	BlockNumber      string
+	TransactionIndex string
+	Type             string `json:",omitempty"`
+	Nonce            string `json:",omitempty"`
+	Gas              string `json:",omitempty"`
+	Input            string `json:",omitempty"`
+	ChainId          string `json:",omitempty"`
+
+	// GasPrice is the effective gas price for EIP-1559 and later types
+	GasPrice             string `json:",omitempty"`
+	MaxFeePerGas         string `json:",omitempty"`
+	MaxPriorityFeePerGas string `json:",omitempty"`
+	MaxFeePerBlobGas     string `json:",omitempty"`
+
+	AccessList          []AccessTuple   `json:",omitempty"`
+	BlobVersionedHashes []string        `json:",omitempty"`
+	AuthorizationList   []Authorization `json:",omitempty"`
+
+	V       string `json:",omitempty"`
+	R       string `json:",omitempty"`
+	S       string `json:",omitempty"`
+	YParity string `json:",omitempty"`
+
+	// Receipt is attached once the transaction outcome has been fetched
+	Receipt *Receipt `json:",omitempty"`
+	// InternalTransactions are attached when a tracer is configured
+	InternalTransactions []InternalTransaction `json:",omitempty"`
+}
+
+// MarshalJSON adds the value in ether next to the raw amount of wei and writes
+// addresses with their EIP-55 checksum.
+func (tx Transaction) MarshalJSON() ([]byte, error) {
+	type transaction Transaction
+	tx.From = util.ChecksumAddress(tx.From)
+	tx.To = util.ChecksumAddress(tx.To)
+	return json.Marshal(struct {
+		transaction
+		ValueEther string
+	}{transaction(tx), tx.Value.Format(util.EtherDecimals)})
+}
+
+// AccessTuple is an entry of an EIP-2930 access list.
+type AccessTuple struct {
+	Address     string
+	StorageKeys []string
+}
+
+// Authorization is a signed EIP-7702 delegation of an account to the code at
+// Address.
+type Authorization struct {
+	ChainId string
+	Address string
+	Nonce   string
+	YParity string
+	R       string
+	S       string
+}
@@ -0,0 +1,94 @@
+package ethereumrpcclient_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	ethereumrpcclient "ethereum-parser/pkg/ethereum-rpc-client"
+	"ethereum-parser/util"
+)
+
+func TestTransaction_Decode(t *testing.T) {
+	cases := []struct {
+		name     string
+		response string
+		expected ethereumrpcclient.Transaction
+	}{
+		{
+			name: "Legacy",
+			response: `{"type":"0x0","hash":"0x1","from":"0xa","to":"0xb","value":"0x10","nonce":"0x5","gas":"0x5208",
+				"gasPrice":"0x3b9aca00","input":"0x","chainId":"0x1","v":"0x25","r":"0xr","s":"0xs"}`,
+			expected: ethereumrpcclient.Transaction{
+				Type: ethereumrpcclient.LegacyTxType, Hash: "0x1", From: "0xa", To: "0xb", Value: util.MustParseQuantity("0x10"), Nonce: "0x5",
+				Gas: "0x5208", GasPrice: "0x3b9aca00", Input: "0x", ChainId: "0x1", V: "0x25", R: "0xr", S: "0xs",
+			},
+		},
+		{
+			name: "Access list",
+			response: `{"type":"0x1","hash":"0x1","gasPrice":"0x1","accessList":[{"address":"0xc","storageKeys":["0x01","0x02"]}],
+				"yParity":"0x1","v":"0x1"}`,
+			expected: ethereumrpcclient.Transaction{
+				Type: ethereumrpcclient.AccessListTxType, Hash: "0x1", GasPrice: "0x1", YParity: "0x1", V: "0x1",
+				AccessList: []ethereumrpcclient.AccessTuple{{Address: "0xc", StorageKeys: []string{"0x01", "0x02"}}},
+			},
+		},
+		{
+			name:     "Dynamic fee",
+			response: `{"type":"0x2","hash":"0x1","gasPrice":"0x3","maxFeePerGas":"0x4","maxPriorityFeePerGas":"0x2","accessList":[]}`,
+			expected: ethereumrpcclient.Transaction{
+				Type: ethereumrpcclient.DynamicFeeTxType, Hash: "0x1", GasPrice: "0x3", MaxFeePerGas: "0x4",
+				MaxPriorityFeePerGas: "0x2", AccessList: []ethereumrpcclient.AccessTuple{},
+			},
+		},
+		{
+			name:     "Blob",
+			response: `{"type":"0x3","hash":"0x1","maxFeePerBlobGas":"0x7","blobVersionedHashes":["0x01aa","0x01bb"]}`,
+			expected: ethereumrpcclient.Transaction{
+				Type: ethereumrpcclient.BlobTxType, Hash: "0x1", MaxFeePerBlobGas: "0x7",
+				BlobVersionedHashes: []string{"0x01aa", "0x01bb"},
+			},
+		},
+		{
+			name: "Set code",
+			response: `{"type":"0x4","hash":"0x1","authorizationList":[{"chainId":"0x1","address":"0xd","nonce":"0x0",
+				"yParity":"0x0","r":"0xr","s":"0xs"}]}`,
+			expected: ethereumrpcclient.Transaction{
+				Type: ethereumrpcclient.SetCodeTxType, Hash: "0x1",
+				AuthorizationList: []ethereumrpcclient.Authorization{
+					{ChainId: "0x1", Address: "0xd", Nonce: "0x0", YParity: "0x0", R: "0xr", S: "0xs"},
+				},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var transaction ethereumrpcclient.Transaction
+			err := json.Unmarshal([]byte(c.response), &transaction)
+
+			assert.NoError(t, err, "Unexpected error")
+			assert.Equal(t, c.expected, transaction, "Transaction does not match expected")
+		})
+	}
+}
+
+func TestTransaction_Encode(t *testing.T) {
+	legacy, err := json.Marshal(ethereumrpcclient.Transaction{Hash: "0x1", Type: ethereumrpcclient.LegacyTxType, GasPrice: "0x1"})
+	assert.NoError(t, err, "Unexpected error")
+	assert.JSONEq(t, `{"Hash":"0x1","From":"","To":"","Value":"0x0","ValueEther":"0","BlockHash":"","BlockNumber":"","TransactionIndex":"",
+		"Type":"0x0","GasPrice":"0x1"}`, string(legacy), "Fields of other transaction types should be left out")
+
+	blob, err := json.Marshal(ethereumrpcclient.Transaction{Hash: "0x1", Type: ethereumrpcclient.BlobTxType, BlobVersionedHashes: []string{"0x01"}})
+	assert.NoError(t, err, "Unexpected error")
+	assert.Contains(t, string(blob), `"BlobVersionedHashes":["0x01"]`)
+
+	transfer, err := json.Marshal(ethereumrpcclient.Transaction{
+		Hash: "0x1", From: "0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed", Value: util.MustParseQuantity("0x1bc16d674ec80000"),
+	})
+	assert.NoError(t, err, "Unexpected error")
+	assert.Contains(t, string(transfer), `"Value":"0x1bc16d674ec80000"`, "Value should be given in wei")
+	assert.Contains(t, string(transfer), `"ValueEther":"2"`, "Value should be given in ether")
+	assert.Contains(t, string(transfer), `"From":"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"`, "Addresses should be checksummed")
+}
@@ -0,0 +1,373 @@
+package ethereumrpcclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"ethereum-parser/logger"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gorilla/websocket"
+)
+
+var ErrConnectionClosed = errors.New("websocket connection closed")
+
+// WebSocketClient talks JSON-RPC to a node over a single WebSocket connection,
+// which is dialed on first use and again after it drops.
+type WebSocketClient struct {
+	Url    string
+	Dialer *websocket.Dialer
+
+	mutex         sync.Mutex
+	writeMutex    sync.Mutex
+	conn          *websocket.Conn
+	done          chan struct{}
+	pending       map[int]*pendingCall
+	subscriptions map[string]*Subscription
+	requestID     atomic.Int64
+}
+
+type pendingCall struct {
+	response chan *JSONRPCResponse
+	// subscription is registered by the read loop as soon as the response
+	// arrives, so no notification sent right after it is lost
+	subscription *Subscription
+}
+
+// wsMessage is either a response to a call or an eth_subscription
+// notification.
+type wsMessage struct {
+	ID     *int            `json:"id"`
+	Method string          `json:"method"`
+	Result json.RawMessage `json:"result"`
+	Error  RPCError        `json:"error"`
+	Params struct {
+		Subscription string          `json:"subscription"`
+		Result       json.RawMessage `json:"result"`
+	} `json:"params"`
+}
+
+func NewWebSocketClient(url string) *WebSocketClient {
+	return &WebSocketClient{
+		Url:    url,
+		Dialer: websocket.DefaultDialer,
+	}
+}
+
+// connect dials the node unless a connection is already open and returns the
+// channel closed when that connection drops.
+func (c *WebSocketClient) connect(ctx context.Context) (*websocket.Conn, chan struct{}, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.conn != nil {
+		return c.conn, c.done, nil
+	}
+
+	if c.Url == "" {
+		return nil, nil, errors.New("ethereum websocket url is empty")
+	}
+
+	conn, _, err := c.Dialer.DialContext(ctx, c.Url, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error connecting to websocket, %w", err)
+	}
+
+	c.conn = conn
+	c.done = make(chan struct{})
+	c.pending = make(map[int]*pendingCall)
+	c.subscriptions = make(map[string]*Subscription)
+
+	go c.readLoop(conn, c.done)
+
+	return conn, c.done, nil
+}
+
+func (c *WebSocketClient) readLoop(conn *websocket.Conn, done chan struct{}) {
+	var err error
+	for {
+		var message wsMessage
+		if err = conn.ReadJSON(&message); err != nil {
+			break
+		}
+
+		c.handleMessage(&message)
+	}
+
+	c.mutex.Lock()
+	if c.conn == conn {
+		c.conn = nil
+	}
+	pending := c.pending
+	subscriptions := c.subscriptions
+	c.pending = nil
+	c.subscriptions = nil
+	c.mutex.Unlock()
+
+	conn.Close()
+	close(done)
+
+	for _, call := range pending {
+		close(call.response)
+	}
+
+	for _, subscription := range subscriptions {
+		subscription.fail(fmt.Errorf("%w, %s", ErrConnectionClosed, err.Error()))
+	}
+}
+
+func (c *WebSocketClient) handleMessage(message *wsMessage) {
+	if message.ID == nil {
+		if message.Method != "eth_subscription" {
+			return
+		}
+
+		c.mutex.Lock()
+		subscription := c.subscriptions[message.Params.Subscription]
+		c.mutex.Unlock()
+
+		if subscription != nil {
+			subscription.notify(message.Params.Result)
+		}
+		return
+	}
+
+	c.mutex.Lock()
+	call := c.pending[*message.ID]
+	delete(c.pending, *message.ID)
+	if call != nil && call.subscription != nil && message.Error.Code == 0 {
+		if err := json.Unmarshal(message.Result, &call.subscription.ID); err == nil {
+			c.subscriptions[call.subscription.ID] = call.subscription
+		}
+	}
+	c.mutex.Unlock()
+
+	if call != nil {
+		call.response <- &JSONRPCResponse{
+			JSONRPC: "2.0",
+			Result:  message.Result,
+			ID:      *message.ID,
+			Error:   message.Error,
+		}
+	}
+}
+
+func (c *WebSocketClient) CallJSONRPC(ctx context.Context, method string, params []interface{}) (json.RawMessage, error) {
+	return c.call(ctx, method, params, nil)
+}
+
+func (c *WebSocketClient) call(ctx context.Context, method string, params []interface{}, subscription *Subscription) (json.RawMessage, error) {
+	if method == "" {
+		return nil, errors.New("method is empty")
+	}
+
+	conn, done, err := c.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	request := JSONRPCRequest{
+		JSONRPC: "2.0",
+		Method:  method,
+		Params:  params,
+		ID:      int(c.requestID.Add(1)),
+	}
+
+	call := &pendingCall{response: make(chan *JSONRPCResponse, 1), subscription: subscription}
+
+	c.mutex.Lock()
+	if c.conn != conn {
+		c.mutex.Unlock()
+		return nil, ErrConnectionClosed
+	}
+	c.pending[request.ID] = call
+	c.mutex.Unlock()
+
+	c.writeMutex.Lock()
+	err = conn.WriteJSON(request)
+	c.writeMutex.Unlock()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("error sending request, %w", err)
+	}
+
+	select {
+	case <-ctx.Done():
+		c.mutex.Lock()
+		if c.pending != nil {
+			delete(c.pending, request.ID)
+		}
+		c.mutex.Unlock()
+		return nil, ctx.Err()
+	case <-done:
+		return nil, ErrConnectionClosed
+	case response, ok := <-call.response:
+		if !ok {
+			return nil, ErrConnectionClosed
+		}
+		if response.Error.Code != 0 {
+			rpcErr := response.Error
+			return nil, &rpcErr
+		}
+		return response.Result, nil
+	}
+}
+
+// Close drops the connection, failing every pending call and subscription.
+func (c *WebSocketClient) Close() error {
+	c.mutex.Lock()
+	conn := c.conn
+	c.mutex.Unlock()
+
+	if conn == nil {
+		return nil
+	}
+
+	return conn.Close()
+}
+
+// Subscription is a live eth_subscribe subscription. Err delivers an error
+// once the subscription ends because the connection dropped.
+type Subscription struct {
+	ID string
+
+	client  *WebSocketClient
+	handler func(result json.RawMessage)
+	err     chan error
+	once    sync.Once
+}
+
+func (s *Subscription) Err() <-chan error {
+	return s.err
+}
+
+func (s *Subscription) notify(result json.RawMessage) {
+	s.handler(result)
+}
+
+func (s *Subscription) fail(err error) {
+	s.once.Do(func() {
+		s.err <- err
+		close(s.err)
+	})
+}
+
+// Unsubscribe cancels the subscription on the node.
+func (s *Subscription) Unsubscribe(ctx context.Context) error {
+	s.client.mutex.Lock()
+	active := s.client.subscriptions[s.ID] == s
+	if active {
+		delete(s.client.subscriptions, s.ID)
+	}
+	s.client.mutex.Unlock()
+
+	s.once.Do(func() {
+		close(s.err)
+	})
+
+	// The node drops subscriptions along with the connection
+	if !active {
+		return nil
+	}
+
+	_, err := s.client.CallJSONRPC(ctx, "eth_unsubscribe", []interface{}{s.ID})
+	if err != nil {
+		return fmt.Errorf("error unsubscribing %s, %w", s.ID, err)
+	}
+
+	return nil
+}
+
+// Subscribe calls eth_subscribe with params and passes every notification
+// result to handler, from the connection's read loop.
+func (c *WebSocketClient) Subscribe(ctx context.Context, handler func(result json.RawMessage), params ...interface{}) (*Subscription, error) {
+	subscription := &Subscription{
+		client:  c,
+		handler: handler,
+		err:     make(chan error, 1),
+	}
+
+	if _, err := c.call(ctx, "eth_subscribe", params, subscription); err != nil {
+		return nil, fmt.Errorf("error subscribing, %w", err)
+	}
+
+	return subscription, nil
+}
+
+// SubscribeNewHeads sends the header of every new chain head to heads. The
+// headers carry no transactions.
+func (c *WebSocketClient) SubscribeNewHeads(ctx context.Context, heads chan<- *Block) (*Subscription, error) {
+	return c.Subscribe(ctx, func(result json.RawMessage) {
+		var head Block
+		if err := json.Unmarshal(result, &head); err != nil {
+			logger.Logger.Warn("Error unmarshalling new head, " + err.Error())
+			return
+		}
+
+		select {
+		case heads <- &head:
+		default:
+			// The consumer only needs to know a new head arrived
+		}
+	}, "newHeads")
+}
+
+// HeadWatcher keeps a newHeads subscription alive, reconnecting with backoff
+// whenever the connection drops.
+type HeadWatcher struct {
+	Client      *WebSocketClient
+	RetryPolicy RetryPolicy
+
+	connected atomic.Bool
+}
+
+func NewHeadWatcher(client *WebSocketClient) *HeadWatcher {
+	return &HeadWatcher{
+		Client:      client,
+		RetryPolicy: DefaultRetryPolicy(),
+	}
+}
+
+// Connected reports whether heads are currently being pushed by the node.
+func (w *HeadWatcher) Connected() bool {
+	return w.connected.Load()
+}
+
+// Run sends new heads to heads until ctx is cancelled.
+func (w *HeadWatcher) Run(ctx context.Context, heads chan<- *Block) {
+	log := logger.Logger
+
+	defer w.Client.Close()
+
+	attempt := 0
+	for {
+		subscription, err := w.Client.SubscribeNewHeads(ctx, heads)
+		if err == nil {
+			attempt = 0
+			w.connected.Store(true)
+			log.Info("Subscribed to new heads on " + w.Client.Url)
+
+			select {
+			case <-ctx.Done():
+				w.connected.Store(false)
+				return
+			case err = <-subscription.Err():
+			}
+
+			w.connected.Store(false)
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		log.Warn("New heads subscription unavailable, polling until reconnected, " + err.Error())
+
+		attempt++
+		if err := sleep(ctx, w.RetryPolicy.Backoff(attempt)); err != nil {
+			return
+		}
+	}
+}
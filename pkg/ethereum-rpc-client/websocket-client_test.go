@@ -0,0 +1,186 @@
+package ethereumrpcclient_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"ethereum-parser/logger"
+	ethereumrpcclient "ethereum-parser/pkg/ethereum-rpc-client"
+)
+
+// newHeadsServer accepts newHeads subscriptions and pushes heads sent on
+// heads to every connection. Connections are dropped after dropAfter heads
+// when it is positive.
+func newHeadsServer(t *testing.T, heads <-chan int, dropAfter int, connections *int32) *httptest.Server {
+	upgrader := websocket.Upgrader{}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("Error upgrading connection, %v", err)
+			return
+		}
+		defer conn.Close()
+
+		var writeMutex sync.Mutex
+		write := func(message string) error {
+			writeMutex.Lock()
+			defer writeMutex.Unlock()
+			return conn.WriteMessage(websocket.TextMessage, []byte(message))
+		}
+
+		connection := atomic.AddInt32(connections, 1)
+		subscriptionID := `"0xsub` + strconv.Itoa(int(connection)) + `"`
+
+		var request ethereumrpcclient.JSONRPCRequest
+		if err := conn.ReadJSON(&request); err != nil {
+			return
+		}
+		if request.Method != "eth_subscribe" || request.Params[0] != "newHeads" {
+			t.Errorf("Unexpected request %s %v", request.Method, request.Params)
+			return
+		}
+		write(`{"jsonrpc":"2.0","result":` + subscriptionID + `,"id":` + strconv.Itoa(request.ID) + `}`)
+
+		// Answer eth_unsubscribe in the background
+		go func() {
+			for {
+				var request ethereumrpcclient.JSONRPCRequest
+				if err := conn.ReadJSON(&request); err != nil {
+					return
+				}
+				write(`{"jsonrpc":"2.0","result":true,"id":` + strconv.Itoa(request.ID) + `}`)
+			}
+		}()
+
+		sent := 0
+		for head := range heads {
+			notification := `{"jsonrpc":"2.0","method":"eth_subscription","params":{"subscription":` + subscriptionID +
+				`,"result":{"number":"0x` + strconv.FormatInt(int64(head), 16) + `","hash":"0x` + strconv.Itoa(head) + `"}}}`
+			if err := write(notification); err != nil {
+				return
+			}
+
+			sent++
+			if dropAfter > 0 && sent >= dropAfter {
+				return
+			}
+		}
+	}))
+}
+
+func wsURL(server *httptest.Server) string {
+	return "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+func receiveHead(t *testing.T, heads <-chan *ethereumrpcclient.Block) *ethereumrpcclient.Block {
+	select {
+	case head := <-heads:
+		return head
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for a new head")
+		return nil
+	}
+}
+
+func TestWebSocketClient_SubscribeNewHeads(t *testing.T) {
+	logger.Logger = zap.NewNop()
+
+	var connections int32
+	serverHeads := make(chan int)
+	server := newHeadsServer(t, serverHeads, 0, &connections)
+	defer server.Close()
+	defer close(serverHeads)
+
+	client := ethereumrpcclient.NewWebSocketClient(wsURL(server))
+	defer client.Close()
+
+	heads := make(chan *ethereumrpcclient.Block, 1)
+	subscription, err := client.SubscribeNewHeads(context.Background(), heads)
+
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, "0xsub1", subscription.ID)
+
+	serverHeads <- 16
+	assert.Equal(t, &ethereumrpcclient.Block{Number: "0x10", Hash: "0x16"}, receiveHead(t, heads))
+
+	assert.NoError(t, subscription.Unsubscribe(context.Background()), "Unexpected error")
+	_, open := <-subscription.Err()
+	assert.False(t, open, "Err should be closed after unsubscribing")
+}
+
+func TestWebSocketClient_CallErrors(t *testing.T) {
+	client := ethereumrpcclient.NewWebSocketClient("")
+	_, err := client.CallJSONRPC(context.Background(), "eth_blockNumber", []interface{}{})
+	assert.EqualError(t, err, "ethereum websocket url is empty")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		var request ethereumrpcclient.JSONRPCRequest
+		conn.ReadJSON(&request)
+		response, _ := json.Marshal(map[string]interface{}{
+			"jsonrpc": "2.0",
+			"id":      request.ID,
+			"error":   map[string]interface{}{"code": -32601, "message": "Method not found"},
+		})
+		conn.WriteMessage(websocket.TextMessage, response)
+		conn.ReadJSON(&request)
+	}))
+	defer server.Close()
+
+	client = ethereumrpcclient.NewWebSocketClient(wsURL(server))
+	defer client.Close()
+
+	_, err = client.CallJSONRPC(context.Background(), "eth_foo", []interface{}{})
+	assert.EqualError(t, err, "Method not found")
+}
+
+func TestHeadWatcher_Reconnect(t *testing.T) {
+	logger.Logger = zap.NewNop()
+
+	var connections int32
+	serverHeads := make(chan int)
+	server := newHeadsServer(t, serverHeads, 1, &connections)
+	defer server.Close()
+	defer close(serverHeads)
+
+	watcher := ethereumrpcclient.NewHeadWatcher(ethereumrpcclient.NewWebSocketClient(wsURL(server)))
+	watcher.RetryPolicy = ethereumrpcclient.RetryPolicy{InitialBackoff: 10 * time.Millisecond}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	heads := make(chan *ethereumrpcclient.Block, 1)
+	go watcher.Run(ctx, heads)
+
+	assert.Eventually(t, watcher.Connected, 2*time.Second, 5*time.Millisecond, "Watcher should connect")
+
+	// The server drops the connection after every head
+	serverHeads <- 1
+	assert.Equal(t, "0x1", receiveHead(t, heads).Number)
+
+	serverHeads <- 2
+	assert.Equal(t, "0x2", receiveHead(t, heads).Number, "Watcher should resubscribe after the connection drops")
+	assert.GreaterOrEqual(t, atomic.LoadInt32(&connections), int32(2))
+
+	cancel()
+	assert.Eventually(t, func() bool { return !watcher.Connected() }, 2*time.Second, 5*time.Millisecond,
+		"Watcher should disconnect when the context is cancelled")
+}
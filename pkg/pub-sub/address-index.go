@@ -0,0 +1,119 @@
+package pubsub
+
+import (
+	evm "ethereum-parser/pkg/ethereum-rpc-client"
+	"ethereum-parser/util"
+)
+
+// AddressIndex maps addresses to the subscribers watching them, so that a
+// block is scanned once however many subscribers there are. It is not safe for
+// concurrent use, the publisher guards it with its lock.
+type AddressIndex struct {
+	subscribers map[util.Address]map[*BlockSubscriber]struct{}
+	watched     map[*BlockSubscriber]map[util.Address]struct{}
+}
+
+func NewAddressIndex() *AddressIndex {
+	return &AddressIndex{
+		subscribers: make(map[util.Address]map[*BlockSubscriber]struct{}),
+		watched:     make(map[*BlockSubscriber]map[util.Address]struct{}),
+	}
+}
+
+// Add makes s watch addresses. A subscriber watching no address yet is still
+// registered, so that it only receives the blocks it asks for.
+func (i *AddressIndex) Add(s *BlockSubscriber, addresses ...util.Address) {
+	watched, ok := i.watched[s]
+	if !ok {
+		watched = make(map[util.Address]struct{})
+		i.watched[s] = watched
+	}
+
+	for _, address := range addresses {
+		watched[address] = struct{}{}
+
+		subscribers, ok := i.subscribers[address]
+		if !ok {
+			subscribers = make(map[*BlockSubscriber]struct{})
+			i.subscribers[address] = subscribers
+		}
+		subscribers[s] = struct{}{}
+	}
+}
+
+// Remove stops s from watching addresses.
+func (i *AddressIndex) Remove(s *BlockSubscriber, addresses ...util.Address) {
+	watched := i.watched[s]
+
+	for _, address := range addresses {
+		delete(watched, address)
+
+		subscribers := i.subscribers[address]
+		delete(subscribers, s)
+		if len(subscribers) == 0 {
+			delete(i.subscribers, address)
+		}
+	}
+}
+
+// RemoveSubscriber forgets s and everything it watches.
+func (i *AddressIndex) RemoveSubscriber(s *BlockSubscriber) {
+	for address := range i.watched[s] {
+		subscribers := i.subscribers[address]
+		delete(subscribers, s)
+		if len(subscribers) == 0 {
+			delete(i.subscribers, address)
+		}
+	}
+
+	delete(i.watched, s)
+}
+
+// Watching reports whether s was added to the index.
+func (i *AddressIndex) Watching(s *BlockSubscriber) bool {
+	_, ok := i.watched[s]
+	return ok
+}
+
+// Len returns the number of watched addresses.
+func (i *AddressIndex) Len() int {
+	return len(i.subscribers)
+}
+
+// Match returns the transactions of block involving an address watched by
+// each subscriber, in block order. Subscribers with no match are left out.
+func (i *AddressIndex) Match(block *evm.Block, addressesOf func(evm.Transaction) []util.Address) map[*BlockSubscriber][]evm.Transaction {
+	matches := make(map[*BlockSubscriber][]evm.Transaction)
+	if block == nil || len(i.subscribers) == 0 {
+		return matches
+	}
+
+	matched := make(map[*BlockSubscriber]struct{})
+	for _, tx := range block.Transactions {
+		for _, address := range addressesOf(tx) {
+			for s := range i.subscribers[address] {
+				// A transaction between two watched addresses is sent once
+				if _, ok := matched[s]; !ok {
+					matched[s] = struct{}{}
+					matches[s] = append(matches[s], tx)
+				}
+			}
+		}
+		clear(matched)
+	}
+
+	return matches
+}
+
+// TransactionAddresses returns the sender and recipient of tx, the default
+// addresses a publisher routes transactions by.
+func TransactionAddresses(tx evm.Transaction) []util.Address {
+	var addresses []util.Address
+	for _, hex := range []string{tx.From, tx.To} {
+		if address, err := util.ParseAddress(hex); err == nil {
+			addresses = append(addresses, address)
+		}
+	}
+
+	return addresses
+}
@@ -0,0 +1,122 @@
+package pubsub_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	evm "ethereum-parser/pkg/ethereum-rpc-client"
+	pubsub "ethereum-parser/pkg/pub-sub"
+	"ethereum-parser/pkg/storage"
+	"ethereum-parser/util"
+)
+
+func address(n int) util.Address {
+	return util.Address{0: byte(n >> 16), 1: byte(n >> 8), 2: byte(n)}
+}
+
+func TestBlockPublisher_Watch(t *testing.T) {
+	publisher := pubsub.NewBlockPublisher(storage.NewMemoryStorage(0))
+
+	watcher := pubsub.NewBlockSubscriber()
+	idle := pubsub.NewBlockSubscriber()
+	everything := pubsub.NewBlockSubscriber()
+	publisher.Subscribe(watcher)
+	publisher.Subscribe(idle)
+	publisher.Subscribe(everything)
+	publisher.Watch(watcher, address(1), address(2))
+	publisher.Watch(idle)
+
+	inbound := evm.Transaction{Hash: "0x1", From: address(3).Hex(), To: address(1).Hex()}
+	between := evm.Transaction{Hash: "0x2", From: address(1).Hex(), To: address(2).Hex()}
+	unrelated := evm.Transaction{Hash: "0x3", From: address(3).Hex(), To: address(4).Hex()}
+	block := &evm.Block{Number: "0x1", Transactions: []evm.Transaction{inbound, between, unrelated}}
+
+	publisher.Publish(block)
+
+	received := (<-watcher.Handler).Block
+	assert.Equal(t, "0x1", received.Number)
+	assert.Equal(t, []evm.Transaction{inbound, between}, received.Transactions, "Only watched transactions should be delivered, once each")
+	assert.Len(t, block.Transactions, 3, "The published block should not be modified")
+	assert.Equal(t, block, (<-everything.Handler).Block, "Subscribers not watching should get every block")
+	assert.Empty(t, idle.Handler, "Subscribers watching nothing should get no block")
+
+	publisher.Unwatch(watcher, address(1))
+	publisher.Publish(block)
+	assert.Equal(t, []evm.Transaction{between}, (<-watcher.Handler).Block.Transactions)
+
+	publisher.Unsubscribe(watcher)
+	publisher.Publish(block)
+	assert.Empty(t, watcher.Handler, "Unsubscribed subscribers should get nothing")
+}
+
+func TestAddressIndex_RemoveSubscriber(t *testing.T) {
+	index := pubsub.NewAddressIndex()
+	first := pubsub.NewBlockSubscriber()
+	second := pubsub.NewBlockSubscriber()
+
+	index.Add(first, address(1), address(2))
+	index.Add(second, address(2))
+	assert.Equal(t, 2, index.Len())
+
+	index.RemoveSubscriber(first)
+
+	assert.False(t, index.Watching(first))
+	assert.True(t, index.Watching(second))
+	assert.Equal(t, 1, index.Len(), "Addresses nobody watches should be dropped")
+}
+
+// benchmarkBlock returns a block of size transactions sent from unwatched
+// addresses to addresses from 0 to size-1.
+func benchmarkBlock(size int) *evm.Block {
+	block := &evm.Block{Number: "0x1"}
+	for i := 0; i < size; i++ {
+		block.Transactions = append(block.Transactions, evm.Transaction{
+			Hash: fmt.Sprintf("0x%x", i),
+			From: address(1<<20 + i).Lower(),
+			To:   address(i).Lower(),
+		})
+	}
+
+	return block
+}
+
+func BenchmarkBlockPublisher_Publish(b *testing.B) {
+	const transactions = 300
+
+	for _, connections := range []int{100, 1000, 5000} {
+		for _, addressesPerConnection := range []int{10, 1000} {
+			b.Run(fmt.Sprintf("connections=%d/addresses=%d", connections, addressesPerConnection), func(b *testing.B) {
+				publisher := pubsub.NewBlockPublisher(storage.NewMemoryStorage(0))
+				subscribers := make([]*pubsub.BlockSubscriber, connections)
+
+				for i := range subscribers {
+					subscribers[i] = pubsub.NewBlockSubscriber()
+					publisher.Subscribe(subscribers[i])
+
+					addresses := make([]util.Address, addressesPerConnection)
+					for j := range addresses {
+						addresses[j] = address((i*addressesPerConnection + j) % (1 << 20))
+					}
+					publisher.Watch(subscribers[i], addresses...)
+				}
+
+				block := benchmarkBlock(transactions)
+				b.ResetTimer()
+
+				for n := 0; n < b.N; n++ {
+					publisher.Publish(block)
+
+					b.StopTimer()
+					for _, s := range subscribers {
+						for len(s.Handler) > 0 {
+							<-s.Handler
+						}
+					}
+					b.StartTimer()
+				}
+			})
+		}
+	}
+}
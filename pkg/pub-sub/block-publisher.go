@@ -1,12 +1,11 @@
 package pubsub
 
 import (
-	"errors"
 	"sync"
 
 	evm "ethereum-parser/pkg/ethereum-rpc-client"
-
-	"github.com/gammazero/deque"
+	"ethereum-parser/pkg/storage"
+	"ethereum-parser/util"
 )
 
 /*
@@ -15,20 +14,29 @@ dev: This demo publisher including block storage and subscriber management.
 
 type BlockPublisher struct {
 	sync.Mutex
-	subs   map[*BlockSubscriber]bool
-	blocks *deque.Deque[evm.Block]
+	subs    map[*BlockSubscriber]bool
+	index   *AddressIndex
+	storage storage.Storage
+	head    HeadEvent
+
+	// AddressesOf returns the addresses involved in a transaction, which
+	// decide the subscribers it is routed to and the addresses stored blocks
+	// are indexed under. Defaults to TransactionAddresses.
+	AddressesOf func(tx evm.Transaction) []util.Address
 }
 
-var DefaultPublisher *BlockPublisher = NewBlockPublisher()
+var DefaultPublisher *BlockPublisher = NewBlockPublisher(storage.NewMemoryStorage(0))
 
 func SetDefaultPublisher(p *BlockPublisher) {
 	DefaultPublisher = p
 }
 
-func NewBlockPublisher() *BlockPublisher {
+func NewBlockPublisher(store storage.Storage) *BlockPublisher {
 	return &BlockPublisher{
-		subs:   make(map[*BlockSubscriber]bool),
-		blocks: deque.New[evm.Block](0),
+		subs:        make(map[*BlockSubscriber]bool),
+		index:       NewAddressIndex(),
+		storage:     store,
+		AddressesOf: TransactionAddresses,
 	}
 }
 
@@ -40,40 +48,122 @@ func (p *BlockPublisher) Subscribe(s *BlockSubscriber) error {
 	return nil
 }
 
+// Unsubscribe stops publishing to s and closes it.
 func (p *BlockPublisher) Unsubscribe(s *BlockSubscriber) error {
 	p.Lock()
 	delete(p.subs, s)
+	p.index.RemoveSubscriber(s)
 	p.Unlock()
 
+	s.Close()
+
 	return nil
 }
 
+// AddBlock stores block and indexes its transactions under the addresses
+// AddressesOf finds in them, for the history of each address.
 func (p *BlockPublisher) AddBlock(block *evm.Block) error {
+	if err := p.storage.AddBlock(block); err != nil {
+		return err
+	}
+
+	number, err := util.ParseBlockNumber(block.Number)
+	if err != nil {
+		return err
+	}
+
+	addresses := make(map[string][]int)
+	for i, tx := range block.Transactions {
+		for _, address := range p.AddressesOf(tx) {
+			addresses[address.Lower()] = append(addresses[address.Lower()], i)
+		}
+	}
+
+	return p.storage.IndexBlock(number, addresses)
+}
+
+// Watch delivers to s only the transactions of published blocks that involve
+// one of addresses, instead of every block. Calling it without addresses
+// switches s to that mode with nothing watched yet.
+func (p *BlockPublisher) Watch(s *BlockSubscriber, addresses ...util.Address) {
 	p.Lock()
-	p.blocks.PushBack(*block)
+	p.index.Add(s, addresses...)
 	p.Unlock()
+}
 
-	return nil
+// Unwatch stops delivering the transactions of addresses to s.
+func (p *BlockPublisher) Unwatch(s *BlockSubscriber, addresses ...util.Address) {
+	p.Lock()
+	p.index.Remove(s, addresses...)
+	p.Unlock()
 }
 
+// Publish sends block to every subscriber. Subscribers watching addresses get
+// a copy holding only their transactions, and nothing when it has none.
 func (p *BlockPublisher) Publish(block *evm.Block) error {
+	p.Lock()
+	defer p.Unlock()
+
+	for s, match := range p.index.Match(block, p.AddressesOf) {
+		if !p.subs[s] {
+			continue
+		}
+
+		filtered := *block
+		filtered.Transactions = match
+		s.Publish(&filtered)
+	}
+
+	for s := range p.subs {
+		if !p.index.Watching(s) {
+			s.Publish(block)
+		}
+	}
+
+	return nil
+}
+
+func (p *BlockPublisher) RemoveBlock(number int) error {
+	return p.storage.RemoveBlock(number)
+}
+
+func (p *BlockPublisher) PublishReorg(reorg *ReorgEvent) error {
 	p.Lock()
 	for s := range p.subs {
-		s.Publish(block)
+		s.PublishReorg(reorg)
 	}
 	p.Unlock()
 
 	return nil
 }
 
-func (p *BlockPublisher) GetLatestBlock() (*evm.Block, error) {
+func (p *BlockPublisher) PublishHead(head *HeadEvent) error {
+	p.Lock()
+	p.head = *head
+	for s := range p.subs {
+		s.PublishHead(head)
+	}
+	p.Unlock()
+
+	return nil
+}
+
+// GetHead returns the last head published.
+func (p *BlockPublisher) GetHead() HeadEvent {
 	p.Lock()
 	defer p.Unlock()
 
-	if p.blocks.Len() == 0 {
-		return nil, errors.New("No blocks available")
-	}
+	return p.head
+}
+
+func (p *BlockPublisher) GetLatestBlock() (*evm.Block, error) {
+	return p.storage.GetLatestBlock()
+}
+
+func (p *BlockPublisher) GetBlockByNumber(number int) (*evm.Block, error) {
+	return p.storage.GetBlockByNumber(number)
+}
 
-	blocks := p.blocks.Back()
-	return &blocks, nil
+func (p *BlockPublisher) Storage() storage.Storage {
+	return p.storage
 }
@@ -7,10 +7,11 @@ import (
 
 	evm "ethereum-parser/pkg/ethereum-rpc-client"
 	pubsub "ethereum-parser/pkg/pub-sub"
+	"ethereum-parser/pkg/storage"
 )
 
 func TestBlockPublisher_Subscribe(t *testing.T) {
-	publisher := pubsub.NewBlockPublisher()
+	publisher := pubsub.NewBlockPublisher(storage.NewMemoryStorage(0))
 	subscriber := pubsub.NewBlockSubscriber()
 
 	err := publisher.Subscribe(subscriber)
@@ -19,16 +20,19 @@ func TestBlockPublisher_Subscribe(t *testing.T) {
 }
 
 func TestBlockPublisher_Unsubscribe(t *testing.T) {
-	publisher := pubsub.NewBlockPublisher()
+	publisher := pubsub.NewBlockPublisher(storage.NewMemoryStorage(0))
 	subscriber := pubsub.NewBlockSubscriber()
 
 	err := publisher.Unsubscribe(subscriber)
 
 	assert.NoError(t, err, "Error should be nil")
+
+	_, open := <-subscriber.Quit
+	assert.False(t, open, "Unsubscribing should close the subscriber")
 }
 
 func TestBlockPublisher_AddBlock(t *testing.T) {
-	publisher := pubsub.NewBlockPublisher()
+	publisher := pubsub.NewBlockPublisher(storage.NewMemoryStorage(0))
 	block := &evm.Block{
 		Number: "0x1",
 		Hash:   "0x123",
@@ -40,7 +44,7 @@ func TestBlockPublisher_AddBlock(t *testing.T) {
 }
 
 func TestBlockPublisher_GetLatestBlock(t *testing.T) {
-	publisher := pubsub.NewBlockPublisher()
+	publisher := pubsub.NewBlockPublisher(storage.NewMemoryStorage(0))
 	block := &evm.Block{
 		Number: "0x1",
 		Hash:   "0x123",
@@ -53,3 +57,45 @@ func TestBlockPublisher_GetLatestBlock(t *testing.T) {
 	assert.NoError(t, err, "Error should be nil")
 	assert.Equal(t, block, latestBlock, "Latest block should match the added block")
 }
+
+func TestBlockPublisher_RemoveBlock(t *testing.T) {
+	publisher := pubsub.NewBlockPublisher(storage.NewMemoryStorage(0))
+	parent := &evm.Block{Number: "0x1", Hash: "0x123"}
+	orphan := &evm.Block{Number: "0x2", Hash: "0x456", ParentHash: "0x123"}
+
+	publisher.AddBlock(parent)
+	publisher.AddBlock(orphan)
+
+	err := publisher.RemoveBlock(2)
+	assert.NoError(t, err, "Error should be nil")
+
+	latestBlock, err := publisher.GetLatestBlock()
+
+	assert.NoError(t, err, "Error should be nil")
+	assert.Equal(t, parent, latestBlock, "Latest block should be the parent after removing the orphan")
+}
+
+func TestBlockPublisher_PublishReorg(t *testing.T) {
+	publisher := pubsub.NewBlockPublisher(storage.NewMemoryStorage(0))
+	subscriber := pubsub.NewBlockSubscriber()
+	publisher.Subscribe(subscriber)
+
+	reorg := &pubsub.ReorgEvent{CommonAncestor: 1}
+	err := publisher.PublishReorg(reorg)
+
+	assert.NoError(t, err, "Error should be nil")
+	assert.Equal(t, reorg, (<-subscriber.Handler).Reorg, "Subscriber should receive the reorg")
+}
+
+func TestBlockPublisher_PublishHead(t *testing.T) {
+	publisher := pubsub.NewBlockPublisher(storage.NewMemoryStorage(0))
+	subscriber := pubsub.NewBlockSubscriber()
+	publisher.Subscribe(subscriber)
+
+	head := &pubsub.HeadEvent{Latest: 100, Safe: 90, Finalized: 80}
+	err := publisher.PublishHead(head)
+
+	assert.NoError(t, err, "Error should be nil")
+	assert.Equal(t, head, (<-subscriber.Handler).Head, "Subscriber should receive the head")
+	assert.Equal(t, *head, publisher.GetHead(), "Publisher should remember the last head")
+}
@@ -0,0 +1,167 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+
+	evm "ethereum-parser/pkg/ethereum-rpc-client"
+	"ethereum-parser/pkg/storage"
+	"ethereum-parser/util"
+)
+
+// MaxBlockRange is the largest number of blocks GetBlocks returns at once.
+const MaxBlockRange = 100
+
+// BlockReader serves the blocks kept by a publisher and fetches the others
+// from the node, without storing them. Blocks nobody has are reported with
+// storage.ErrBlockNotFound.
+type BlockReader struct {
+	publisher *BlockPublisher
+	client    evm.RPCClient
+}
+
+var DefaultBlockReader *BlockReader = NewBlockReader(DefaultPublisher, nil)
+
+func SetDefaultBlockReader(r *BlockReader) {
+	DefaultBlockReader = r
+}
+
+// NewBlockReader reads blocks from publisher, falling back to client when it
+// is not nil.
+func NewBlockReader(publisher *BlockPublisher, client evm.RPCClient) *BlockReader {
+	return &BlockReader{
+		publisher: publisher,
+		client:    client,
+	}
+}
+
+func (r *BlockReader) GetBlockByNumber(ctx context.Context, number int) (*evm.Block, error) {
+	block, err := r.publisher.storage.GetBlockByNumber(number)
+	if !errors.Is(err, storage.ErrBlockNotFound) || r.client == nil {
+		return block, err
+	}
+
+	block, err = r.client.GetBlockByNumber(ctx, number)
+	if err != nil {
+		return nil, fetchError(err)
+	}
+
+	return block, r.attachReceipts(ctx, []*evm.Block{block})
+}
+
+func (r *BlockReader) GetBlockByHash(ctx context.Context, hash string) (*evm.Block, error) {
+	block, err := r.publisher.storage.GetBlockByHash(hash)
+	if !errors.Is(err, storage.ErrBlockNotFound) || r.client == nil {
+		return block, err
+	}
+
+	block, err = r.client.GetBlockByHash(ctx, hash)
+	if err != nil {
+		return nil, fetchError(err)
+	}
+
+	return block, r.attachReceipts(ctx, []*evm.Block{block})
+}
+
+// GetBlocks returns the blocks from from to to, both included, in ascending
+// order. The range is cut at the latest block and at MaxBlockRange blocks.
+func (r *BlockReader) GetBlocks(ctx context.Context, from, to int) ([]evm.Block, error) {
+	if from < 0 || to < from {
+		return nil, errors.New("invalid block range")
+	}
+
+	latest, err := r.LatestBlockNumber(ctx)
+	if errors.Is(err, storage.ErrNoBlocks) {
+		return []evm.Block{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	to = min(to, latest, from+MaxBlockRange-1)
+	if to < from {
+		return []evm.Block{}, nil
+	}
+
+	stored, err := r.publisher.storage.GetBlocks(from, to, 0, false)
+	if err != nil {
+		return nil, err
+	}
+	if r.client == nil {
+		return append([]evm.Block{}, stored...), nil
+	}
+
+	// Fetch the gaps between the stored blocks
+	blocks := make([]evm.Block, 0, to-from+1)
+	next := from
+	for i := 0; next <= to; i++ {
+		end := to
+		if i < len(stored) {
+			number, err := util.ParseBlockNumber(stored[i].Number)
+			if err != nil {
+				return nil, err
+			}
+			end = number - 1
+		}
+
+		if next <= end {
+			fetched, err := r.client.GetBlocksByRange(ctx, next, end)
+			if err != nil {
+				return nil, fetchError(err)
+			}
+			if err := r.attachReceipts(ctx, fetched); err != nil {
+				return nil, err
+			}
+			for _, block := range fetched {
+				blocks = append(blocks, *block)
+			}
+		}
+
+		if i < len(stored) {
+			blocks = append(blocks, stored[i])
+		}
+		next = end + 2
+	}
+
+	return blocks, nil
+}
+
+// LatestBlockNumber returns the number of the latest block the publisher
+// knows of, asking the node when nothing was published yet.
+func (r *BlockReader) LatestBlockNumber(ctx context.Context) (int, error) {
+	if head := r.publisher.GetHead(); head.Latest > 0 {
+		return head.Latest, nil
+	}
+
+	block, err := r.publisher.storage.GetLatestBlock()
+	if err == nil {
+		return util.ParseBlockNumber(block.Number)
+	}
+	if !errors.Is(err, storage.ErrNoBlocks) || r.client == nil {
+		return 0, err
+	}
+
+	return r.client.GetBlockNumber(ctx)
+}
+
+// IsFinalized reports whether the block numbered number is final according to
+// the last published head, and so will never change.
+func (r *BlockReader) IsFinalized(number int) bool {
+	finalized := r.publisher.GetHead().Finalized
+	return finalized > 0 && number <= finalized
+}
+
+func (r *BlockReader) attachReceipts(ctx context.Context, blocks []*evm.Block) error {
+	if err := evm.AttachReceipts(ctx, r.client, blocks); err != nil {
+		return errors.New("error getting receipts, " + err.Error())
+	}
+
+	return nil
+}
+
+func fetchError(err error) error {
+	if errors.Is(err, evm.ErrBlockNotFound) {
+		return storage.ErrBlockNotFound
+	}
+
+	return errors.New("error fetching block, " + err.Error())
+}
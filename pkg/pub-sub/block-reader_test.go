@@ -0,0 +1,130 @@
+package pubsub_test
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	evm "ethereum-parser/pkg/ethereum-rpc-client"
+	pubsub "ethereum-parser/pkg/pub-sub"
+	"ethereum-parser/pkg/storage"
+)
+
+// nodeClient serves the blocks up to head, each with one transaction.
+type nodeClient struct {
+	evm.RPCClient
+	head  int
+	calls []string
+}
+
+func nodeBlock(number int) *evm.Block {
+	hex := "0x" + strconv.FormatInt(int64(number), 16)
+	return &evm.Block{
+		Number:       hex,
+		Hash:         "0xnode" + hex[2:],
+		Transactions: []evm.Transaction{{Hash: "0xtx" + hex[2:], BlockNumber: hex}},
+	}
+}
+
+func (c *nodeClient) GetBlockNumber(ctx context.Context) (int, error) {
+	return c.head, nil
+}
+
+func (c *nodeClient) GetBlockByNumber(ctx context.Context, number int) (*evm.Block, error) {
+	c.calls = append(c.calls, "number "+strconv.Itoa(number))
+	if number > c.head {
+		return nil, evm.ErrBlockNotFound
+	}
+	return nodeBlock(number), nil
+}
+
+func (c *nodeClient) GetBlockByHash(ctx context.Context, hash string) (*evm.Block, error) {
+	c.calls = append(c.calls, "hash "+hash)
+	return nil, evm.ErrBlockNotFound
+}
+
+func (c *nodeClient) GetBlocksByRange(ctx context.Context, from int, to int) ([]*evm.Block, error) {
+	c.calls = append(c.calls, "range "+strconv.Itoa(from)+"-"+strconv.Itoa(to))
+	var blocks []*evm.Block
+	for number := from; number <= to; number++ {
+		blocks = append(blocks, nodeBlock(number))
+	}
+	return blocks, nil
+}
+
+func (c *nodeClient) GetBlockReceiptsByRange(ctx context.Context, from int, to int) ([][]*evm.Receipt, error) {
+	var receipts [][]*evm.Receipt
+	for number := from; number <= to; number++ {
+		hex := strconv.FormatInt(int64(number), 16)
+		receipts = append(receipts, []*evm.Receipt{{TransactionHash: "0xtx" + hex, Status: "0x1"}})
+	}
+	return receipts, nil
+}
+
+func TestBlockReader(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStorage(0)
+	store.AddBlock(&evm.Block{Number: "0x3", Hash: "0xaa"})
+	store.AddBlock(&evm.Block{Number: "0x5", Hash: "0xbb"})
+
+	publisher := pubsub.NewBlockPublisher(store)
+	publisher.PublishHead(&pubsub.HeadEvent{Latest: 6, Finalized: 4})
+	client := &nodeClient{head: 6}
+	reader := pubsub.NewBlockReader(publisher, client)
+
+	block, err := reader.GetBlockByNumber(ctx, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, "0xaa", block.Hash, "Stored blocks should be served from storage")
+	assert.Empty(t, client.calls)
+
+	block, err = reader.GetBlockByNumber(ctx, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, "0xnode2", block.Hash, "Missing blocks should be fetched")
+	assert.Equal(t, "0x1", block.Transactions[0].Receipt.Status, "Fetched blocks should carry receipts")
+
+	_, err = reader.GetBlockByNumber(ctx, 7)
+	assert.ErrorIs(t, err, storage.ErrBlockNotFound)
+
+	block, err = reader.GetBlockByHash(ctx, "0xBB")
+	assert.NoError(t, err)
+	assert.Equal(t, "0x5", block.Number)
+
+	_, err = reader.GetBlockByHash(ctx, "0xcc")
+	assert.ErrorIs(t, err, storage.ErrBlockNotFound)
+
+	client.calls = nil
+	blocks, err := reader.GetBlocks(ctx, 2, 10)
+	assert.NoError(t, err)
+	var hashes []string
+	for _, block := range blocks {
+		hashes = append(hashes, block.Hash)
+	}
+	assert.Equal(t, []string{"0xnode2", "0xaa", "0xnode4", "0xbb", "0xnode6"}, hashes, "Ranges should stop at the latest block")
+	assert.Equal(t, []string{"range 2-2", "range 4-4", "range 6-6"}, client.calls, "Only the gaps should be fetched")
+
+	blocks, err = reader.GetBlocks(ctx, 0, 1000)
+	assert.NoError(t, err)
+	assert.Len(t, blocks, 7)
+
+	_, err = reader.GetBlocks(ctx, 5, 4)
+	assert.Error(t, err)
+
+	assert.True(t, reader.IsFinalized(4))
+	assert.False(t, reader.IsFinalized(5))
+}
+
+func TestBlockReader_WithoutClient(t *testing.T) {
+	ctx := context.Background()
+	reader := pubsub.NewBlockReader(pubsub.NewBlockPublisher(storage.NewMemoryStorage(0)), nil)
+
+	blocks, err := reader.GetBlocks(ctx, 0, 10)
+	assert.NoError(t, err)
+	assert.Empty(t, blocks)
+	assert.NotNil(t, blocks)
+
+	_, err = reader.GetBlockByNumber(ctx, 1)
+	assert.ErrorIs(t, err, storage.ErrBlockNotFound)
+	assert.False(t, reader.IsFinalized(0), "Nothing is final before a head is published")
+}
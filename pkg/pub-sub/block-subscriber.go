@@ -5,22 +5,79 @@ import (
 	"sync"
 )
 
+// Event carries either a newly published block, a chain reorganization or a
+// head update, in the order the listener observed them.
+type Event struct {
+	Block *evm.Block
+	Reorg *ReorgEvent
+	Head  *HeadEvent
+}
+
+// ReorgEvent reports the blocks that were dropped from the canonical chain,
+// oldest first, and the last block both chains have in common.
+type ReorgEvent struct {
+	CommonAncestor int
+	RemovedBlocks  []*evm.Block
+}
+
+// HeadEvent reports the latest, safe and finalized block numbers. Safe and
+// Finalized are 0 when the node does not support those block tags.
+type HeadEvent struct {
+	Latest    int
+	Safe      int
+	Finalized int
+}
+
+// BlockSubscriber receives events on Handler until it is closed, at which
+// point Quit is closed.
 type BlockSubscriber struct {
 	sync.Mutex
-	Handler chan *evm.Block
+	Handler chan *Event
 	Quit    chan struct{}
+	closed  bool
 }
 
 func NewBlockSubscriber() *BlockSubscriber {
 	return &BlockSubscriber{
-		Handler: make(chan *evm.Block, 1024),
+		Handler: make(chan *Event, 1024),
 		Quit:    make(chan struct{}),
 	}
 }
 
 func (s *BlockSubscriber) Publish(block *evm.Block) {
+	s.send(&Event{Block: block})
+}
+
+func (s *BlockSubscriber) PublishReorg(reorg *ReorgEvent) {
+	s.send(&Event{Reorg: reorg})
+}
+
+func (s *BlockSubscriber) PublishHead(head *HeadEvent) {
+	s.send(&Event{Head: head})
+}
+
+// Close stops the delivery of events and closes Quit. It is safe to call more
+// than once.
+func (s *BlockSubscriber) Close() {
+	s.Lock()
+	defer s.Unlock()
+
+	if !s.closed {
+		s.closed = true
+		close(s.Quit)
+	}
+}
+
+func (s *BlockSubscriber) send(event *Event) {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.closed {
+		return
+	}
+
 	select {
-	case s.Handler <- block:
+	case s.Handler <- event:
 	default:
 	}
 }
@@ -30,6 +30,34 @@ func TestBlockSubscriber_Publish(t *testing.T) {
 	subscriber.Publish(block)
 
 	// Assert that the block was received by the subscriber's handler channel
-	receivedBlock := <-subscriber.Handler
-	assert.Equal(t, block, receivedBlock, "Received block does not match published block")
+	receivedEvent := <-subscriber.Handler
+	assert.Equal(t, block, receivedEvent.Block, "Received block does not match published block")
+	assert.Nil(t, receivedEvent.Reorg, "Block event should not carry a reorg")
+}
+
+func TestBlockSubscriber_PublishReorg(t *testing.T) {
+	subscriber := pubsub.NewBlockSubscriber()
+
+	block := &evm.Block{Number: "0x2", Hash: "0x456"}
+	reorg := &pubsub.ReorgEvent{CommonAncestor: 1, RemovedBlocks: []*evm.Block{block}}
+
+	subscriber.Publish(block)
+	subscriber.PublishReorg(reorg)
+
+	// Events are delivered in the order they were published
+	assert.Equal(t, block, (<-subscriber.Handler).Block, "First event should be the block")
+	assert.Equal(t, reorg, (<-subscriber.Handler).Reorg, "Second event should be the reorg")
+}
+
+func TestBlockSubscriber_Close(t *testing.T) {
+	subscriber := pubsub.NewBlockSubscriber()
+
+	subscriber.Close()
+	subscriber.Close()
+
+	_, open := <-subscriber.Quit
+	assert.False(t, open, "Quit should be closed")
+
+	subscriber.Publish(&evm.Block{Number: "0x1"})
+	assert.Empty(t, subscriber.Handler, "Closed subscribers should not receive events")
 }
@@ -0,0 +1,743 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	evm "ethereum-parser/pkg/ethereum-rpc-client"
+	"ethereum-parser/util"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	blocksBucket        = []byte("blocks")
+	blockHashesBucket   = []byte("block_hashes")
+	transactionsBucket  = []byte("transactions")
+	addressIndexBucket  = []byte("address_index")
+	indexedBucket       = []byte("indexed_addresses")
+	subscriptionsBucket = []byte("subscriptions")
+	watchListsBucket    = []byte("watch_lists")
+	recordsBucket       = []byte("subscription_records")
+	deliveriesBucket    = []byte("webhook_deliveries")
+	metaBucket          = []byte("meta")
+
+	checkpointKey = []byte("checkpoint")
+)
+
+/*
+dev: BoltStorage keeps everything in a single bbolt file. Blocks are keyed by
+big-endian block number so the last key is always the latest block, and each
+address or subscription set gets its own nested bucket.
+*/
+
+type BoltStorage struct {
+	db        *bolt.DB
+	maxBlocks int
+}
+
+func NewBoltStorage(path string, maxBlocks int) (*BoltStorage, error) {
+	if path == "" {
+		return nil, errors.New("storage path is empty")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return nil, errors.New("error creating storage directory, " + err.Error())
+	}
+
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		return nil, errors.New("error opening storage, " + err.Error())
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{blocksBucket, blockHashesBucket, transactionsBucket, addressIndexBucket, indexedBucket, subscriptionsBucket, watchListsBucket, recordsBucket, deliveriesBucket, metaBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, errors.New("error creating storage buckets, " + err.Error())
+	}
+
+	return &BoltStorage{
+		db:        db,
+		maxBlocks: maxBlocks,
+	}, nil
+}
+
+func (s *BoltStorage) AddBlock(block *evm.Block) error {
+	if block == nil {
+		return errors.New("block is nil")
+	}
+
+	number, err := util.HexToDecimal(block.Number)
+	if err != nil {
+		return errors.New("error parsing block number, " + err.Error())
+	}
+
+	value, err := json.Marshal(block)
+	if err != nil {
+		return errors.New("error marshalling block, " + err.Error())
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		blocks := tx.Bucket(blocksBucket)
+		hashes := tx.Bucket(blockHashesBucket)
+
+		key := uint64Key(uint64(number))
+		// The replaced block is no longer found by its hash
+		if replaced := blocks.Get(key); replaced != nil {
+			var stale evm.Block
+			if err := json.Unmarshal(replaced, &stale); err == nil {
+				hashes.Delete([]byte(strings.ToLower(stale.Hash)))
+			}
+			if err := unindexBlock(tx, key); err != nil {
+				return err
+			}
+		}
+		if err := blocks.Put(key, value); err != nil {
+			return err
+		}
+		if err := hashes.Put([]byte(strings.ToLower(block.Hash)), key); err != nil {
+			return err
+		}
+
+		if s.maxBlocks <= 0 || int(number) < s.maxBlocks {
+			return nil
+		}
+
+		// Drop blocks that fell out of the retention window
+		threshold := uint64Key(uint64(int(number) - s.maxBlocks + 1))
+		cursor := blocks.Cursor()
+		for k, v := cursor.First(); k != nil && bytes.Compare(k, threshold) < 0; k, v = cursor.First() {
+			var stale evm.Block
+			if err := json.Unmarshal(v, &stale); err == nil {
+				hashes.Delete([]byte(strings.ToLower(stale.Hash)))
+			}
+			if err := unindexBlock(tx, append([]byte(nil), k...)); err != nil {
+				return err
+			}
+			if err := cursor.Delete(); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (s *BoltStorage) GetBlockByNumber(number int) (*evm.Block, error) {
+	var block *evm.Block
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(blocksBucket).Get(uint64Key(uint64(number)))
+		if value == nil {
+			return ErrBlockNotFound
+		}
+
+		var err error
+		block, err = decodeBlock(value)
+		return err
+	})
+
+	return block, err
+}
+
+func (s *BoltStorage) GetBlockByHash(hash string) (*evm.Block, error) {
+	var block *evm.Block
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		key := tx.Bucket(blockHashesBucket).Get([]byte(strings.ToLower(hash)))
+		if key == nil {
+			return ErrBlockNotFound
+		}
+
+		value := tx.Bucket(blocksBucket).Get(key)
+		if value == nil {
+			return ErrBlockNotFound
+		}
+
+		var err error
+		block, err = decodeBlock(value)
+		return err
+	})
+
+	return block, err
+}
+
+func (s *BoltStorage) GetLatestBlock() (*evm.Block, error) {
+	var block *evm.Block
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		_, value := tx.Bucket(blocksBucket).Cursor().Last()
+		if value == nil {
+			return ErrNoBlocks
+		}
+
+		var err error
+		block, err = decodeBlock(value)
+		return err
+	})
+
+	return block, err
+}
+
+func (s *BoltStorage) GetBlocks(from, to, limit int, descending bool) ([]evm.Block, error) {
+	var blocks []evm.Block
+	if from < 0 {
+		from = 0
+	}
+	if to < from {
+		return blocks, nil
+	}
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(blocksBucket).Cursor()
+		first, last := uint64Key(uint64(from)), uint64Key(uint64(to))
+
+		var k, v []byte
+		if descending {
+			// Seek lands on the first key after to when to is not stored
+			k, v = cursor.Seek(last)
+			if k == nil {
+				k, v = cursor.Last()
+			} else if bytes.Compare(k, last) > 0 {
+				k, v = cursor.Prev()
+			}
+		} else {
+			k, v = cursor.Seek(first)
+		}
+
+		for k != nil && bytes.Compare(k, first) >= 0 && bytes.Compare(k, last) <= 0 {
+			block, err := decodeBlock(v)
+			if err != nil {
+				return err
+			}
+			blocks = append(blocks, *block)
+			if limit > 0 && len(blocks) == limit {
+				return nil
+			}
+
+			if descending {
+				k, v = cursor.Prev()
+			} else {
+				k, v = cursor.Next()
+			}
+		}
+
+		return nil
+	})
+
+	return blocks, err
+}
+
+func (s *BoltStorage) RemoveBlock(number int) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		blocks := tx.Bucket(blocksBucket)
+
+		key := uint64Key(uint64(number))
+		value := blocks.Get(key)
+		if value == nil {
+			return nil
+		}
+
+		block, err := decodeBlock(value)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Bucket(blockHashesBucket).Delete([]byte(strings.ToLower(block.Hash))); err != nil {
+			return err
+		}
+		if err := unindexBlock(tx, key); err != nil {
+			return err
+		}
+
+		return blocks.Delete(key)
+	})
+}
+
+func (s *BoltStorage) IndexBlock(number int, addresses map[string][]int) error {
+	indexed := make([]string, 0, len(addresses))
+	for address := range addresses {
+		indexed = append(indexed, strings.ToLower(address))
+	}
+
+	value, err := json.Marshal(indexed)
+	if err != nil {
+		return errors.New("error marshalling indexed addresses, " + err.Error())
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		key := uint64Key(uint64(number))
+		if tx.Bucket(blocksBucket).Get(key) == nil {
+			return ErrBlockNotFound
+		}
+
+		if err := unindexBlock(tx, key); err != nil {
+			return err
+		}
+
+		for address, indexes := range addresses {
+			bucket, err := tx.Bucket(addressIndexBucket).CreateBucketIfNotExists([]byte(strings.ToLower(address)))
+			if err != nil {
+				return err
+			}
+
+			for _, index := range indexes {
+				if err := bucket.Put(positionKey(TransactionPosition{Block: number, Index: index}), []byte{1}); err != nil {
+					return err
+				}
+			}
+		}
+
+		return tx.Bucket(indexedBucket).Put(key, value)
+	})
+}
+
+func (s *BoltStorage) GetTransactionPositions(address string, from, to TransactionPosition, limit int, descending bool) ([]TransactionPosition, error) {
+	positions := []TransactionPosition{}
+
+	// Keys hold unsigned numbers
+	if from.Block < 0 {
+		from = TransactionPosition{}
+	}
+	from.Index = max(from.Index, 0)
+	if to.Index < 0 {
+		to = TransactionPosition{Block: to.Block - 1, Index: math.MaxInt}
+	}
+	if to.Block < 0 || comparePositions(from, to) > 0 {
+		return positions, nil
+	}
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(addressIndexBucket).Bucket([]byte(strings.ToLower(address)))
+		if bucket == nil {
+			return nil
+		}
+
+		cursor := bucket.Cursor()
+		first, last := positionKey(from), positionKey(to)
+
+		var k []byte
+		if descending {
+			k, _ = cursor.Seek(last)
+			if k == nil {
+				k, _ = cursor.Last()
+			} else if bytes.Compare(k, last) > 0 {
+				k, _ = cursor.Prev()
+			}
+		} else {
+			k, _ = cursor.Seek(first)
+		}
+
+		for k != nil && bytes.Compare(k, first) >= 0 && bytes.Compare(k, last) <= 0 {
+			positions = append(positions, TransactionPosition{
+				Block: int(binary.BigEndian.Uint64(k[:8])),
+				Index: int(binary.BigEndian.Uint64(k[8:])),
+			})
+			if limit > 0 && len(positions) == limit {
+				return nil
+			}
+
+			if descending {
+				k, _ = cursor.Prev()
+			} else {
+				k, _ = cursor.Next()
+			}
+		}
+
+		return nil
+	})
+
+	return positions, err
+}
+
+func (s *BoltStorage) AddTransaction(address string, transaction evm.Transaction) error {
+	value, err := json.Marshal(transaction)
+	if err != nil {
+		return errors.New("error marshalling transaction, " + err.Error())
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.Bucket(transactionsBucket).CreateBucketIfNotExists([]byte(strings.ToLower(address)))
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put(transactionKey(transaction), value)
+	})
+}
+
+func (s *BoltStorage) GetTransactions(address string) ([]evm.Transaction, error) {
+	transactions := []evm.Transaction{}
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(transactionsBucket).Bucket([]byte(strings.ToLower(address)))
+		if bucket == nil {
+			return nil
+		}
+
+		return bucket.ForEach(func(_, value []byte) error {
+			var transaction evm.Transaction
+			if err := json.Unmarshal(value, &transaction); err != nil {
+				return errors.New("error unmarshalling transaction, " + err.Error())
+			}
+
+			transactions = append(transactions, transaction)
+			return nil
+		})
+	})
+
+	return transactions, err
+}
+
+func (s *BoltStorage) RemoveTransaction(address string, transaction evm.Transaction) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(transactionsBucket).Bucket([]byte(strings.ToLower(address)))
+		if bucket == nil {
+			return nil
+		}
+
+		return bucket.Delete(transactionKey(transaction))
+	})
+}
+
+func (s *BoltStorage) RemoveTransactions(address string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		err := tx.Bucket(transactionsBucket).DeleteBucket([]byte(strings.ToLower(address)))
+		if errors.Is(err, bolt.ErrBucketNotFound) {
+			return nil
+		}
+		return err
+	})
+}
+
+func (s *BoltStorage) AddSubscription(set string, address string) error {
+	if set == "" {
+		return errors.New("subscription set is empty")
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.Bucket(subscriptionsBucket).CreateBucketIfNotExists([]byte(set))
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put([]byte(strings.ToLower(address)), []byte{1})
+	})
+}
+
+func (s *BoltStorage) RemoveSubscription(set string, address string) error {
+	if set == "" {
+		return errors.New("subscription set is empty")
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(subscriptionsBucket).Bucket([]byte(set))
+		if bucket == nil {
+			return nil
+		}
+
+		return bucket.Delete([]byte(strings.ToLower(address)))
+	})
+}
+
+func (s *BoltStorage) GetSubscriptions(set string) ([]string, error) {
+	addresses := []string{}
+
+	if set == "" {
+		return addresses, nil
+	}
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(subscriptionsBucket).Bucket([]byte(set))
+		if bucket == nil {
+			return nil
+		}
+
+		return bucket.ForEach(func(key, _ []byte) error {
+			addresses = append(addresses, string(key))
+			return nil
+		})
+	})
+
+	return addresses, err
+}
+
+func (s *BoltStorage) SaveWatchList(list WatchList) error {
+	if list.ID == "" {
+		return errors.New("watch-list id is empty")
+	}
+
+	value, err := json.Marshal(list)
+	if err != nil {
+		return errors.New("error marshalling watch-list, " + err.Error())
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(watchListsBucket).Put([]byte(list.ID), value)
+	})
+}
+
+func (s *BoltStorage) GetWatchList(id string) (*WatchList, error) {
+	var list *WatchList
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(watchListsBucket).Get([]byte(id))
+		if value == nil {
+			return ErrWatchListNotFound
+		}
+
+		list = &WatchList{}
+		if err := json.Unmarshal(value, list); err != nil {
+			return errors.New("error unmarshalling watch-list, " + err.Error())
+		}
+		return nil
+	})
+
+	return list, err
+}
+
+func (s *BoltStorage) GetWatchLists() ([]WatchList, error) {
+	lists := []WatchList{}
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(watchListsBucket).ForEach(func(_, value []byte) error {
+			var list WatchList
+			if err := json.Unmarshal(value, &list); err != nil {
+				return errors.New("error unmarshalling watch-list, " + err.Error())
+			}
+			lists = append(lists, list)
+			return nil
+		})
+	})
+
+	return lists, err
+}
+
+func (s *BoltStorage) DeleteWatchList(id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(watchListsBucket).Delete([]byte(id)); err != nil {
+			return err
+		}
+
+		err := tx.Bucket(subscriptionsBucket).DeleteBucket([]byte(WatchListSet(id)))
+		if err != nil && !errors.Is(err, bolt.ErrBucketNotFound) {
+			return err
+		}
+		return nil
+	})
+}
+
+func (s *BoltStorage) SaveSubscription(subscription Subscription) error {
+	if subscription.ID == "" {
+		return errors.New("subscription id is empty")
+	}
+
+	value, err := json.Marshal(subscription)
+	if err != nil {
+		return errors.New("error marshalling subscription, " + err.Error())
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(recordsBucket).Put([]byte(subscription.ID), value)
+	})
+}
+
+func (s *BoltStorage) GetSubscription(id string) (*Subscription, error) {
+	var subscription *Subscription
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(recordsBucket).Get([]byte(id))
+		if value == nil {
+			return ErrSubscriptionNotFound
+		}
+
+		subscription = &Subscription{}
+		if err := json.Unmarshal(value, subscription); err != nil {
+			return errors.New("error unmarshalling subscription, " + err.Error())
+		}
+		return nil
+	})
+
+	return subscription, err
+}
+
+func (s *BoltStorage) ListSubscriptions() ([]Subscription, error) {
+	subscriptions := []Subscription{}
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(recordsBucket).ForEach(func(_, value []byte) error {
+			var subscription Subscription
+			if err := json.Unmarshal(value, &subscription); err != nil {
+				return errors.New("error unmarshalling subscription, " + err.Error())
+			}
+			subscriptions = append(subscriptions, subscription)
+			return nil
+		})
+	})
+
+	return subscriptions, err
+}
+
+func (s *BoltStorage) DeleteSubscription(id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(recordsBucket).Delete([]byte(id))
+	})
+}
+
+func (s *BoltStorage) SaveDelivery(delivery Delivery) error {
+	if delivery.ID == "" {
+		return errors.New("delivery id is empty")
+	}
+
+	value, err := json.Marshal(delivery)
+	if err != nil {
+		return errors.New("error marshalling delivery, " + err.Error())
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(deliveriesBucket).Put([]byte(delivery.ID), value)
+	})
+}
+
+func (s *BoltStorage) GetDelivery(id string) (*Delivery, error) {
+	var delivery *Delivery
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(deliveriesBucket).Get([]byte(id))
+		if value == nil {
+			return ErrDeliveryNotFound
+		}
+
+		delivery = &Delivery{}
+		if err := json.Unmarshal(value, delivery); err != nil {
+			return errors.New("error unmarshalling delivery, " + err.Error())
+		}
+		return nil
+	})
+
+	return delivery, err
+}
+
+func (s *BoltStorage) GetDeliveries(status string) ([]Delivery, error) {
+	deliveries := []Delivery{}
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(deliveriesBucket).ForEach(func(_, value []byte) error {
+			var delivery Delivery
+			if err := json.Unmarshal(value, &delivery); err != nil {
+				return errors.New("error unmarshalling delivery, " + err.Error())
+			}
+			if status == "" || delivery.Status == status {
+				deliveries = append(deliveries, delivery)
+			}
+			return nil
+		})
+	})
+	sortDeliveries(deliveries)
+
+	return deliveries, err
+}
+
+func (s *BoltStorage) SetCheckpoint(checkpoint Checkpoint) error {
+	value, err := json.Marshal(checkpoint)
+	if err != nil {
+		return errors.New("error marshalling checkpoint, " + err.Error())
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(metaBucket).Put(checkpointKey, value)
+	})
+}
+
+func (s *BoltStorage) GetCheckpoint() (*Checkpoint, error) {
+	var checkpoint *Checkpoint
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(metaBucket).Get(checkpointKey)
+		if value == nil {
+			return ErrNoCheckpoint
+		}
+
+		checkpoint = &Checkpoint{}
+		if err := json.Unmarshal(value, checkpoint); err != nil {
+			return errors.New("error unmarshalling checkpoint, " + err.Error())
+		}
+		return nil
+	})
+
+	return checkpoint, err
+}
+
+func (s *BoltStorage) Close() error {
+	return s.db.Close()
+}
+
+func decodeBlock(value []byte) (*evm.Block, error) {
+	var block evm.Block
+	if err := json.Unmarshal(value, &block); err != nil {
+		return nil, errors.New("error unmarshalling block, " + err.Error())
+	}
+
+	return &block, nil
+}
+
+func uint64Key(value uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, value)
+	return key
+}
+
+func positionKey(position TransactionPosition) []byte {
+	return append(uint64Key(uint64(position.Block)), uint64Key(uint64(position.Index))...)
+}
+
+// unindexBlock removes the positions indexed for the block stored under key.
+func unindexBlock(tx *bolt.Tx, key []byte) error {
+	indexed := tx.Bucket(indexedBucket)
+
+	value := indexed.Get(key)
+	if value == nil {
+		return nil
+	}
+
+	var addresses []string
+	if err := json.Unmarshal(value, &addresses); err != nil {
+		return errors.New("error unmarshalling indexed addresses, " + err.Error())
+	}
+
+	index := tx.Bucket(addressIndexBucket)
+	for _, address := range addresses {
+		bucket := index.Bucket([]byte(address))
+		if bucket == nil {
+			continue
+		}
+
+		cursor := bucket.Cursor()
+		for k, _ := cursor.Seek(key); k != nil && bytes.HasPrefix(k, key); k, _ = cursor.Seek(key) {
+			if err := cursor.Delete(); err != nil {
+				return err
+			}
+		}
+
+		if k, _ := cursor.First(); k == nil {
+			if err := index.DeleteBucket([]byte(address)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return indexed.Delete(key)
+}
@@ -0,0 +1,50 @@
+package storage_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	evm "ethereum-parser/pkg/ethereum-rpc-client"
+	"ethereum-parser/pkg/storage"
+)
+
+func TestBoltStorage(t *testing.T) {
+	testStorage(t, func(maxBlocks int) storage.Storage {
+		store, err := storage.NewBoltStorage(filepath.Join(t.TempDir(), "test.db"), maxBlocks)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return store
+	})
+}
+
+func TestBoltStorage_Reopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+
+	store, err := storage.NewBoltStorage(path, 0)
+	assert.NoError(t, err)
+
+	block := &evm.Block{Number: "0x10", Hash: "0xaa"}
+	store.AddBlock(block)
+	store.AddSubscription("default", "0xaa")
+	store.SetCheckpoint(storage.Checkpoint{Number: 16, Hash: "0xaa"})
+	assert.NoError(t, store.Close())
+
+	store, err = storage.NewBoltStorage(path, 0)
+	assert.NoError(t, err)
+	defer store.Close()
+
+	latest, err := store.GetLatestBlock()
+	assert.NoError(t, err)
+	assert.Equal(t, block, latest, "Block should survive a restart")
+
+	addresses, err := store.GetSubscriptions("default")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"0xaa"}, addresses, "Subscriptions should survive a restart")
+
+	checkpoint, err := store.GetCheckpoint()
+	assert.NoError(t, err)
+	assert.Equal(t, &storage.Checkpoint{Number: 16, Hash: "0xaa"}, checkpoint, "Checkpoint should survive a restart")
+}
@@ -0,0 +1,518 @@
+package storage
+
+import (
+	"errors"
+	evm "ethereum-parser/pkg/ethereum-rpc-client"
+	"ethereum-parser/util"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type MemoryStorage struct {
+	sync.RWMutex
+	maxBlocks     int
+	blocks        map[int]evm.Block
+	blockHashes   map[string]int                        // Block number by lowercase hash
+	numbers       []int                                 // Stored block numbers in ascending order
+	transactions  map[string]map[string]evm.Transaction // By transactionKey
+	positions     map[string][]TransactionPosition      // Indexed transactions by address, in order
+	indexed       map[int][]string                      // Indexed addresses by block number
+	subscriptions map[string]map[string]bool
+	watchLists    map[string]WatchList
+	records       map[string]Subscription
+	deliveries    map[string]Delivery
+	checkpoint    *Checkpoint
+}
+
+// NewMemoryStorage keeps the blocks within maxBlocks of the last added one, or
+// every block when maxBlocks is 0.
+func NewMemoryStorage(maxBlocks int) *MemoryStorage {
+	return &MemoryStorage{
+		maxBlocks:     maxBlocks,
+		blocks:        make(map[int]evm.Block),
+		blockHashes:   make(map[string]int),
+		transactions:  make(map[string]map[string]evm.Transaction),
+		positions:     make(map[string][]TransactionPosition),
+		indexed:       make(map[int][]string),
+		subscriptions: make(map[string]map[string]bool),
+		watchLists:    make(map[string]WatchList),
+		records:       make(map[string]Subscription),
+		deliveries:    make(map[string]Delivery),
+	}
+}
+
+func (s *MemoryStorage) AddBlock(block *evm.Block) error {
+	if block == nil {
+		return errors.New("block is nil")
+	}
+
+	number, err := util.HexToDecimal(block.Number)
+	if err != nil {
+		return errors.New("error parsing block number, " + err.Error())
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	s.putBlock(int(number), *block)
+
+	// Drop blocks that fell out of the retention window
+	threshold := int(number) - s.maxBlocks + 1
+	for s.maxBlocks > 0 && len(s.numbers) > 0 && s.numbers[0] < threshold {
+		s.deleteBlock(s.numbers[0])
+	}
+
+	return nil
+}
+
+func (s *MemoryStorage) GetBlockByNumber(number int) (*evm.Block, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	block, ok := s.blocks[number]
+	if !ok {
+		return nil, ErrBlockNotFound
+	}
+
+	return &block, nil
+}
+
+func (s *MemoryStorage) GetBlockByHash(hash string) (*evm.Block, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	number, ok := s.blockHashes[strings.ToLower(hash)]
+	if !ok {
+		return nil, ErrBlockNotFound
+	}
+
+	block := s.blocks[number]
+	return &block, nil
+}
+
+func (s *MemoryStorage) GetLatestBlock() (*evm.Block, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	if len(s.numbers) == 0 {
+		return nil, ErrNoBlocks
+	}
+
+	block := s.blocks[s.numbers[len(s.numbers)-1]]
+	return &block, nil
+}
+
+func (s *MemoryStorage) GetBlocks(from, to, limit int, descending bool) ([]evm.Block, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	// numbers is sorted, so the range is found without reading other blocks
+	first := sort.SearchInts(s.numbers, from)
+	end := sort.SearchInts(s.numbers, to+1)
+	if first >= end {
+		return []evm.Block{}, nil
+	}
+
+	count := end - first
+	if limit > 0 && count > limit {
+		count = limit
+	}
+
+	blocks := make([]evm.Block, 0, count)
+	for i := 0; i < count; i++ {
+		if descending {
+			blocks = append(blocks, s.blocks[s.numbers[end-1-i]])
+		} else {
+			blocks = append(blocks, s.blocks[s.numbers[first+i]])
+		}
+	}
+
+	return blocks, nil
+}
+
+func (s *MemoryStorage) RemoveBlock(number int) error {
+	s.Lock()
+	defer s.Unlock()
+
+	s.deleteBlock(number)
+
+	return nil
+}
+
+func (s *MemoryStorage) IndexBlock(number int, addresses map[string][]int) error {
+	s.Lock()
+	defer s.Unlock()
+
+	if _, ok := s.blocks[number]; !ok {
+		return ErrBlockNotFound
+	}
+
+	s.unindexBlock(number)
+
+	for address, indexes := range addresses {
+		address = strings.ToLower(address)
+
+		positions := s.positions[address]
+		for _, index := range indexes {
+			position := TransactionPosition{Block: number, Index: index}
+			i := sort.Search(len(positions), func(i int) bool { return comparePositions(positions[i], position) >= 0 })
+			if i < len(positions) && positions[i] == position {
+				continue
+			}
+
+			positions = append(positions, TransactionPosition{})
+			copy(positions[i+1:], positions[i:])
+			positions[i] = position
+		}
+
+		s.positions[address] = positions
+		s.indexed[number] = append(s.indexed[number], address)
+	}
+
+	return nil
+}
+
+func (s *MemoryStorage) GetTransactionPositions(address string, from, to TransactionPosition, limit int, descending bool) ([]TransactionPosition, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	positions := s.positions[strings.ToLower(address)]
+	first := sort.Search(len(positions), func(i int) bool { return comparePositions(positions[i], from) >= 0 })
+	end := sort.Search(len(positions), func(i int) bool { return comparePositions(positions[i], to) > 0 })
+	if first >= end {
+		return []TransactionPosition{}, nil
+	}
+
+	count := end - first
+	if limit > 0 && count > limit {
+		count = limit
+	}
+
+	result := make([]TransactionPosition, 0, count)
+	for i := 0; i < count; i++ {
+		if descending {
+			result = append(result, positions[end-1-i])
+		} else {
+			result = append(result, positions[first+i])
+		}
+	}
+
+	return result, nil
+}
+
+func (s *MemoryStorage) AddTransaction(address string, tx evm.Transaction) error {
+	s.Lock()
+	defer s.Unlock()
+
+	address = strings.ToLower(address)
+	if s.transactions[address] == nil {
+		s.transactions[address] = make(map[string]evm.Transaction)
+	}
+	s.transactions[address][string(transactionKey(tx))] = tx
+
+	return nil
+}
+
+func (s *MemoryStorage) GetTransactions(address string) ([]evm.Transaction, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	stored := s.transactions[strings.ToLower(address)]
+	keys := make([]string, 0, len(stored))
+	for key := range stored {
+		keys = append(keys, key)
+	}
+	// Keys sort by block and index, as the bolt cursor does
+	sort.Strings(keys)
+
+	transactions := make([]evm.Transaction, 0, len(keys))
+	for _, key := range keys {
+		transactions = append(transactions, stored[key])
+	}
+
+	return transactions, nil
+}
+
+func (s *MemoryStorage) RemoveTransaction(address string, tx evm.Transaction) error {
+	s.Lock()
+	defer s.Unlock()
+
+	delete(s.transactions[strings.ToLower(address)], string(transactionKey(tx)))
+
+	return nil
+}
+
+func (s *MemoryStorage) RemoveTransactions(address string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	delete(s.transactions, strings.ToLower(address))
+
+	return nil
+}
+
+func (s *MemoryStorage) AddSubscription(set string, address string) error {
+	if set == "" {
+		return errors.New("subscription set is empty")
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	if s.subscriptions[set] == nil {
+		s.subscriptions[set] = make(map[string]bool)
+	}
+	s.subscriptions[set][strings.ToLower(address)] = true
+
+	return nil
+}
+
+func (s *MemoryStorage) RemoveSubscription(set string, address string) error {
+	if set == "" {
+		return errors.New("subscription set is empty")
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	delete(s.subscriptions[set], strings.ToLower(address))
+	if len(s.subscriptions[set]) == 0 {
+		delete(s.subscriptions, set)
+	}
+
+	return nil
+}
+
+func (s *MemoryStorage) GetSubscriptions(set string) ([]string, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	addresses := make([]string, 0, len(s.subscriptions[set]))
+	for address := range s.subscriptions[set] {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+
+	return addresses, nil
+}
+
+func (s *MemoryStorage) SaveWatchList(list WatchList) error {
+	if list.ID == "" {
+		return errors.New("watch-list id is empty")
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	s.watchLists[list.ID] = list
+
+	return nil
+}
+
+func (s *MemoryStorage) GetWatchList(id string) (*WatchList, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	list, ok := s.watchLists[id]
+	if !ok {
+		return nil, ErrWatchListNotFound
+	}
+
+	return &list, nil
+}
+
+func (s *MemoryStorage) GetWatchLists() ([]WatchList, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	lists := make([]WatchList, 0, len(s.watchLists))
+	for _, list := range s.watchLists {
+		lists = append(lists, list)
+	}
+	sort.Slice(lists, func(i, j int) bool {
+		return lists[i].ID < lists[j].ID
+	})
+
+	return lists, nil
+}
+
+func (s *MemoryStorage) DeleteWatchList(id string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	delete(s.watchLists, id)
+	delete(s.subscriptions, WatchListSet(id))
+
+	return nil
+}
+
+func (s *MemoryStorage) SaveSubscription(subscription Subscription) error {
+	if subscription.ID == "" {
+		return errors.New("subscription id is empty")
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	s.records[subscription.ID] = subscription
+
+	return nil
+}
+
+func (s *MemoryStorage) GetSubscription(id string) (*Subscription, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	subscription, ok := s.records[id]
+	if !ok {
+		return nil, ErrSubscriptionNotFound
+	}
+
+	return &subscription, nil
+}
+
+func (s *MemoryStorage) ListSubscriptions() ([]Subscription, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	subscriptions := make([]Subscription, 0, len(s.records))
+	for _, subscription := range s.records {
+		subscriptions = append(subscriptions, subscription)
+	}
+	sort.Slice(subscriptions, func(i, j int) bool {
+		return subscriptions[i].ID < subscriptions[j].ID
+	})
+
+	return subscriptions, nil
+}
+
+func (s *MemoryStorage) DeleteSubscription(id string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	delete(s.records, id)
+
+	return nil
+}
+
+func (s *MemoryStorage) SaveDelivery(delivery Delivery) error {
+	if delivery.ID == "" {
+		return errors.New("delivery id is empty")
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	// Later changes to the caller's attempts must not reach the stored copy
+	delivery.Attempts = append([]DeliveryAttempt(nil), delivery.Attempts...)
+	s.deliveries[delivery.ID] = delivery
+
+	return nil
+}
+
+func (s *MemoryStorage) GetDelivery(id string) (*Delivery, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	delivery, ok := s.deliveries[id]
+	if !ok {
+		return nil, ErrDeliveryNotFound
+	}
+	delivery.Attempts = append([]DeliveryAttempt(nil), delivery.Attempts...)
+
+	return &delivery, nil
+}
+
+func (s *MemoryStorage) GetDeliveries(status string) ([]Delivery, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	deliveries := []Delivery{}
+	for _, delivery := range s.deliveries {
+		if status != "" && delivery.Status != status {
+			continue
+		}
+		delivery.Attempts = append([]DeliveryAttempt(nil), delivery.Attempts...)
+		deliveries = append(deliveries, delivery)
+	}
+	sortDeliveries(deliveries)
+
+	return deliveries, nil
+}
+
+func (s *MemoryStorage) SetCheckpoint(checkpoint Checkpoint) error {
+	s.Lock()
+	defer s.Unlock()
+
+	s.checkpoint = &checkpoint
+
+	return nil
+}
+
+func (s *MemoryStorage) GetCheckpoint() (*Checkpoint, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	if s.checkpoint == nil {
+		return nil, ErrNoCheckpoint
+	}
+
+	checkpoint := *s.checkpoint
+	return &checkpoint, nil
+}
+
+func (s *MemoryStorage) Close() error {
+	return nil
+}
+
+// putBlock stores block under number, replacing the block stored there. It
+// must be called with the storage locked.
+func (s *MemoryStorage) putBlock(number int, block evm.Block) {
+	if stored, ok := s.blocks[number]; ok {
+		delete(s.blockHashes, strings.ToLower(stored.Hash))
+		s.unindexBlock(number)
+	} else {
+		i := sort.SearchInts(s.numbers, number)
+		s.numbers = append(s.numbers, 0)
+		copy(s.numbers[i+1:], s.numbers[i:])
+		s.numbers[i] = number
+	}
+
+	s.blocks[number] = block
+	s.blockHashes[strings.ToLower(block.Hash)] = number
+}
+
+// deleteBlock removes the block stored under number, if any. It must be
+// called with the storage locked.
+func (s *MemoryStorage) deleteBlock(number int) {
+	block, ok := s.blocks[number]
+	if !ok {
+		return
+	}
+
+	delete(s.blocks, number)
+	delete(s.blockHashes, strings.ToLower(block.Hash))
+	s.unindexBlock(number)
+
+	i := sort.SearchInts(s.numbers, number)
+	s.numbers = append(s.numbers[:i], s.numbers[i+1:]...)
+}
+
+// unindexBlock removes the positions indexed for the block numbered number. It
+// must be called with the storage locked.
+func (s *MemoryStorage) unindexBlock(number int) {
+	for _, address := range s.indexed[number] {
+		positions := s.positions[address]
+		first := sort.Search(len(positions), func(i int) bool { return positions[i].Block >= number })
+		end := sort.Search(len(positions), func(i int) bool { return positions[i].Block > number })
+
+		if positions = append(positions[:first], positions[end:]...); len(positions) == 0 {
+			delete(s.positions, address)
+		} else {
+			s.positions[address] = positions
+		}
+	}
+
+	delete(s.indexed, number)
+}
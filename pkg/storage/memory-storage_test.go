@@ -0,0 +1,13 @@
+package storage_test
+
+import (
+	"testing"
+
+	"ethereum-parser/pkg/storage"
+)
+
+func TestMemoryStorage(t *testing.T) {
+	testStorage(t, func(maxBlocks int) storage.Storage {
+		return storage.NewMemoryStorage(maxBlocks)
+	})
+}
@@ -0,0 +1,181 @@
+package storage
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"ethereum-parser/config"
+	evm "ethereum-parser/pkg/ethereum-rpc-client"
+	"ethereum-parser/util"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNoBlocks      = errors.New("No blocks available")
+	ErrBlockNotFound = errors.New("block not found")
+	ErrNoCheckpoint  = errors.New("no checkpoint saved")
+
+	ErrWatchListNotFound    = errors.New("watch-list not found")
+	ErrSubscriptionNotFound = errors.New("subscription not found")
+	ErrDeliveryNotFound     = errors.New("delivery not found")
+)
+
+// Checkpoint is the last block the listener fully processed.
+type Checkpoint struct {
+	Number int    `json:"number"`
+	Hash   string `json:"hash"`
+}
+
+// WatchList is a named set of addresses kept server side. Its addresses are
+// stored as the subscription set WatchListSet(ID).
+type WatchList struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// WatchListSet returns the subscription set holding the addresses of the
+// watch-list id.
+func WatchListSet(id string) string {
+	return "watch-list:" + id
+}
+
+// Subscription is an address subscribed server side, through the REST API
+// rather than by a single connection.
+type Subscription struct {
+	ID                string `json:"id"`
+	Address           string `json:"address"`
+	ConfirmationDepth int    `json:"confirmationDepth,omitempty"`
+	ConfirmationTag   string `json:"confirmationTag,omitempty"`
+	CallbackURL       string `json:"callbackUrl,omitempty"`
+	Secret            string `json:"secret,omitempty"`
+}
+
+// Delivery is a webhook event sent to the callback URL of a subscription, with
+// every attempt made to send it.
+type Delivery struct {
+	ID             string            `json:"id"`
+	SubscriptionID string            `json:"subscriptionId"`
+	Event          string            `json:"event,omitempty"`
+	URL            string            `json:"url"`
+	Payload        json.RawMessage   `json:"payload"`
+	Signature      string            `json:"signature"`
+	Status         string            `json:"status"`
+	Attempts       []DeliveryAttempt `json:"attempts"`
+	CreatedAt      time.Time         `json:"createdAt"`
+}
+
+// DeliveryAttempt is a single request of a delivery. StatusCode is 0 when no
+// response was received.
+type DeliveryAttempt struct {
+	Time       time.Time `json:"time"`
+	StatusCode int       `json:"statusCode,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// TransactionPosition locates a transaction among the stored blocks, by block
+// number and index within the block.
+type TransactionPosition struct {
+	Block int `json:"block"`
+	Index int `json:"index"`
+}
+
+// Storage keeps the blocks published by the listener, the transaction history
+// of tracked addresses, named sets of subscribed addresses, watch-lists,
+// server side subscriptions and their webhook deliveries.
+type Storage interface {
+	// AddBlock stores block, replacing the block of the same number. With a
+	// retention of maxBlocks, blocks numbered maxBlocks or more below it are
+	// dropped.
+	AddBlock(block *evm.Block) error
+	GetBlockByNumber(number int) (*evm.Block, error)
+	GetBlockByHash(hash string) (*evm.Block, error)
+	GetLatestBlock() (*evm.Block, error)
+	// GetBlocks returns at most limit stored blocks numbered from to to, both
+	// included, in ascending or descending order. A limit of 0 returns them all.
+	GetBlocks(from, to, limit int, descending bool) ([]evm.Block, error)
+	RemoveBlock(number int) error
+	// IndexBlock records, by address, the indexes of the transactions of the
+	// stored block numbered number that involve it, replacing the entries
+	// recorded for the block before. The entries go along with the block.
+	IndexBlock(number int, addresses map[string][]int) error
+	// GetTransactionPositions returns at most limit indexed positions of the
+	// transactions of address from from to to, both included, in ascending or
+	// descending order. A limit of 0 returns them all.
+	GetTransactionPositions(address string, from, to TransactionPosition, limit int, descending bool) ([]TransactionPosition, error)
+
+	// AddTransaction stores tx under its block, index and hash, replacing the
+	// transaction stored with the same ones
+	AddTransaction(address string, tx evm.Transaction) error
+	// GetTransactions returns the transactions of address ordered by block
+	// and index
+	GetTransactions(address string) ([]evm.Transaction, error)
+	RemoveTransaction(address string, tx evm.Transaction) error
+	RemoveTransactions(address string) error
+
+	AddSubscription(set string, address string) error
+	RemoveSubscription(set string, address string) error
+	GetSubscriptions(set string) ([]string, error)
+
+	SaveWatchList(list WatchList) error
+	GetWatchList(id string) (*WatchList, error)
+	GetWatchLists() ([]WatchList, error)
+	// DeleteWatchList removes the watch-list along with its addresses
+	DeleteWatchList(id string) error
+
+	SaveSubscription(subscription Subscription) error
+	GetSubscription(id string) (*Subscription, error)
+	ListSubscriptions() ([]Subscription, error)
+	DeleteSubscription(id string) error
+
+	SaveDelivery(delivery Delivery) error
+	GetDelivery(id string) (*Delivery, error)
+	// GetDeliveries returns the deliveries with status, or all of them when it
+	// is empty, oldest first
+	GetDeliveries(status string) ([]Delivery, error)
+
+	SetCheckpoint(checkpoint Checkpoint) error
+	GetCheckpoint() (*Checkpoint, error)
+
+	Close() error
+}
+
+func NewStorage(storageConfig config.Storage) (Storage, error) {
+	switch storageConfig.Type {
+	case "", "memory":
+		return NewMemoryStorage(storageConfig.MaxBlocks), nil
+	case "bolt":
+		return NewBoltStorage(storageConfig.Path, storageConfig.MaxBlocks)
+	default:
+		return nil, errors.New("unknown storage type, " + storageConfig.Type)
+	}
+}
+
+func sortDeliveries(deliveries []Delivery) {
+	sort.Slice(deliveries, func(i, j int) bool {
+		if !deliveries[i].CreatedAt.Equal(deliveries[j].CreatedAt) {
+			return deliveries[i].CreatedAt.Before(deliveries[j].CreatedAt)
+		}
+		return deliveries[i].ID < deliveries[j].ID
+	})
+}
+
+// transactionKey orders an address history by block and position within the
+// block, falling back to the hash so unrelated transactions never collide. Both
+// storages key transactions by it.
+func transactionKey(transaction evm.Transaction) []byte {
+	blockNumber, _ := util.HexToDecimal(transaction.BlockNumber)
+	index, _ := util.HexToDecimal(transaction.TransactionIndex)
+
+	key := append(uint64Key(uint64(blockNumber)), uint64Key(uint64(index))...)
+	return append(key, []byte(strings.ToLower(transaction.Hash))...)
+}
+
+func comparePositions(a, b TransactionPosition) int {
+	if a.Block != b.Block {
+		return cmp.Compare(a.Block, b.Block)
+	}
+
+	return cmp.Compare(a.Index, b.Index)
+}
@@ -0,0 +1,488 @@
+package storage_test
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"ethereum-parser/config"
+	evm "ethereum-parser/pkg/ethereum-rpc-client"
+	"ethereum-parser/pkg/storage"
+)
+
+func TestNewStorage(t *testing.T) {
+	cases := []struct {
+		name          string
+		storageConfig config.Storage
+		expectedErr   string
+	}{
+		{
+			name:          "Default to memory",
+			storageConfig: config.Storage{},
+		},
+		{
+			name:          "Memory",
+			storageConfig: config.Storage{Type: "memory", MaxBlocks: 10},
+		},
+		{
+			name:          "Bolt",
+			storageConfig: config.Storage{Type: "bolt", Path: filepath.Join(t.TempDir(), "test.db")},
+		},
+		{
+			name:          "Bolt without path",
+			storageConfig: config.Storage{Type: "bolt"},
+			expectedErr:   "storage path is empty",
+		},
+		{
+			name:          "Unknown type",
+			storageConfig: config.Storage{Type: "redis"},
+			expectedErr:   "unknown storage type, redis",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			store, err := storage.NewStorage(c.storageConfig)
+
+			if c.expectedErr != "" {
+				assert.EqualError(t, err, c.expectedErr)
+				return
+			}
+
+			assert.NoError(t, err, "Unexpected error")
+			assert.NotNil(t, store, "Storage should not be nil")
+			assert.NoError(t, store.Close(), "Close should not fail")
+		})
+	}
+}
+
+// testStorage runs the behavior every Storage implementation must share.
+func testStorage(t *testing.T, newStorage func(maxBlocks int) storage.Storage) {
+	t.Run("Blocks", func(t *testing.T) {
+		store := newStorage(0)
+		defer store.Close()
+
+		_, err := store.GetLatestBlock()
+		assert.ErrorIs(t, err, storage.ErrNoBlocks)
+
+		first := &evm.Block{Number: "0x1", Hash: "0xaa"}
+		second := &evm.Block{Number: "0x2", Hash: "0xbb", ParentHash: "0xaa"}
+		assert.NoError(t, store.AddBlock(first))
+		assert.NoError(t, store.AddBlock(second))
+
+		latest, err := store.GetLatestBlock()
+		assert.NoError(t, err)
+		assert.Equal(t, second, latest, "Latest block should be the last added block")
+
+		block, err := store.GetBlockByNumber(1)
+		assert.NoError(t, err)
+		assert.Equal(t, first, block, "Block by number does not match")
+
+		block, err = store.GetBlockByHash("0xBB")
+		assert.NoError(t, err)
+		assert.Equal(t, second, block, "Block by hash does not match")
+
+		_, err = store.GetBlockByNumber(3)
+		assert.ErrorIs(t, err, storage.ErrBlockNotFound)
+
+		_, err = store.GetBlockByHash("0xcc")
+		assert.ErrorIs(t, err, storage.ErrBlockNotFound)
+	})
+
+	t.Run("Block ranges", func(t *testing.T) {
+		store := newStorage(0)
+		defer store.Close()
+
+		for _, number := range []string{"0x1", "0x2", "0x4", "0x5"} {
+			store.AddBlock(&evm.Block{Number: number, Hash: "0x" + number[2:]})
+		}
+
+		numbers := func(blocks []evm.Block, err error) []string {
+			assert.NoError(t, err)
+			result := []string{}
+			for _, block := range blocks {
+				result = append(result, block.Number)
+			}
+			return result
+		}
+
+		assert.Equal(t, []string{"0x2", "0x4", "0x5"}, numbers(store.GetBlocks(2, 10, 0, false)))
+		assert.Equal(t, []string{"0x4", "0x2"}, numbers(store.GetBlocks(0, 4, 2, true)))
+		assert.Equal(t, []string{"0x2", "0x1"}, numbers(store.GetBlocks(0, 3, 0, true)), "Missing upper bounds should be skipped")
+		assert.Equal(t, []string{"0x5"}, numbers(store.GetBlocks(0, 100, 1, true)))
+		assert.Equal(t, []string{"0x1"}, numbers(store.GetBlocks(0, 100, 1, false)))
+		assert.Equal(t, []string{}, numbers(store.GetBlocks(6, 100, 0, false)))
+		assert.Equal(t, []string{}, numbers(store.GetBlocks(3, 3, 0, true)))
+		assert.Equal(t, []string{}, numbers(store.GetBlocks(4, 2, 0, false)))
+	})
+
+	t.Run("Block retention", func(t *testing.T) {
+		cases := []struct {
+			name      string
+			maxBlocks int
+			added     []evm.Block
+			kept      []string
+			dropped   []string
+		}{
+			{
+				name:      "Window of block numbers",
+				maxBlocks: 2,
+				added:     []evm.Block{{Number: "0x1", Hash: "0xaa"}, {Number: "0x2", Hash: "0xbb"}, {Number: "0x3", Hash: "0xcc"}},
+				kept:      []string{"0xbb", "0xcc"},
+				dropped:   []string{"0xaa"},
+			},
+			{
+				name:      "Gaps are not counted",
+				maxBlocks: 3,
+				added:     []evm.Block{{Number: "0x1", Hash: "0xaa"}, {Number: "0x2", Hash: "0xbb"}, {Number: "0xa", Hash: "0xcc"}},
+				kept:      []string{"0xcc"},
+				dropped:   []string{"0xaa", "0xbb"},
+			},
+			{
+				name:      "Older blocks keep the window",
+				maxBlocks: 3,
+				added:     []evm.Block{{Number: "0x3", Hash: "0xaa"}, {Number: "0x1", Hash: "0xbb"}, {Number: "0x2", Hash: "0xcc"}},
+				kept:      []string{"0xbb", "0xcc", "0xaa"},
+			},
+			{
+				name:      "Same number replaces the block",
+				maxBlocks: 0,
+				added:     []evm.Block{{Number: "0x1", Hash: "0xaa"}, {Number: "0x2", Hash: "0xbb"}, {Number: "0x2", Hash: "0xcc"}},
+				kept:      []string{"0xaa", "0xcc"},
+				dropped:   []string{"0xbb"},
+			},
+		}
+
+		for _, c := range cases {
+			t.Run(c.name, func(t *testing.T) {
+				store := newStorage(c.maxBlocks)
+				defer store.Close()
+
+				for i := range c.added {
+					assert.NoError(t, store.AddBlock(&c.added[i]))
+				}
+
+				blocks, err := store.GetBlocks(0, 100, 0, false)
+				assert.NoError(t, err)
+				hashes := []string{}
+				for _, block := range blocks {
+					hashes = append(hashes, block.Hash)
+				}
+				assert.Equal(t, c.kept, hashes, "Kept blocks do not match")
+
+				for _, hash := range c.kept {
+					_, err := store.GetBlockByHash(hash)
+					assert.NoError(t, err, "Kept block should be found by hash")
+				}
+				for _, hash := range c.dropped {
+					_, err := store.GetBlockByHash(hash)
+					assert.ErrorIs(t, err, storage.ErrBlockNotFound, "Dropped block should not be found by hash")
+				}
+			})
+		}
+	})
+
+	t.Run("Remove block", func(t *testing.T) {
+		store := newStorage(0)
+		defer store.Close()
+
+		parent := &evm.Block{Number: "0x1", Hash: "0xaa"}
+		orphan := &evm.Block{Number: "0x2", Hash: "0xbb", ParentHash: "0xaa"}
+		store.AddBlock(parent)
+		store.AddBlock(orphan)
+
+		assert.NoError(t, store.RemoveBlock(2))
+		assert.NoError(t, store.RemoveBlock(5), "Removing a missing block should not fail")
+
+		_, err := store.GetBlockByHash("0xbb")
+		assert.ErrorIs(t, err, storage.ErrBlockNotFound)
+
+		latest, err := store.GetLatestBlock()
+		assert.NoError(t, err)
+		assert.Equal(t, parent, latest, "Latest block should be the parent")
+
+		// The replacement block at the same height is stored normally
+		replacement := &evm.Block{Number: "0x2", Hash: "0xcc", ParentHash: "0xaa"}
+		store.AddBlock(replacement)
+
+		block, err := store.GetBlockByNumber(2)
+		assert.NoError(t, err)
+		assert.Equal(t, replacement, block)
+	})
+
+	t.Run("Address index", func(t *testing.T) {
+		store := newStorage(3)
+		defer store.Close()
+
+		address := "0x7a250d5630B4cF539739dF2C5dAcb4c659F2488D"
+		other := "0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359"
+
+		assert.ErrorIs(t, store.IndexBlock(1, map[string][]int{address: {0}}), storage.ErrBlockNotFound, "Only stored blocks can be indexed")
+
+		for _, number := range []string{"0x1", "0x2", "0x3"} {
+			assert.NoError(t, store.AddBlock(&evm.Block{Number: number, Hash: "0xa" + number[2:]}))
+		}
+		assert.NoError(t, store.IndexBlock(1, map[string][]int{address: {0, 2}, other: {1}}))
+		assert.NoError(t, store.IndexBlock(2, map[string][]int{other: {0}}))
+		assert.NoError(t, store.IndexBlock(3, map[string][]int{address: {1}}))
+
+		positions := func(address string, from, to storage.TransactionPosition, limit int, descending bool) []storage.TransactionPosition {
+			result, err := store.GetTransactionPositions(address, from, to, limit, descending)
+			assert.NoError(t, err)
+			return result
+		}
+		all := storage.TransactionPosition{Block: 100}
+
+		assert.Equal(t, []storage.TransactionPosition{{Block: 1, Index: 0}, {Block: 1, Index: 2}, {Block: 3, Index: 1}},
+			positions(strings.ToLower(address), storage.TransactionPosition{}, all, 0, false))
+		assert.Equal(t, []storage.TransactionPosition{{Block: 3, Index: 1}, {Block: 1, Index: 2}},
+			positions(address, storage.TransactionPosition{}, all, 2, true))
+		assert.Equal(t, []storage.TransactionPosition{{Block: 1, Index: 2}},
+			positions(address, storage.TransactionPosition{Block: 1, Index: 1}, storage.TransactionPosition{Block: 2}, 0, false), "Bounds should apply within a block")
+		assert.Empty(t, positions(address, storage.TransactionPosition{Block: 2}, storage.TransactionPosition{Block: 2, Index: 9}, 0, false))
+
+		// Entries go along with replaced, removed and dropped blocks
+		assert.NoError(t, store.AddBlock(&evm.Block{Number: "0x3", Hash: "0xb3"}))
+		assert.NoError(t, store.RemoveBlock(2))
+		assert.NoError(t, store.AddBlock(&evm.Block{Number: "0x4", Hash: "0xa4"}))
+
+		assert.Empty(t, positions(address, storage.TransactionPosition{}, all, 0, false))
+		assert.Empty(t, positions(other, storage.TransactionPosition{}, all, 0, false))
+
+		assert.NoError(t, store.IndexBlock(4, map[string][]int{address: {0}}))
+		assert.NoError(t, store.IndexBlock(4, map[string][]int{other: {3}}), "Indexing again should replace the entries")
+		assert.Empty(t, positions(address, storage.TransactionPosition{}, all, 0, false))
+		assert.Equal(t, []storage.TransactionPosition{{Block: 4, Index: 3}}, positions(other, storage.TransactionPosition{}, all, 0, false))
+	})
+
+	t.Run("Transactions", func(t *testing.T) {
+		store := newStorage(0)
+		defer store.Close()
+
+		address := "0x7a250d5630B4cF539739dF2C5dAcb4c659F2488D"
+		first := evm.Transaction{Hash: "0x1", From: address, BlockNumber: "0x1", TransactionIndex: "0x0"}
+		second := evm.Transaction{Hash: "0x2", To: address, BlockNumber: "0x2", TransactionIndex: "0x0"}
+
+		transactions, err := store.GetTransactions(address)
+		assert.NoError(t, err)
+		assert.Empty(t, transactions)
+
+		assert.NoError(t, store.AddTransaction(address, first))
+		assert.NoError(t, store.AddTransaction(address, second))
+		assert.NoError(t, store.AddTransaction(address, first), "Adding a duplicate should not fail")
+
+		transactions, err = store.GetTransactions("0x7a250d5630b4cf539739df2c5dacb4c659f2488d")
+		assert.NoError(t, err)
+		assert.Equal(t, []evm.Transaction{first, second}, transactions, "Transactions do not match")
+
+		assert.NoError(t, store.RemoveTransaction(address, first))
+		assert.NoError(t, store.RemoveTransaction(address, first), "Removing twice should not fail")
+
+		transactions, err = store.GetTransactions(address)
+		assert.NoError(t, err)
+		assert.Equal(t, []evm.Transaction{second}, transactions, "Only the remaining transaction should be left")
+
+		assert.NoError(t, store.RemoveTransactions(address))
+		assert.NoError(t, store.RemoveTransactions(address), "Removing twice should not fail")
+
+		transactions, err = store.GetTransactions(address)
+		assert.NoError(t, err)
+		assert.Empty(t, transactions)
+	})
+
+	t.Run("Transaction keys", func(t *testing.T) {
+		address := "0x7a250d5630B4cF539739dF2C5dAcb4c659F2488D"
+		tx := func(hash, block, index, blockHash string) evm.Transaction {
+			return evm.Transaction{Hash: hash, BlockNumber: block, TransactionIndex: index, BlockHash: blockHash}
+		}
+
+		cases := []struct {
+			name     string
+			added    []evm.Transaction
+			removed  []evm.Transaction
+			expected []evm.Transaction
+		}{
+			{
+				name:     "Ordered by block and index",
+				added:    []evm.Transaction{tx("0x3", "0x2", "0x0", ""), tx("0x2", "0x1", "0x1", ""), tx("0x1", "0x1", "0x0", "")},
+				expected: []evm.Transaction{tx("0x1", "0x1", "0x0", ""), tx("0x2", "0x1", "0x1", ""), tx("0x3", "0x2", "0x0", "")},
+			},
+			{
+				name:     "Same hash in other blocks is kept apart",
+				added:    []evm.Transaction{tx("0x1", "0x1", "0x0", ""), tx("0x1", "0x2", "0x0", "")},
+				expected: []evm.Transaction{tx("0x1", "0x1", "0x0", ""), tx("0x1", "0x2", "0x0", "")},
+			},
+			{
+				name:     "Same key replaces the transaction",
+				added:    []evm.Transaction{tx("0x1", "0x1", "0x0", "0x1"), tx("0x1", "0x1", "0x0", "0x2")},
+				expected: []evm.Transaction{tx("0x1", "0x1", "0x0", "0x2")},
+			},
+			{
+				name:     "Removed by key",
+				added:    []evm.Transaction{tx("0x1", "0x1", "0x0", ""), tx("0x1", "0x2", "0x0", "")},
+				removed:  []evm.Transaction{tx("0x1", "0x1", "0x0", "")},
+				expected: []evm.Transaction{tx("0x1", "0x2", "0x0", "")},
+			},
+		}
+
+		for _, c := range cases {
+			t.Run(c.name, func(t *testing.T) {
+				store := newStorage(0)
+				defer store.Close()
+
+				for _, transaction := range c.added {
+					assert.NoError(t, store.AddTransaction(address, transaction))
+				}
+				for _, transaction := range c.removed {
+					assert.NoError(t, store.RemoveTransaction(address, transaction))
+				}
+
+				transactions, err := store.GetTransactions(address)
+				assert.NoError(t, err)
+				assert.Equal(t, c.expected, transactions)
+			})
+		}
+	})
+
+	t.Run("Subscriptions", func(t *testing.T) {
+		store := newStorage(0)
+		defer store.Close()
+
+		assert.EqualError(t, store.AddSubscription("", "0x1"), "subscription set is empty")
+
+		assert.NoError(t, store.AddSubscription("a", "0xBB"))
+		assert.NoError(t, store.AddSubscription("a", "0xaa"))
+		assert.NoError(t, store.AddSubscription("b", "0xcc"))
+
+		addresses, err := store.GetSubscriptions("a")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"0xaa", "0xbb"}, addresses)
+
+		assert.NoError(t, store.RemoveSubscription("a", "0xbb"))
+		assert.NoError(t, store.RemoveSubscription("missing", "0xbb"))
+
+		addresses, err = store.GetSubscriptions("a")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"0xaa"}, addresses)
+
+		addresses, err = store.GetSubscriptions("missing")
+		assert.NoError(t, err)
+		assert.Empty(t, addresses)
+	})
+
+	t.Run("WatchLists", func(t *testing.T) {
+		store := newStorage(0)
+		defer store.Close()
+
+		_, err := store.GetWatchList("a")
+		assert.ErrorIs(t, err, storage.ErrWatchListNotFound)
+		assert.EqualError(t, store.SaveWatchList(storage.WatchList{}), "watch-list id is empty")
+
+		assert.NoError(t, store.SaveWatchList(storage.WatchList{ID: "b", Name: "exchanges"}))
+		assert.NoError(t, store.SaveWatchList(storage.WatchList{ID: "a", Name: "treasury"}))
+		assert.NoError(t, store.AddSubscription(storage.WatchListSet("a"), "0xaa"))
+
+		list, err := store.GetWatchList("a")
+		assert.NoError(t, err)
+		assert.Equal(t, &storage.WatchList{ID: "a", Name: "treasury"}, list)
+
+		lists, err := store.GetWatchLists()
+		assert.NoError(t, err)
+		assert.Equal(t, []storage.WatchList{{ID: "a", Name: "treasury"}, {ID: "b", Name: "exchanges"}}, lists)
+
+		assert.NoError(t, store.DeleteWatchList("a"))
+		assert.NoError(t, store.DeleteWatchList("b"), "Lists without addresses should be deleted too")
+		assert.NoError(t, store.DeleteWatchList("missing"))
+
+		lists, err = store.GetWatchLists()
+		assert.NoError(t, err)
+		assert.Empty(t, lists)
+
+		addresses, err := store.GetSubscriptions(storage.WatchListSet("a"))
+		assert.NoError(t, err)
+		assert.Empty(t, addresses, "Addresses should be deleted with their list")
+	})
+
+	t.Run("Server subscriptions", func(t *testing.T) {
+		store := newStorage(0)
+		defer store.Close()
+
+		_, err := store.GetSubscription("a")
+		assert.ErrorIs(t, err, storage.ErrSubscriptionNotFound)
+		assert.EqualError(t, store.SaveSubscription(storage.Subscription{}), "subscription id is empty")
+
+		first := storage.Subscription{ID: "a", Address: "0xaa", ConfirmationDepth: 3}
+		second := storage.Subscription{ID: "b", Address: "0xbb", ConfirmationTag: "finalized"}
+		assert.NoError(t, store.SaveSubscription(second))
+		assert.NoError(t, store.SaveSubscription(first))
+
+		subscription, err := store.GetSubscription("a")
+		assert.NoError(t, err)
+		assert.Equal(t, &first, subscription)
+
+		subscriptions, err := store.ListSubscriptions()
+		assert.NoError(t, err)
+		assert.Equal(t, []storage.Subscription{first, second}, subscriptions)
+
+		assert.NoError(t, store.DeleteSubscription("a"))
+		assert.NoError(t, store.DeleteSubscription("missing"))
+
+		subscriptions, err = store.ListSubscriptions()
+		assert.NoError(t, err)
+		assert.Equal(t, []storage.Subscription{second}, subscriptions)
+	})
+
+	t.Run("Deliveries", func(t *testing.T) {
+		store := newStorage(0)
+		defer store.Close()
+
+		_, err := store.GetDelivery("a")
+		assert.ErrorIs(t, err, storage.ErrDeliveryNotFound)
+		assert.EqualError(t, store.SaveDelivery(storage.Delivery{}), "delivery id is empty")
+
+		created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		first := storage.Delivery{ID: "b", Payload: json.RawMessage(`{"id":"b"}`), Status: "failed", CreatedAt: created}
+		second := storage.Delivery{ID: "a", Payload: json.RawMessage(`{"id":"a"}`), Status: "pending", CreatedAt: created.Add(time.Second)}
+		assert.NoError(t, store.SaveDelivery(second))
+		assert.NoError(t, store.SaveDelivery(first))
+
+		second.Status = "delivered"
+		second.Attempts = []storage.DeliveryAttempt{{Time: created, StatusCode: 200}}
+		assert.NoError(t, store.SaveDelivery(second), "Saving again should replace the delivery")
+
+		delivery, err := store.GetDelivery("a")
+		assert.NoError(t, err)
+		assert.Equal(t, &second, delivery)
+
+		deliveries, err := store.GetDeliveries("")
+		assert.NoError(t, err)
+		assert.Equal(t, []storage.Delivery{first, second}, deliveries, "Deliveries should be sorted oldest first")
+
+		deliveries, err = store.GetDeliveries("failed")
+		assert.NoError(t, err)
+		assert.Equal(t, []storage.Delivery{first}, deliveries)
+
+		deliveries, err = store.GetDeliveries("pending")
+		assert.NoError(t, err)
+		assert.Empty(t, deliveries)
+	})
+
+	t.Run("Checkpoint", func(t *testing.T) {
+		store := newStorage(0)
+		defer store.Close()
+
+		_, err := store.GetCheckpoint()
+		assert.ErrorIs(t, err, storage.ErrNoCheckpoint)
+
+		assert.NoError(t, store.SetCheckpoint(storage.Checkpoint{Number: 1, Hash: "0xaa"}))
+		assert.NoError(t, store.SetCheckpoint(storage.Checkpoint{Number: 2, Hash: "0xbb"}))
+
+		checkpoint, err := store.GetCheckpoint()
+		assert.NoError(t, err)
+		assert.Equal(t, &storage.Checkpoint{Number: 2, Hash: "0xbb"}, checkpoint, "Latest checkpoint should win")
+	})
+}
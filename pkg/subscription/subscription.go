@@ -0,0 +1,245 @@
+package subscription
+
+import (
+	"errors"
+	ethereumParser "ethereum-parser/pkg/ethereum-parser"
+	"ethereum-parser/pkg/storage"
+	"ethereum-parser/util"
+	"sort"
+	"sync"
+)
+
+var ErrNotFound = storage.ErrSubscriptionNotFound
+
+// Subscription is an address subscribed server side. Unlike the subscriptions
+// of a WebSocket connection it outlives its creator, and is delivered to the
+// consumers attached to the registry. Subscriptions with a callback URL are
+// also delivered as webhooks signed with their secret, which is never
+// returned.
+type Subscription struct {
+	ID            string                            `json:"id"`
+	Address       util.Address                      `json:"address"`
+	Confirmations ethereumParser.ConfirmationPolicy `json:"confirmations"`
+	CallbackURL   string                            `json:"callbackUrl,omitempty"`
+	Secret        string                            `json:"-"`
+}
+
+// Change reports the subscriptions added to or removed from a registry.
+type Change struct {
+	Added   []Subscription
+	Removed []Subscription
+}
+
+// Listener is called with every change of the registry it is attached to. It
+// runs while the registry is locked, so changes reach it in order, and it must
+// not call back into the registry.
+type Listener func(change Change)
+
+// Registry keeps the server side subscriptions in storage, with an in-memory
+// copy for lookups, and tells attached listeners about their changes.
+type Registry struct {
+	mutex         sync.Mutex
+	storage       storage.Storage
+	subscriptions map[string]Subscription
+	listeners     map[*Listener]struct{}
+}
+
+var DefaultRegistry *Registry = newMemoryRegistry()
+
+func SetDefaultRegistry(r *Registry) {
+	DefaultRegistry = r
+}
+
+func newMemoryRegistry() *Registry {
+	r, _ := NewRegistry(storage.NewMemoryStorage(0))
+	return r
+}
+
+// NewRegistry loads the subscriptions saved in store.
+func NewRegistry(store storage.Storage) (*Registry, error) {
+	saved, err := store.ListSubscriptions()
+	if err != nil {
+		return nil, errors.New("error loading subscriptions, " + err.Error())
+	}
+
+	subscriptions := make(map[string]Subscription, len(saved))
+	for _, record := range saved {
+		subscription, err := fromRecord(record)
+		if err != nil {
+			return nil, errors.New("error loading subscriptions, " + err.Error())
+		}
+		subscriptions[subscription.ID] = subscription
+	}
+
+	return &Registry{
+		storage:       store,
+		subscriptions: subscriptions,
+		listeners:     make(map[*Listener]struct{}),
+	}, nil
+}
+
+// Add saves subscriptions under new ids and returns them. A subscription equal
+// to an existing one but for its id is not added twice, the existing one is
+// returned instead.
+func (r *Registry) Add(subscriptions ...Subscription) ([]Subscription, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var result, added []Subscription
+	for _, subscription := range subscriptions {
+		if existing, ok := r.find(subscription); ok {
+			result = append(result, existing)
+			continue
+		}
+
+		id, err := util.NewID()
+		if err != nil {
+			r.notify(Change{Added: added})
+			return nil, errors.New("error creating subscription, " + err.Error())
+		}
+		subscription.ID = id
+
+		if err := r.storage.SaveSubscription(subscription.record()); err != nil {
+			r.notify(Change{Added: added})
+			return nil, errors.New("error saving subscription, " + err.Error())
+		}
+		r.subscriptions[id] = subscription
+
+		result = append(result, subscription)
+		added = append(added, subscription)
+	}
+
+	r.notify(Change{Added: added})
+
+	return result, nil
+}
+
+func (r *Registry) Get(id string) (*Subscription, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	subscription, ok := r.subscriptions[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return &subscription, nil
+}
+
+// List returns every subscription ordered by address.
+func (r *Registry) List() []Subscription {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.list()
+}
+
+// Remove deletes the subscriptions ids and returns the ones that existed.
+func (r *Registry) Remove(ids ...string) ([]Subscription, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	removed := []Subscription{}
+	for _, id := range ids {
+		subscription, ok := r.subscriptions[id]
+		if !ok {
+			continue
+		}
+
+		if err := r.storage.DeleteSubscription(id); err != nil {
+			r.notify(Change{Removed: removed})
+			return nil, errors.New("error deleting subscription, " + err.Error())
+		}
+		delete(r.subscriptions, id)
+		removed = append(removed, subscription)
+	}
+
+	r.notify(Change{Removed: removed})
+
+	return removed, nil
+}
+
+// Attach calls listener with every subscription, as a change adding all of
+// them, then with every later change until the returned detach function is
+// called.
+func (r *Registry) Attach(listener Listener) func() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	listener(Change{Added: r.list()})
+
+	key := &listener
+	r.listeners[key] = struct{}{}
+
+	return func() {
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+
+		delete(r.listeners, key)
+	}
+}
+
+func (r *Registry) find(subscription Subscription) (Subscription, bool) {
+	for _, existing := range r.subscriptions {
+		subscription.ID = existing.ID
+		if existing == subscription {
+			return existing, true
+		}
+	}
+
+	return Subscription{}, false
+}
+
+func (r *Registry) list() []Subscription {
+	subscriptions := make([]Subscription, 0, len(r.subscriptions))
+	for _, subscription := range r.subscriptions {
+		subscriptions = append(subscriptions, subscription)
+	}
+	sort.Slice(subscriptions, func(i, j int) bool {
+		if subscriptions[i].Address != subscriptions[j].Address {
+			return subscriptions[i].Address.Lower() < subscriptions[j].Address.Lower()
+		}
+		return subscriptions[i].ID < subscriptions[j].ID
+	})
+
+	return subscriptions
+}
+
+func (r *Registry) notify(change Change) {
+	if len(change.Added) == 0 && len(change.Removed) == 0 {
+		return
+	}
+
+	for listener := range r.listeners {
+		(*listener)(change)
+	}
+}
+
+func (s Subscription) record() storage.Subscription {
+	return storage.Subscription{
+		ID:                s.ID,
+		Address:           s.Address.Lower(),
+		ConfirmationDepth: s.Confirmations.Depth,
+		ConfirmationTag:   s.Confirmations.Tag,
+		CallbackURL:       s.CallbackURL,
+		Secret:            s.Secret,
+	}
+}
+
+func fromRecord(record storage.Subscription) (Subscription, error) {
+	address, err := util.ParseAddress(record.Address)
+	if err != nil {
+		return Subscription{}, err
+	}
+
+	return Subscription{
+		ID:      record.ID,
+		Address: address,
+		Confirmations: ethereumParser.ConfirmationPolicy{
+			Depth: record.ConfirmationDepth,
+			Tag:   record.ConfirmationTag,
+		},
+		CallbackURL: record.CallbackURL,
+		Secret:      record.Secret,
+	}, nil
+}
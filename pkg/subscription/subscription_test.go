@@ -0,0 +1,108 @@
+package subscription_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	ethereumParser "ethereum-parser/pkg/ethereum-parser"
+	"ethereum-parser/pkg/storage"
+	"ethereum-parser/pkg/subscription"
+	"ethereum-parser/util"
+)
+
+var (
+	alice = util.MustParseAddress("0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed")
+	bob   = util.MustParseAddress("0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359")
+)
+
+func TestRegistry(t *testing.T) {
+	registry, err := subscription.NewRegistry(storage.NewMemoryStorage(0))
+	assert.NoError(t, err)
+
+	finalized := ethereumParser.ConfirmationPolicy{Tag: "finalized"}
+	added, err := registry.Add(
+		subscription.Subscription{Address: bob},
+		subscription.Subscription{Address: alice, Confirmations: finalized},
+		subscription.Subscription{Address: bob},
+	)
+	assert.NoError(t, err)
+	assert.Len(t, added, 3)
+	assert.NotEmpty(t, added[0].ID)
+	assert.Equal(t, added[0], added[2], "Equal subscriptions should only be added once")
+	assert.NotEqual(t, added[0].ID, added[1].ID)
+
+	again, err := registry.Add(subscription.Subscription{Address: bob, Confirmations: finalized})
+	assert.NoError(t, err)
+	assert.NotEqual(t, added[0].ID, again[0].ID, "Subscriptions with another policy should be added")
+
+	subscriptions := registry.List()
+	assert.Len(t, subscriptions, 3)
+	assert.Equal(t, alice, subscriptions[0].Address, "Subscriptions should be sorted by address")
+
+	got, err := registry.Get(added[1].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, added[1], *got)
+
+	removed, err := registry.Remove(added[1].ID, "missing", added[1].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, []subscription.Subscription{added[1]}, removed, "Only existing subscriptions should be returned")
+
+	_, err = registry.Get(added[1].ID)
+	assert.ErrorIs(t, err, subscription.ErrNotFound)
+	assert.Len(t, registry.List(), 2)
+}
+
+func TestRegistry_Attach(t *testing.T) {
+	registry, err := subscription.NewRegistry(storage.NewMemoryStorage(0))
+	assert.NoError(t, err)
+
+	existing, _ := registry.Add(subscription.Subscription{Address: alice})
+
+	var changes []subscription.Change
+	detach := registry.Attach(func(change subscription.Change) {
+		changes = append(changes, change)
+	})
+	assert.Equal(t, []subscription.Change{{Added: existing}}, changes, "Listeners should start with the current subscriptions")
+
+	added, _ := registry.Add(subscription.Subscription{Address: bob}, subscription.Subscription{Address: alice})
+	assert.Equal(t, subscription.Change{Added: added[:1]}, changes[1], "Only new subscriptions should be reported")
+
+	registry.Remove("missing")
+	assert.Len(t, changes, 2, "Changing nothing should not be reported")
+
+	registry.Remove(existing[0].ID)
+	assert.Equal(t, subscription.Change{Removed: existing}, changes[2])
+
+	detach()
+	registry.Remove(added[0].ID)
+	assert.Len(t, changes, 3, "Detached listeners should not be called")
+}
+
+func TestRegistry_Reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+
+	store, err := storage.NewBoltStorage(path, 0)
+	assert.NoError(t, err)
+
+	registry, err := subscription.NewRegistry(store)
+	assert.NoError(t, err)
+	kept, _ := registry.Add(subscription.Subscription{
+		Address:       alice,
+		Confirmations: ethereumParser.ConfirmationPolicy{Depth: 3},
+		CallbackURL:   "https://example.com/hook",
+		Secret:        "secret",
+	})
+	removed, _ := registry.Add(subscription.Subscription{Address: bob})
+	registry.Remove(removed[0].ID)
+	store.Close()
+
+	store, err = storage.NewBoltStorage(path, 0)
+	assert.NoError(t, err)
+	defer store.Close()
+
+	registry, err = subscription.NewRegistry(store)
+	assert.NoError(t, err)
+	assert.Equal(t, kept, registry.List(), "Subscriptions should survive a restart")
+}
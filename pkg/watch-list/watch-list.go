@@ -0,0 +1,314 @@
+package watchlist
+
+import (
+	"errors"
+	"ethereum-parser/pkg/storage"
+	"ethereum-parser/util"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var ErrNotFound = storage.ErrWatchListNotFound
+
+// WatchList is a named set of addresses kept server side, which WebSocket and
+// webhook consumers attach to by ID.
+type WatchList struct {
+	ID        string         `json:"id"`
+	Name      string         `json:"name"`
+	Addresses []util.Address `json:"addresses"`
+}
+
+// Change reports the addresses added to or removed from a watch-list. When the
+// list is deleted Deleted is set and Removed holds every address it had.
+type Change struct {
+	ListID  string
+	Added   []util.Address
+	Removed []util.Address
+	Deleted bool
+}
+
+// Listener is called with every change of a watch-list it is attached to. It
+// runs while the registry is locked, so changes reach it in order, and it must
+// not call back into the registry.
+type Listener func(change Change)
+
+type list struct {
+	storage.WatchList
+	addresses map[util.Address]struct{}
+	listeners map[*Listener]struct{}
+}
+
+// Registry keeps the watch-lists in storage, with an in-memory copy for
+// lookups, and tells attached listeners about their changes.
+type Registry struct {
+	mutex   sync.Mutex
+	storage storage.Storage
+	lists   map[string]*list
+}
+
+var DefaultRegistry *Registry = newMemoryRegistry()
+
+func SetDefaultRegistry(r *Registry) {
+	DefaultRegistry = r
+}
+
+func newMemoryRegistry() *Registry {
+	r, _ := NewRegistry(storage.NewMemoryStorage(0))
+	return r
+}
+
+// NewRegistry loads the watch-lists saved in store.
+func NewRegistry(store storage.Storage) (*Registry, error) {
+	saved, err := store.GetWatchLists()
+	if err != nil {
+		return nil, errors.New("error loading watch-lists, " + err.Error())
+	}
+
+	lists := make(map[string]*list, len(saved))
+	for _, watchList := range saved {
+		addresses, err := store.GetSubscriptions(storage.WatchListSet(watchList.ID))
+		if err != nil {
+			return nil, errors.New("error loading watch-list addresses, " + err.Error())
+		}
+
+		l := &list{
+			WatchList: watchList,
+			addresses: make(map[util.Address]struct{}, len(addresses)),
+			listeners: make(map[*Listener]struct{}),
+		}
+		for _, address := range addresses {
+			parsed, err := util.ParseAddress(address)
+			if err != nil {
+				return nil, errors.New("error loading watch-list addresses, " + err.Error())
+			}
+			l.addresses[parsed] = struct{}{}
+		}
+		lists[watchList.ID] = l
+	}
+
+	return &Registry{
+		storage: store,
+		lists:   lists,
+	}, nil
+}
+
+// Create saves a new watch-list holding addresses.
+func (r *Registry) Create(name string, addresses []util.Address) (*WatchList, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, errors.New("watch-list name is empty")
+	}
+
+	id, err := util.NewID()
+	if err != nil {
+		return nil, errors.New("error creating watch-list, " + err.Error())
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	l := &list{
+		WatchList: storage.WatchList{ID: id, Name: name},
+		addresses: make(map[util.Address]struct{}),
+		listeners: make(map[*Listener]struct{}),
+	}
+	if err := r.storage.SaveWatchList(l.WatchList); err != nil {
+		return nil, errors.New("error saving watch-list, " + err.Error())
+	}
+	r.lists[id] = l
+
+	if _, err := r.add(l, addresses); err != nil {
+		return nil, err
+	}
+
+	return l.snapshot(), nil
+}
+
+func (r *Registry) Get(id string) (*WatchList, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	l, ok := r.lists[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return l.snapshot(), nil
+}
+
+// List returns every watch-list ordered by name.
+func (r *Registry) List() []WatchList {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	lists := make([]WatchList, 0, len(r.lists))
+	for _, l := range r.lists {
+		lists = append(lists, *l.snapshot())
+	}
+	sort.Slice(lists, func(i, j int) bool {
+		if lists[i].Name != lists[j].Name {
+			return lists[i].Name < lists[j].Name
+		}
+		return lists[i].ID < lists[j].ID
+	})
+
+	return lists
+}
+
+// Contains reports whether address is on the watch-list id.
+func (r *Registry) Contains(id string, address util.Address) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	l, ok := r.lists[id]
+	if !ok {
+		return false
+	}
+
+	_, ok = l.addresses[address]
+	return ok
+}
+
+// Delete removes the watch-list id. Attached listeners are told every address
+// was removed and are not called again.
+func (r *Registry) Delete(id string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	l, ok := r.lists[id]
+	if !ok {
+		return ErrNotFound
+	}
+
+	if err := r.storage.DeleteWatchList(id); err != nil {
+		return errors.New("error deleting watch-list, " + err.Error())
+	}
+	delete(r.lists, id)
+
+	l.notify(Change{ListID: id, Removed: l.snapshot().Addresses, Deleted: true})
+
+	return nil
+}
+
+// AddAddresses adds addresses to the watch-list id.
+func (r *Registry) AddAddresses(id string, addresses ...util.Address) (*WatchList, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	l, ok := r.lists[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return r.add(l, addresses)
+}
+
+// RemoveAddresses removes addresses from the watch-list id.
+func (r *Registry) RemoveAddresses(id string, addresses ...util.Address) (*WatchList, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	l, ok := r.lists[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	var removed []util.Address
+	for _, address := range addresses {
+		if _, ok := l.addresses[address]; !ok {
+			continue
+		}
+
+		if err := r.storage.RemoveSubscription(storage.WatchListSet(id), address.Lower()); err != nil {
+			l.notify(Change{ListID: id, Removed: removed})
+			return nil, errors.New("error removing watch-list address, " + err.Error())
+		}
+		delete(l.addresses, address)
+		removed = append(removed, address)
+	}
+
+	if len(removed) > 0 {
+		l.notify(Change{ListID: id, Removed: removed})
+	}
+
+	return l.snapshot(), nil
+}
+
+// Attach calls listener with the addresses of the watch-list id, as a change
+// adding all of them, then with every later change until the returned detach
+// function is called.
+func (r *Registry) Attach(id string, listener Listener) (*WatchList, func(), error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	l, ok := r.lists[id]
+	if !ok {
+		return nil, nil, ErrNotFound
+	}
+
+	snapshot := l.snapshot()
+	listener(Change{ListID: id, Added: snapshot.Addresses})
+
+	key := &listener
+	l.listeners[key] = struct{}{}
+
+	detach := func() {
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+
+		delete(l.listeners, key)
+	}
+
+	return snapshot, detach, nil
+}
+
+// add saves the addresses not on l yet, the caller must hold the mutex.
+func (r *Registry) add(l *list, addresses []util.Address) (*WatchList, error) {
+	var added []util.Address
+	for _, address := range addresses {
+		if _, ok := l.addresses[address]; ok {
+			continue
+		}
+
+		if err := r.storage.AddSubscription(storage.WatchListSet(l.ID), address.Lower()); err != nil {
+			l.notify(Change{ListID: l.ID, Added: added})
+			return nil, errors.New("error saving watch-list address, " + err.Error())
+		}
+		l.addresses[address] = struct{}{}
+		added = append(added, address)
+	}
+
+	if len(added) > 0 {
+		l.notify(Change{ListID: l.ID, Added: added})
+	}
+
+	return l.snapshot(), nil
+}
+
+func (l *list) notify(change Change) {
+	if len(change.Added) == 0 && len(change.Removed) == 0 && !change.Deleted {
+		return
+	}
+
+	for listener := range l.listeners {
+		(*listener)(change)
+	}
+}
+
+// snapshot returns a copy of l with its addresses in lowercase order.
+func (l *list) snapshot() *WatchList {
+	addresses := make([]util.Address, 0, len(l.addresses))
+	for address := range l.addresses {
+		addresses = append(addresses, address)
+	}
+	sort.Slice(addresses, func(i, j int) bool {
+		return addresses[i].Lower() < addresses[j].Lower()
+	})
+
+	return &WatchList{
+		ID:        l.ID,
+		Name:      l.Name,
+		Addresses: addresses,
+	}
+}
@@ -0,0 +1,118 @@
+package watchlist_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"ethereum-parser/pkg/storage"
+	watchlist "ethereum-parser/pkg/watch-list"
+	"ethereum-parser/util"
+)
+
+var (
+	alice = util.MustParseAddress("0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed")
+	bob   = util.MustParseAddress("0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359")
+	carol = util.MustParseAddress("0xdbF03B407c01E7cD3CBea99509d93f8DDDC8C6FB")
+)
+
+func TestRegistry(t *testing.T) {
+	registry, err := watchlist.NewRegistry(storage.NewMemoryStorage(0))
+	assert.NoError(t, err)
+
+	_, err = registry.Create(" ", nil)
+	assert.Error(t, err, "Watch-lists need a name")
+
+	list, err := registry.Create("treasury", []util.Address{bob, alice, bob})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, list.ID)
+	assert.Equal(t, "treasury", list.Name)
+	assert.Equal(t, []util.Address{alice, bob}, list.Addresses, "Addresses should be unique and sorted")
+
+	other, err := registry.Create("exchanges", nil)
+	assert.NoError(t, err)
+	assert.NotEqual(t, list.ID, other.ID)
+	assert.Empty(t, other.Addresses)
+
+	lists := registry.List()
+	assert.Len(t, lists, 2)
+	assert.Equal(t, "exchanges", lists[0].Name, "Watch-lists should be sorted by name")
+
+	list, err = registry.AddAddresses(list.ID, carol)
+	assert.NoError(t, err)
+	assert.Len(t, list.Addresses, 3)
+	assert.True(t, registry.Contains(list.ID, carol))
+
+	list, err = registry.RemoveAddresses(list.ID, alice, alice)
+	assert.NoError(t, err)
+	assert.Equal(t, []util.Address{carol, bob}, list.Addresses)
+	assert.False(t, registry.Contains(list.ID, alice))
+
+	assert.NoError(t, registry.Delete(other.ID))
+	_, err = registry.Get(other.ID)
+	assert.ErrorIs(t, err, watchlist.ErrNotFound)
+	assert.ErrorIs(t, registry.Delete(other.ID), watchlist.ErrNotFound)
+	_, err = registry.AddAddresses(other.ID, alice)
+	assert.ErrorIs(t, err, watchlist.ErrNotFound)
+	assert.False(t, registry.Contains(other.ID, alice))
+}
+
+func TestRegistry_Attach(t *testing.T) {
+	registry, err := watchlist.NewRegistry(storage.NewMemoryStorage(0))
+	assert.NoError(t, err)
+
+	list, err := registry.Create("treasury", []util.Address{alice})
+	assert.NoError(t, err)
+
+	var first, second []watchlist.Change
+	_, detachFirst, err := registry.Attach(list.ID, func(change watchlist.Change) {
+		first = append(first, change)
+	})
+	assert.NoError(t, err)
+	attached, _, err := registry.Attach(list.ID, func(change watchlist.Change) {
+		second = append(second, change)
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []util.Address{alice}, attached.Addresses)
+	assert.Equal(t, []watchlist.Change{{ListID: list.ID, Added: []util.Address{alice}}}, first, "Listeners should start with the current addresses")
+
+	registry.AddAddresses(list.ID, alice, bob)
+	registry.RemoveAddresses(list.ID, carol)
+	assert.Equal(t, watchlist.Change{ListID: list.ID, Added: []util.Address{bob}}, first[1], "Only new addresses should be reported")
+	assert.Len(t, first, 2, "Changing nothing should not be reported")
+
+	detachFirst()
+	registry.RemoveAddresses(list.ID, alice)
+	assert.Len(t, first, 2, "Detached listeners should not be called")
+	assert.Equal(t, watchlist.Change{ListID: list.ID, Removed: []util.Address{alice}}, second[2])
+
+	registry.Delete(list.ID)
+	assert.Equal(t, watchlist.Change{ListID: list.ID, Removed: []util.Address{bob}, Deleted: true}, second[3])
+
+	_, _, err = registry.Attach(list.ID, func(watchlist.Change) {})
+	assert.ErrorIs(t, err, watchlist.ErrNotFound)
+}
+
+func TestRegistry_Reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+
+	store, err := storage.NewBoltStorage(path, 0)
+	assert.NoError(t, err)
+
+	registry, err := watchlist.NewRegistry(store)
+	assert.NoError(t, err)
+	kept, _ := registry.Create("kept", []util.Address{alice, bob})
+	deleted, _ := registry.Create("deleted", []util.Address{carol})
+	registry.RemoveAddresses(kept.ID, bob)
+	registry.Delete(deleted.ID)
+	store.Close()
+
+	store, err = storage.NewBoltStorage(path, 0)
+	assert.NoError(t, err)
+	defer store.Close()
+
+	registry, err = watchlist.NewRegistry(store)
+	assert.NoError(t, err)
+	assert.Equal(t, []watchlist.WatchList{{ID: kept.ID, Name: "kept", Addresses: []util.Address{alice}}}, registry.List(), "Watch-lists should survive a restart")
+}
@@ -0,0 +1,75 @@
+package webhook
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var ErrPrivateCallback = errors.New("callback host is not public")
+
+// ValidateCallbackURL checks that rawURL is an http or https URL with a host.
+// Unless allowPrivate is set, loopback, private, link-local and other
+// non-public hosts are rejected too. Names are only checked once resolved, by
+// the client of a dispatcher created from config.
+func ValidateCallbackURL(rawURL string, allowPrivate bool) error {
+	callback, err := url.Parse(rawURL)
+	if err != nil || (callback.Scheme != "http" && callback.Scheme != "https") || callback.Hostname() == "" {
+		return errors.New("invalid callback url, " + rawURL)
+	}
+
+	if allowPrivate {
+		return nil
+	}
+
+	host := strings.ToLower(strings.TrimSuffix(callback.Hostname(), "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return errors.New(ErrPrivateCallback.Error() + ", " + callback.Hostname())
+	}
+	if ip, err := netip.ParseAddr(host); err == nil && !isPublic(ip) {
+		return errors.New(ErrPrivateCallback.Error() + ", " + callback.Hostname())
+	}
+
+	return nil
+}
+
+// newPublicTransport returns a transport that refuses to connect to
+// non-public addresses, whatever name resolved to them, so that neither DNS
+// nor redirects reach internal hosts. Proxies are not used, they would be
+// dialed instead of the callback.
+func newPublicTransport() *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !isPublic(addrPort.Addr()) {
+				return ErrPrivateCallback
+			}
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return transport
+}
+
+func isPublic(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return ip.IsGlobalUnicast() && !ip.IsPrivate() && !sharedAddressSpace.Contains(ip)
+}
+
+// sharedAddressSpace is the carrier-grade NAT range, internal like the
+// private ones.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
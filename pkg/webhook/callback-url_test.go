@@ -0,0 +1,72 @@
+package webhook_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	evm "ethereum-parser/pkg/ethereum-rpc-client"
+	pubsub "ethereum-parser/pkg/pub-sub"
+	"ethereum-parser/pkg/storage"
+	"ethereum-parser/pkg/subscription"
+	"ethereum-parser/pkg/webhook"
+)
+
+func TestValidateCallbackURL(t *testing.T) {
+	cases := []struct {
+		name         string
+		url          string
+		allowPrivate bool
+		expectedErr  string
+	}{
+		{name: "Public host", url: "https://example.com/hook"},
+		{name: "Public IP", url: "http://93.184.216.34:8080/hook"},
+		{name: "Unsupported scheme", url: "ftp://example.com/hook", expectedErr: "invalid callback url, ftp://example.com/hook"},
+		{name: "Missing host", url: "https:///hook", expectedErr: "invalid callback url, https:///hook"},
+		{name: "Loopback", url: "http://127.0.0.1/hook", expectedErr: "callback host is not public, 127.0.0.1"},
+		{name: "IPv6 loopback", url: "http://[::1]:8080/hook", expectedErr: "callback host is not public, ::1"},
+		{name: "Mapped loopback", url: "http://[::ffff:127.0.0.1]/hook", expectedErr: "callback host is not public, ::ffff:127.0.0.1"},
+		{name: "Localhost", url: "http://LocalHost./hook", expectedErr: "callback host is not public, LocalHost."},
+		{name: "Localhost subdomain", url: "http://api.localhost/hook", expectedErr: "callback host is not public, api.localhost"},
+		{name: "Metadata service", url: "http://169.254.169.254/latest/meta-data", expectedErr: "callback host is not public, 169.254.169.254"},
+		{name: "Private", url: "https://10.0.0.1/hook", expectedErr: "callback host is not public, 10.0.0.1"},
+		{name: "Private IPv6", url: "https://[fd00::1]/hook", expectedErr: "callback host is not public, fd00::1"},
+		{name: "Shared address space", url: "https://100.64.0.1/hook", expectedErr: "callback host is not public, 100.64.0.1"},
+		{name: "Unspecified", url: "http://0.0.0.0/hook", expectedErr: "callback host is not public, 0.0.0.0"},
+		{name: "Private allowed", url: "http://127.0.0.1/hook", allowPrivate: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := webhook.ValidateCallbackURL(c.url, c.allowPrivate)
+
+			if c.expectedErr != "" {
+				assert.EqualError(t, err, c.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestDispatcher_PrivateCallbacks(t *testing.T) {
+	f := newFixture(t)
+	// Created from the default config, which refuses private callbacks
+	f.store = storage.NewMemoryStorage(0)
+	f.publisher = pubsub.NewBlockPublisher(f.store)
+	f.registry, _ = subscription.NewRegistry(f.store)
+	f.dispatcher = webhook.NewDispatcherFromConfig(f.publisher, f.registry, f.store)
+	f.dispatcher.RetryPolicy = evm.RetryPolicy{MaxAttempts: 1, InitialBackoff: time.Millisecond}
+	f.start(t)
+
+	// Subscriptions saved before validation, or names resolving to a private
+	// address, are refused when dialing
+	f.registry.Add(subscription.Subscription{Address: alice, Confirmations: immediate, CallbackURL: f.url, Secret: "secret"})
+	f.publisher.Publish(&evm.Block{Number: "0x1", Transactions: []evm.Transaction{{Hash: "0x1", To: alice.String()}}})
+
+	deliveries := f.waitFor(t, 1)
+	assert.Equal(t, webhook.StatusFailed, deliveries[0].Status)
+	assert.Contains(t, deliveries[0].Attempts[0].Error, webhook.ErrPrivateCallback.Error())
+	assert.Zero(t, f.receiver.count(), "Private callbacks should not be reached")
+}
@@ -0,0 +1,641 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"ethereum-parser/config"
+	"ethereum-parser/logger"
+	ethereumParser "ethereum-parser/pkg/ethereum-parser"
+	evm "ethereum-parser/pkg/ethereum-rpc-client"
+	pubsub "ethereum-parser/pkg/pub-sub"
+	"ethereum-parser/pkg/storage"
+	"ethereum-parser/pkg/subscription"
+	"ethereum-parser/util"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Delivery statuses. Failed deliveries ran out of attempts and form the
+// dead-letter list, until they are redelivered.
+const (
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	StatusFailed    = "failed"
+)
+
+const (
+	// SignatureHeader holds "sha256=" followed by the hex HMAC-SHA256 of the
+	// request body, keyed with the secret of the subscription
+	SignatureHeader = "X-Webhook-Signature"
+	DeliveryHeader  = "X-Webhook-Delivery"
+	EventHeader     = "X-Webhook-Event"
+)
+
+var (
+	ErrNotFound   = storage.ErrDeliveryNotFound
+	ErrInProgress = errors.New("delivery in progress")
+)
+
+// Events posted to callback URLs, as named by the action of the WebSocket API.
+// RemovedTransactions holds transactions delivered before a reorganization
+// dropped their block.
+const (
+	EventTransactions        = "Transactions"
+	EventRemovedTransactions = "RemovedTransactions"
+)
+
+// Event is the body posted to a callback URL, holding the transactions of a
+// block that involve the address of one subscription. ID is the id of the
+// delivery, receivers use it to drop redelivered events.
+type Event struct {
+	ID             string            `json:"id"`
+	Action         string            `json:"action"`
+	SubscriptionID string            `json:"subscriptionId"`
+	Address        util.Address      `json:"address"`
+	BlockNumber    string            `json:"blockNumber"`
+	BlockHash      string            `json:"blockHash"`
+	Txs            []evm.Transaction `json:"txs"`
+}
+
+// Dispatcher posts the transactions of published blocks to the callback URLs
+// of the subscriptions that match them, once their confirmation policy is met.
+// Every delivery is kept in storage with its attempts, so pending ones survive
+// a restart and failed ones can be redelivered. Transactions still waiting for
+// confirmations do not.
+type Dispatcher struct {
+	Client      *http.Client
+	RetryPolicy evm.RetryPolicy
+
+	publisher  *pubsub.BlockPublisher
+	storage    storage.Storage
+	subscriber *pubsub.BlockSubscriber
+	detach     func()
+
+	mutex     sync.Mutex
+	ctx       context.Context
+	stopped   bool
+	callbacks map[string]*callback // Subscriptions with a callback URL by id
+	watched   map[util.Address]int // Callbacks by address
+	inFlight  map[string]bool      // Deliveries being sent by id
+	wg        sync.WaitGroup
+}
+
+// callback is a subscription with a callback URL. Its transactions wait in
+// tracker until they are confirmed, and stay there until finalized so that a
+// reorganization can remove them.
+type callback struct {
+	subscription.Subscription
+	tracker   *ethereumParser.ConfirmationTracker
+	delivered map[string]bool // Tracked transactions already delivered by hash
+}
+
+// notification is a delivery to create.
+type notification struct {
+	callback subscription.Subscription
+	action   string
+	block    *evm.Block
+	txs      []evm.Transaction
+}
+
+var DefaultDispatcher *Dispatcher = newMemoryDispatcher()
+
+func SetDefaultDispatcher(d *Dispatcher) {
+	DefaultDispatcher = d
+}
+
+func newMemoryDispatcher() *Dispatcher {
+	store := storage.NewMemoryStorage(0)
+	registry, _ := subscription.NewRegistry(store)
+	return NewDispatcher(pubsub.NewBlockPublisher(store), registry, store)
+}
+
+func DefaultRetryPolicy() evm.RetryPolicy {
+	return evm.RetryPolicy{
+		MaxAttempts:    6,
+		InitialBackoff: 1 * time.Second,
+		MaxBackoff:     1 * time.Minute,
+		Multiplier:     2,
+		Jitter:         0.2,
+	}
+}
+
+// NewDispatcher follows the subscriptions of registry and the blocks of
+// publisher right away, so that no block published after it returns is
+// missed. Deliveries start with Start.
+func NewDispatcher(publisher *pubsub.BlockPublisher, registry *subscription.Registry, store storage.Storage) *Dispatcher {
+	d := &Dispatcher{
+		Client:      &http.Client{Timeout: 10 * time.Second},
+		RetryPolicy: DefaultRetryPolicy(),
+		publisher:   publisher,
+		storage:     store,
+		subscriber:  pubsub.NewBlockSubscriber(),
+		ctx:         context.Background(),
+		callbacks:   make(map[string]*callback),
+		watched:     make(map[util.Address]int),
+		inFlight:    make(map[string]bool),
+	}
+
+	// Only the transactions of watched addresses are delivered
+	publisher.Watch(d.subscriber)
+	d.detach = registry.Attach(d.update)
+	publisher.Subscribe(d.subscriber)
+
+	return d
+}
+
+func NewDispatcherFromConfig(publisher *pubsub.BlockPublisher, registry *subscription.Registry, store storage.Storage) *Dispatcher {
+	webhookConfig := config.GetConfig().Webhook
+	d := NewDispatcher(publisher, registry, store)
+
+	if webhookConfig.Timeout > 0 {
+		d.Client.Timeout = time.Duration(webhookConfig.Timeout) * time.Second
+	}
+	if webhookConfig.MaxAttempts > 0 {
+		d.RetryPolicy.MaxAttempts = webhookConfig.MaxAttempts
+	}
+	if webhookConfig.InitialBackoffMs > 0 {
+		d.RetryPolicy.InitialBackoff = time.Duration(webhookConfig.InitialBackoffMs) * time.Millisecond
+	}
+	if webhookConfig.MaxBackoffMs > 0 {
+		d.RetryPolicy.MaxBackoff = time.Duration(webhookConfig.MaxBackoffMs) * time.Millisecond
+	}
+	if !webhookConfig.AllowPrivateCallbacks {
+		d.Client.Transport = newPublicTransport()
+	}
+
+	return d
+}
+
+// Start resumes the deliveries a previous run left pending, then delivers the
+// transactions of published blocks until ctx is done. It stops following the
+// subscriptions and waits for the deliveries being sent before returning.
+func (d *Dispatcher) Start(ctx context.Context) {
+	d.mutex.Lock()
+	d.ctx = ctx
+	d.mutex.Unlock()
+
+	defer func() {
+		d.detach()
+		d.publisher.Unsubscribe(d.subscriber)
+
+		d.mutex.Lock()
+		d.stopped = true
+		d.mutex.Unlock()
+		d.wg.Wait()
+	}()
+
+	pending, err := d.storage.GetDeliveries(StatusPending)
+	if err != nil {
+		logger.Logger.Error("Error loading pending webhook deliveries, " + err.Error())
+	}
+	for _, delivery := range pending {
+		d.send(delivery)
+	}
+
+	for {
+		select {
+		case event := <-d.subscriber.Handler:
+			if event == nil {
+				continue
+			}
+
+			if event.Reorg != nil {
+				d.reorg(event.Reorg)
+			} else if event.Block != nil {
+				d.dispatch(event.Block)
+			} else if event.Head != nil {
+				d.confirm(event.Head)
+			}
+		case <-d.subscriber.Quit:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (d *Dispatcher) GetDelivery(id string) (*storage.Delivery, error) {
+	return d.storage.GetDelivery(id)
+}
+
+// GetDeliveries returns the deliveries with status, or all of them when it is
+// empty, oldest first.
+func (d *Dispatcher) GetDeliveries(status string) ([]storage.Delivery, error) {
+	return d.storage.GetDeliveries(status)
+}
+
+// GetDeadLetters returns the deliveries that ran out of attempts.
+func (d *Dispatcher) GetDeadLetters() ([]storage.Delivery, error) {
+	return d.storage.GetDeliveries(StatusFailed)
+}
+
+// Redeliver sends a delivery again, with as many attempts as a new one. The
+// attempts already made are kept. A delivery still being sent returns
+// ErrInProgress.
+func (d *Dispatcher) Redeliver(id string) (*storage.Delivery, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.inFlight[id] {
+		return nil, ErrInProgress
+	}
+
+	delivery, err := d.storage.GetDelivery(id)
+	if err != nil {
+		return nil, err
+	}
+
+	delivery.Status = StatusPending
+	if err := d.storage.SaveDelivery(*delivery); err != nil {
+		return nil, errors.New("error saving delivery, " + err.Error())
+	}
+	d.start(*delivery)
+
+	return delivery, nil
+}
+
+// Sign returns the signature header of payload for secret.
+func Sign(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// update follows the subscriptions with a callback URL, watching their
+// addresses on the publisher.
+func (d *Dispatcher) update(change subscription.Change) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	for _, added := range change.Added {
+		if added.CallbackURL == "" {
+			continue
+		}
+
+		d.callbacks[added.ID] = &callback{
+			Subscription: added,
+			tracker:      ethereumParser.NewConfirmationTracker(),
+			delivered:    make(map[string]bool),
+		}
+		d.watched[added.Address]++
+		if d.watched[added.Address] == 1 {
+			d.publisher.Watch(d.subscriber, added.Address)
+		}
+	}
+
+	for _, removed := range change.Removed {
+		if _, ok := d.callbacks[removed.ID]; !ok {
+			continue
+		}
+
+		delete(d.callbacks, removed.ID)
+		d.watched[removed.Address]--
+		if d.watched[removed.Address] == 0 {
+			delete(d.watched, removed.Address)
+			d.publisher.Unwatch(d.subscriber, removed.Address)
+		}
+	}
+}
+
+// dispatch tracks the transactions of block for every subscription with a
+// callback URL they involve, then delivers the ones already confirmed.
+func (d *Dispatcher) dispatch(block *evm.Block) {
+	number, err := util.ParseBlockNumber(block.Number)
+	if err != nil {
+		logger.Logger.Error("Error parsing webhook block number, " + err.Error())
+		return
+	}
+
+	d.mutex.Lock()
+	for _, callback := range d.sortedCallbacks() {
+		for _, tx := range block.Transactions {
+			if involves(d.publisher.AddressesOf(tx), callback.Address) {
+				callback.tracker.Track(callback.Address, block, inBlock(tx, block))
+			}
+		}
+	}
+	d.mutex.Unlock()
+
+	// The head may not have caught up with the block yet
+	head := d.publisher.GetHead()
+	head.Latest = max(head.Latest, number)
+	d.confirm(&head)
+}
+
+// confirm delivers the tracked transactions that head confirms, each once.
+func (d *Dispatcher) confirm(head *pubsub.HeadEvent) {
+	var notifications []notification
+
+	d.mutex.Lock()
+	for _, callback := range d.sortedCallbacks() {
+		policy := callback.Confirmations
+		statuses := callback.tracker.Update(head, func(util.Address) ethereumParser.ConfirmationPolicy {
+			return policy
+		})
+
+		var txs []evm.Transaction
+		for _, status := range statuses {
+			hash := status.Transaction.Hash
+			if callback.delivered[hash] {
+				// Finalized transactions are no longer tracked
+				if status.Status == ethereumParser.StatusFinalized {
+					delete(callback.delivered, hash)
+				}
+				continue
+			}
+
+			if status.Status != ethereumParser.StatusFinalized {
+				callback.delivered[hash] = true
+			}
+			txs = append(txs, status.Transaction)
+		}
+
+		notifications = append(notifications, blockNotifications(callback.Subscription, EventTransactions, txs)...)
+	}
+	d.mutex.Unlock()
+
+	d.create(notifications)
+}
+
+// reorg stops tracking the transactions of the removed blocks, and delivers a
+// RemovedTransactions event for the ones already delivered.
+func (d *Dispatcher) reorg(reorg *pubsub.ReorgEvent) {
+	var notifications []notification
+
+	d.mutex.Lock()
+	for _, callback := range d.sortedCallbacks() {
+		for _, block := range reorg.RemovedBlocks {
+			var removed []evm.Transaction
+			for _, tx := range block.Transactions {
+				if !involves(d.publisher.AddressesOf(tx), callback.Address) {
+					continue
+				}
+
+				tx = inBlock(tx, block)
+				callback.tracker.Untrack(callback.Address, tx)
+				if callback.delivered[tx.Hash] {
+					delete(callback.delivered, tx.Hash)
+					removed = append(removed, tx)
+				}
+			}
+
+			if len(removed) > 0 {
+				notifications = append(notifications, notification{callback: callback.Subscription, action: EventRemovedTransactions, block: block, txs: removed})
+			}
+		}
+	}
+	d.mutex.Unlock()
+
+	d.create(notifications)
+}
+
+func (d *Dispatcher) create(notifications []notification) {
+	for _, n := range notifications {
+		delivery, err := d.newDelivery(n)
+		if err != nil {
+			logger.Logger.Error("Error creating webhook delivery, " + err.Error())
+			continue
+		}
+		d.send(*delivery)
+	}
+}
+
+// sortedCallbacks returns the callbacks by id. It must be called with the
+// dispatcher locked.
+func (d *Dispatcher) sortedCallbacks() []*callback {
+	callbacks := make([]*callback, 0, len(d.callbacks))
+	for _, callback := range d.callbacks {
+		callbacks = append(callbacks, callback)
+	}
+
+	sort.Slice(callbacks, func(i, j int) bool {
+		return callbacks[i].ID < callbacks[j].ID
+	})
+
+	return callbacks
+}
+
+func (d *Dispatcher) newDelivery(n notification) (*storage.Delivery, error) {
+	callback := n.callback
+	id, err := util.NewID()
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := json.Marshal(Event{
+		ID:             id,
+		Action:         n.action,
+		SubscriptionID: callback.ID,
+		Address:        callback.Address,
+		BlockNumber:    n.block.Number,
+		BlockHash:      n.block.Hash,
+		Txs:            n.txs,
+	})
+	if err != nil {
+		return nil, errors.New("error marshalling event, " + err.Error())
+	}
+
+	delivery := &storage.Delivery{
+		ID:             id,
+		SubscriptionID: callback.ID,
+		Event:          n.action,
+		URL:            callback.CallbackURL,
+		Payload:        payload,
+		Signature:      Sign(callback.Secret, payload),
+		Status:         StatusPending,
+		Attempts:       []storage.DeliveryAttempt{},
+		CreatedAt:      time.Now().UTC(),
+	}
+	if err := d.storage.SaveDelivery(*delivery); err != nil {
+		return nil, errors.New("error saving delivery, " + err.Error())
+	}
+
+	return delivery, nil
+}
+
+func (d *Dispatcher) send(delivery storage.Delivery) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.start(delivery)
+}
+
+// start sends delivery in the background unless it is already being sent or
+// the dispatcher stopped, in which case it stays pending. It must be called
+// with the dispatcher locked.
+func (d *Dispatcher) start(delivery storage.Delivery) {
+	if d.stopped || d.inFlight[delivery.ID] {
+		return
+	}
+
+	d.inFlight[delivery.ID] = true
+	d.wg.Add(1)
+	ctx := d.ctx
+
+	go func() {
+		defer d.wg.Done()
+
+		if !d.deliver(ctx, delivery) {
+			d.mutex.Lock()
+			delete(d.inFlight, delivery.ID)
+			d.mutex.Unlock()
+		}
+	}()
+}
+
+// deliver posts delivery until the callback accepts it or the retry policy
+// runs out of attempts, which dead-letters it, and reports whether it did. An
+// attempt interrupted by ctx is not recorded and the delivery stays pending,
+// to be resumed on the next start.
+func (d *Dispatcher) deliver(ctx context.Context, delivery storage.Delivery) bool {
+	for attempt := 1; ; attempt++ {
+		result := d.post(ctx, delivery)
+		if ctx.Err() != nil {
+			return false
+		}
+
+		delivery.Attempts = append(delivery.Attempts, result)
+		if result.Error == "" {
+			delivery.Status = StatusDelivered
+		} else if attempt >= d.RetryPolicy.MaxAttempts {
+			delivery.Status = StatusFailed
+			logger.Logger.Warn("Webhook delivery " + delivery.ID + " to " + delivery.URL + " failed, " + result.Error)
+		}
+
+		if delivery.Status != StatusPending {
+			d.finish(delivery)
+			return true
+		}
+		d.save(delivery)
+
+		if err := sleep(ctx, d.RetryPolicy.Backoff(attempt)); err != nil {
+			return false
+		}
+	}
+}
+
+// finish saves the outcome of delivery along with the end of its sending, so
+// that it can be redelivered as soon as it is seen failed.
+func (d *Dispatcher) finish(delivery storage.Delivery) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.save(delivery)
+	delete(d.inFlight, delivery.ID)
+}
+
+func (d *Dispatcher) save(delivery storage.Delivery) {
+	if err := d.storage.SaveDelivery(delivery); err != nil {
+		logger.Logger.Error("Error saving webhook delivery, " + err.Error())
+	}
+}
+
+func (d *Dispatcher) post(ctx context.Context, delivery storage.Delivery) storage.DeliveryAttempt {
+	attempt := storage.DeliveryAttempt{Time: time.Now().UTC()}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(SignatureHeader, delivery.Signature)
+	request.Header.Set(DeliveryHeader, delivery.ID)
+	// Deliveries saved before events were recorded only held transactions
+	event := delivery.Event
+	if event == "" {
+		event = EventTransactions
+	}
+	request.Header.Set(EventHeader, event)
+
+	response, err := d.Client.Do(request)
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	defer response.Body.Close()
+	// Drained so the connection is reused
+	io.Copy(io.Discard, io.LimitReader(response.Body, 64<<10))
+
+	attempt.StatusCode = response.StatusCode
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		attempt.Error = "unexpected status " + response.Status
+	}
+
+	return attempt
+}
+
+// inBlock returns tx with the number and hash of block when it lacks them.
+func inBlock(tx evm.Transaction, block *evm.Block) evm.Transaction {
+	if tx.BlockNumber == "" {
+		tx.BlockNumber = block.Number
+	}
+	if tx.BlockHash == "" {
+		tx.BlockHash = block.Hash
+	}
+
+	return tx
+}
+
+// blockNotifications groups txs by block, in chain order, as notifications of action.
+func blockNotifications(callback subscription.Subscription, action string, txs []evm.Transaction) []notification {
+	sort.SliceStable(txs, func(i, j int) bool {
+		return bytes.Compare(txKey(txs[i]), txKey(txs[j])) < 0
+	})
+
+	var notifications []notification
+	for _, tx := range txs {
+		last := len(notifications) - 1
+		if last < 0 || notifications[last].block.Number != tx.BlockNumber || notifications[last].block.Hash != tx.BlockHash {
+			block := &evm.Block{Number: tx.BlockNumber, Hash: tx.BlockHash}
+			notifications = append(notifications, notification{callback: callback, action: action, block: block})
+			last++
+		}
+		notifications[last].txs = append(notifications[last].txs, tx)
+	}
+
+	return notifications
+}
+
+// txKey orders transactions by block and index.
+func txKey(tx evm.Transaction) []byte {
+	number, _ := util.HexToDecimal(tx.BlockNumber)
+	index, _ := util.HexToDecimal(tx.TransactionIndex)
+
+	key := make([]byte, 16)
+	binary.BigEndian.PutUint64(key, uint64(number))
+	binary.BigEndian.PutUint64(key[8:], uint64(index))
+	return key
+}
+
+func involves(addresses []util.Address, address util.Address) bool {
+	for _, involved := range addresses {
+		if involved == address {
+			return true
+		}
+	}
+
+	return false
+}
+
+func sleep(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
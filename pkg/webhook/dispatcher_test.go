@@ -0,0 +1,338 @@
+package webhook_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"ethereum-parser/logger"
+	ethereumParser "ethereum-parser/pkg/ethereum-parser"
+	evm "ethereum-parser/pkg/ethereum-rpc-client"
+	pubsub "ethereum-parser/pkg/pub-sub"
+	"ethereum-parser/pkg/storage"
+	"ethereum-parser/pkg/subscription"
+	"ethereum-parser/pkg/webhook"
+	"ethereum-parser/util"
+)
+
+var (
+	alice = util.MustParseAddress("0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed")
+	bob   = util.MustParseAddress("0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359")
+	carol = util.MustParseAddress("0xdbF03B407c01E7cD3CBea99509d93f8DDDC8C6FB")
+
+	// immediate delivers transactions as soon as their block arrives
+	immediate = ethereumParser.ConfirmationPolicy{Depth: 1}
+)
+
+// receiver records the requests it gets, answering each with the next status
+// of statuses and 200 once they run out.
+type receiver struct {
+	mutex    sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, request *http.Request) {
+	body, _ := io.ReadAll(request.Body)
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.requests = append(r.requests, request)
+	r.bodies = append(r.bodies, body)
+
+	status := http.StatusOK
+	if len(r.statuses) > 0 {
+		status, r.statuses = r.statuses[0], r.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func (r *receiver) count() int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return len(r.requests)
+}
+
+// request returns the last request of the delivery id.
+func (r *receiver) request(id string) *http.Request {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for i := len(r.requests) - 1; i >= 0; i-- {
+		if r.requests[i].Header.Get(webhook.DeliveryHeader) == id {
+			return r.requests[i]
+		}
+	}
+
+	return nil
+}
+
+type fixture struct {
+	store      *storage.MemoryStorage
+	publisher  *pubsub.BlockPublisher
+	registry   *subscription.Registry
+	dispatcher *webhook.Dispatcher
+	receiver   *receiver
+	url        string
+}
+
+func newFixture(t *testing.T, statuses ...int) *fixture {
+	logger.Logger = zap.NewNop()
+
+	f := &fixture{
+		store:    storage.NewMemoryStorage(0),
+		receiver: &receiver{statuses: statuses},
+	}
+	f.publisher = pubsub.NewBlockPublisher(f.store)
+	f.registry, _ = subscription.NewRegistry(f.store)
+	f.dispatcher = webhook.NewDispatcher(f.publisher, f.registry, f.store)
+	f.dispatcher.RetryPolicy = evm.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+
+	server := httptest.NewServer(f.receiver)
+	t.Cleanup(server.Close)
+	f.url = server.URL + "/hook"
+
+	return f
+}
+
+func (f *fixture) start(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f.dispatcher.Start(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+// waitFor waits until every delivery left the pending status and returns them.
+func (f *fixture) waitFor(t *testing.T, count int) []storage.Delivery {
+	var deliveries []storage.Delivery
+	assert.Eventually(t, func() bool {
+		pending, _ := f.dispatcher.GetDeliveries(webhook.StatusPending)
+		deliveries, _ = f.dispatcher.GetDeliveries("")
+		return len(pending) == 0 && len(deliveries) == count
+	}, time.Second, time.Millisecond)
+
+	return deliveries
+}
+
+func TestDispatcher(t *testing.T) {
+	f := newFixture(t)
+	f.start(t)
+
+	subscribed, _ := f.registry.Add(
+		subscription.Subscription{Address: alice, Confirmations: immediate, CallbackURL: f.url, Secret: "secret"},
+		subscription.Subscription{Address: bob},
+	)
+
+	f.publisher.Publish(&evm.Block{
+		Number: "0x1",
+		Hash:   "0xaa",
+		Transactions: []evm.Transaction{
+			{Hash: "0x1", From: alice.String(), To: carol.String()},
+			{Hash: "0x2", From: bob.String(), To: carol.String()},
+		},
+	})
+
+	deliveries := f.waitFor(t, 1)
+	assert.Equal(t, webhook.StatusDelivered, deliveries[0].Status)
+	assert.Equal(t, subscribed[0].ID, deliveries[0].SubscriptionID)
+	assert.Len(t, deliveries[0].Attempts, 1)
+	assert.Equal(t, http.StatusOK, deliveries[0].Attempts[0].StatusCode)
+
+	request, body := f.receiver.requests[0], f.receiver.bodies[0]
+	assert.Equal(t, "/hook", request.URL.Path)
+	assert.Equal(t, "application/json", request.Header.Get("Content-Type"))
+	assert.Equal(t, deliveries[0].ID, request.Header.Get(webhook.DeliveryHeader))
+	assert.Equal(t, webhook.Sign("secret", body), request.Header.Get(webhook.SignatureHeader), "Bodies should be signed with the subscription secret")
+	assert.NotEqual(t, webhook.Sign("other", body), request.Header.Get(webhook.SignatureHeader))
+
+	var event webhook.Event
+	assert.NoError(t, json.Unmarshal(body, &event))
+	assert.Equal(t, deliveries[0].ID, event.ID)
+	assert.Equal(t, "Transactions", event.Action)
+	assert.Equal(t, alice, event.Address)
+	assert.Equal(t, "0x1", event.BlockNumber)
+	assert.Len(t, event.Txs, 1, "Only the transactions of the subscribed address should be sent")
+	assert.Equal(t, "0x1", event.Txs[0].Hash)
+
+	// Removed subscriptions are no longer delivered
+	f.registry.Remove(subscribed[0].ID)
+	f.publisher.Publish(&evm.Block{
+		Number:       "0x2",
+		Hash:         "0xbb",
+		Transactions: []evm.Transaction{{Hash: "0x3", From: alice.String()}},
+	})
+	f.registry.Add(subscription.Subscription{Address: carol, Confirmations: immediate, CallbackURL: f.url, Secret: "secret"})
+	f.publisher.Publish(&evm.Block{
+		Number:       "0x3",
+		Hash:         "0xcc",
+		Transactions: []evm.Transaction{{Hash: "0x4", From: alice.String(), To: carol.String()}},
+	})
+
+	deliveries = f.waitFor(t, 2)
+	assert.Equal(t, 2, f.receiver.count())
+	assert.JSONEq(t, string(deliveries[1].Payload), string(f.receiver.bodies[1]))
+	assert.Contains(t, string(deliveries[1].Payload), `"blockNumber":"0x3"`)
+}
+
+func TestDispatcher_Retries(t *testing.T) {
+	f := newFixture(t, http.StatusInternalServerError, http.StatusBadGateway)
+	f.start(t)
+
+	f.registry.Add(subscription.Subscription{Address: alice, Confirmations: immediate, CallbackURL: f.url, Secret: "secret"})
+	f.publisher.Publish(&evm.Block{Number: "0x1", Transactions: []evm.Transaction{{Hash: "0x1", To: alice.String()}}})
+
+	deliveries := f.waitFor(t, 1)
+	assert.Equal(t, webhook.StatusDelivered, deliveries[0].Status)
+
+	attempts := deliveries[0].Attempts
+	assert.Len(t, attempts, 3, "Failed attempts should be retried")
+	assert.Equal(t, http.StatusInternalServerError, attempts[0].StatusCode)
+	assert.Equal(t, "unexpected status 500 Internal Server Error", attempts[0].Error)
+	assert.Equal(t, http.StatusBadGateway, attempts[1].StatusCode)
+	assert.Empty(t, attempts[2].Error)
+	assert.Equal(t, f.receiver.bodies[0], f.receiver.bodies[2], "Retries should send the same body")
+}
+
+func TestDispatcher_DeadLetters(t *testing.T) {
+	f := newFixture(t, 500, 500, 500)
+	f.start(t)
+
+	f.registry.Add(subscription.Subscription{Address: alice, Confirmations: immediate, CallbackURL: f.url, Secret: "secret"})
+	f.publisher.Publish(&evm.Block{Number: "0x1", Transactions: []evm.Transaction{{Hash: "0x1", To: alice.String()}}})
+
+	deliveries := f.waitFor(t, 1)
+	assert.Equal(t, webhook.StatusFailed, deliveries[0].Status)
+	assert.Len(t, deliveries[0].Attempts, 3, "Deliveries should stop after the last attempt")
+
+	deadLetters, err := f.dispatcher.GetDeadLetters()
+	assert.NoError(t, err)
+	assert.Equal(t, deliveries, deadLetters)
+
+	redelivered, err := f.dispatcher.Redeliver(deliveries[0].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, webhook.StatusPending, redelivered.Status)
+
+	deliveries = f.waitFor(t, 1)
+	assert.Equal(t, webhook.StatusDelivered, deliveries[0].Status)
+	assert.Len(t, deliveries[0].Attempts, 4, "Earlier attempts should be kept")
+
+	deadLetters, err = f.dispatcher.GetDeadLetters()
+	assert.NoError(t, err)
+	assert.Empty(t, deadLetters)
+
+	_, err = f.dispatcher.Redeliver("missing")
+	assert.ErrorIs(t, err, webhook.ErrNotFound)
+}
+
+func TestDispatcher_Resume(t *testing.T) {
+	f := newFixture(t)
+
+	payload := []byte(`{"id":"a"}`)
+	f.store.SaveDelivery(storage.Delivery{
+		ID:        "a",
+		URL:       f.url,
+		Payload:   payload,
+		Signature: webhook.Sign("secret", payload),
+		Status:    webhook.StatusPending,
+		CreatedAt: time.Now(),
+	})
+	f.start(t)
+
+	deliveries := f.waitFor(t, 1)
+	assert.Equal(t, webhook.StatusDelivered, deliveries[0].Status, "Pending deliveries should be resumed")
+	assert.Equal(t, payload, f.receiver.bodies[0])
+}
+
+func TestDispatcher_Confirmations(t *testing.T) {
+	f := newFixture(t)
+	f.start(t)
+
+	f.registry.Add(
+		subscription.Subscription{Address: alice, Confirmations: ethereumParser.ConfirmationPolicy{Depth: 3}, CallbackURL: f.url, Secret: "secret"},
+		subscription.Subscription{Address: bob, Confirmations: immediate, CallbackURL: f.url, Secret: "secret"},
+	)
+
+	f.publisher.Publish(&evm.Block{Number: "0x1", Hash: "0xaa", Transactions: []evm.Transaction{{Hash: "0x1", To: alice.String()}}})
+	f.publisher.PublishHead(&pubsub.HeadEvent{Latest: 2})
+	// Transactions with a depth of 1 are delivered right away, events are
+	// handled in order so alice's would come first
+	f.publisher.Publish(&evm.Block{Number: "0x2", Hash: "0xbb", Transactions: []evm.Transaction{{Hash: "0x2", To: bob.String()}}})
+
+	deliveries := f.waitFor(t, 1)
+	assert.Contains(t, string(deliveries[0].Payload), `"address":"`+bob.String()+`"`, "Unconfirmed transactions should wait")
+
+	f.publisher.PublishHead(&pubsub.HeadEvent{Latest: 3})
+
+	deliveries = f.waitFor(t, 2)
+	var event webhook.Event
+	assert.NoError(t, json.Unmarshal(deliveries[1].Payload, &event))
+	assert.Equal(t, alice, event.Address)
+	assert.Equal(t, "0x1", event.BlockNumber)
+	assert.Equal(t, "0xaa", event.BlockHash)
+	assert.Len(t, event.Txs, 1)
+
+	// Finalization does not deliver confirmed transactions again
+	f.publisher.PublishHead(&pubsub.HeadEvent{Latest: 10, Finalized: 5})
+	f.publisher.Publish(&evm.Block{Number: "0xb", Hash: "0xcc", Transactions: []evm.Transaction{{Hash: "0x3", To: bob.String()}}})
+
+	deliveries = f.waitFor(t, 3)
+	assert.Contains(t, string(deliveries[2].Payload), `"blockNumber":"0xb"`)
+}
+
+func TestDispatcher_Reorg(t *testing.T) {
+	f := newFixture(t)
+	f.start(t)
+
+	f.registry.Add(
+		subscription.Subscription{Address: alice, Confirmations: immediate, CallbackURL: f.url, Secret: "secret"},
+		subscription.Subscription{Address: carol, Confirmations: ethereumParser.ConfirmationPolicy{Depth: 5}, CallbackURL: f.url, Secret: "secret"},
+	)
+
+	orphan := &evm.Block{
+		Number: "0x1",
+		Hash:   "0xaa",
+		Transactions: []evm.Transaction{
+			{Hash: "0x1", From: alice.String(), To: bob.String()},
+			{Hash: "0x2", From: bob.String(), To: carol.String()},
+		},
+	}
+	f.publisher.Publish(orphan)
+	f.waitFor(t, 1)
+
+	f.publisher.PublishReorg(&pubsub.ReorgEvent{CommonAncestor: 0, RemovedBlocks: []*evm.Block{orphan}})
+	// Carol's transaction was never delivered, it is dropped silently
+	f.publisher.PublishHead(&pubsub.HeadEvent{Latest: 10})
+	f.publisher.Publish(&evm.Block{Number: "0xb", Hash: "0xbb", Transactions: []evm.Transaction{{Hash: "0x3", To: alice.String()}}})
+
+	deliveries := f.waitFor(t, 3)
+	assert.Contains(t, string(deliveries[2].Payload), `"blockNumber":"0xb"`)
+	assert.Equal(t, webhook.EventRemovedTransactions, deliveries[1].Event)
+	assert.Equal(t, webhook.EventRemovedTransactions, f.receiver.request(deliveries[1].ID).Header.Get(webhook.EventHeader))
+	assert.Equal(t, webhook.EventTransactions, f.receiver.request(deliveries[0].ID).Header.Get(webhook.EventHeader))
+
+	var event webhook.Event
+	assert.NoError(t, json.Unmarshal(deliveries[1].Payload, &event))
+	assert.Equal(t, webhook.EventRemovedTransactions, event.Action)
+	assert.Equal(t, alice, event.Address)
+	assert.Equal(t, "0xaa", event.BlockHash)
+	if assert.Len(t, event.Txs, 1) {
+		assert.Equal(t, "0x1", event.Txs[0].Hash)
+	}
+}
@@ -0,0 +1,120 @@
+package controller
+
+import (
+	"errors"
+	"ethereum-parser/logger"
+	ethereumParser "ethereum-parser/pkg/ethereum-parser"
+	pubsub "ethereum-parser/pkg/pub-sub"
+	"ethereum-parser/util"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetAddressTransactions pages through the stored transactions of an address,
+// see ethereumParser.HistoryQuery for the filters.
+func GetAddressTransactions(c *gin.Context) {
+	address, err := util.ParseAddress(c.Param("address"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, util.GetFailResponse("Invalid address"))
+		return
+	}
+
+	query, err := getHistoryQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, util.GetFailResponse(err.Error()))
+		return
+	}
+	query.Address = address
+	if err := query.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, util.GetFailResponse(err.Error()))
+		return
+	}
+
+	page, err := ethereumParser.QueryHistory(pubsub.DefaultPublisher.Storage(), query)
+	if errors.Is(err, ethereumParser.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, util.GetFailResponse("Invalid cursor"))
+		return
+	}
+	if err != nil {
+		logger.Logger.Error("Failed to get address transactions, " + err.Error())
+		c.JSON(http.StatusInternalServerError, util.GetFailResponse("Failed to get transactions"))
+		return
+	}
+
+	response := map[string]interface{}{
+		"address":      address,
+		"transactions": page.Transactions,
+		"nextCursor":   page.NextCursor,
+		"hasMore":      page.HasMore,
+	}
+	c.JSON(http.StatusOK, util.GetSuccessResponse(response))
+}
+
+func getHistoryQuery(c *gin.Context) (ethereumParser.HistoryQuery, error) {
+	query := ethereumParser.HistoryQuery{
+		ToBlock:   -1,
+		Direction: c.Query("direction"),
+		Asset:     c.Query("asset"),
+		Cursor:    c.Query("cursor"),
+	}
+
+	var err error
+	if value := c.Query("fromBlock"); value != "" {
+		if query.FromBlock, err = parseBlockParam(value); err != nil {
+			return query, errors.New("Invalid fromBlock")
+		}
+	}
+	if value := c.Query("toBlock"); value != "" {
+		if query.ToBlock, err = parseBlockParam(value); err != nil {
+			return query, errors.New("Invalid toBlock")
+		}
+	}
+
+	if value := c.Query("minValue"); value != "" {
+		minValue, err := util.ParseQuantity(value)
+		if err != nil {
+			return query, errors.New("Invalid minValue")
+		}
+		query.MinValue = &minValue
+	}
+	if value := c.Query("maxValue"); value != "" {
+		maxValue, err := util.ParseQuantity(value)
+		if err != nil {
+			return query, errors.New("Invalid maxValue")
+		}
+		query.MaxValue = &maxValue
+	}
+
+	switch c.DefaultQuery("order", "desc") {
+	case "asc":
+	case "desc":
+		query.Descending = true
+	default:
+		return query, errors.New("Invalid order")
+	}
+
+	if value := c.Query("limit"); value != "" {
+		if query.Limit, err = strconv.Atoi(value); err != nil || query.Limit <= 0 {
+			return query, errors.New("Invalid limit")
+		}
+	}
+
+	return query, nil
+}
+
+// parseBlockParam parses a block number given in decimal or "0x" hex.
+func parseBlockParam(value string) (int, error) {
+	// ParseQuantity reads an empty string as 0
+	if value == "" {
+		return 0, errors.New("block number is empty")
+	}
+
+	quantity, err := util.ParseQuantity(value)
+	if err != nil {
+		return 0, err
+	}
+
+	return quantity.Int()
+}
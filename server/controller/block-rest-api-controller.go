@@ -0,0 +1,136 @@
+package controller
+
+import (
+	"errors"
+	evm "ethereum-parser/pkg/ethereum-rpc-client"
+	pubsub "ethereum-parser/pkg/pub-sub"
+	"ethereum-parser/pkg/storage"
+	"ethereum-parser/util"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// finalizedCacheControl lets clients and proxies keep finalized blocks, which
+// never change.
+const finalizedCacheControl = "public, max-age=31536000, immutable"
+
+func GetBlock(c *gin.Context) {
+	block, ok := getBlockParam(c)
+	if !ok {
+		return
+	}
+
+	response := map[string]interface{}{
+		"block": block,
+	}
+	c.JSON(http.StatusOK, util.GetSuccessResponse(response))
+}
+
+func GetBlockTransactions(c *gin.Context) {
+	block, ok := getBlockParam(c)
+	if !ok {
+		return
+	}
+
+	response := map[string]interface{}{
+		"blockNumber":  block.Number,
+		"blockHash":    block.Hash,
+		"transactions": block.Transactions,
+	}
+	c.JSON(http.StatusOK, util.GetSuccessResponse(response))
+}
+
+// GetBlocks returns the blocks from the from query parameter to the to one,
+// both included, up to pubsub.MaxBlockRange of them.
+func GetBlocks(c *gin.Context) {
+	reader := pubsub.DefaultBlockReader
+
+	value, ok := c.GetQuery("from")
+	if !ok {
+		c.JSON(http.StatusBadRequest, util.GetFailResponse("Missing from"))
+		return
+	}
+
+	from, err := parseBlockParam(value)
+	if err != nil || from < 0 {
+		c.JSON(http.StatusBadRequest, util.GetFailResponse("Invalid from"))
+		return
+	}
+
+	to := from + pubsub.MaxBlockRange - 1
+	if value := c.Query("to"); value != "" {
+		if to, err = parseBlockParam(value); err != nil || to < from {
+			c.JSON(http.StatusBadRequest, util.GetFailResponse("Invalid to"))
+			return
+		}
+	}
+
+	blocks, err := reader.GetBlocks(c.Request.Context(), from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, util.GetFailResponse(err.Error()))
+		return
+	}
+
+	// The range only holds finalized blocks, so it is complete and final
+	if len(blocks) > 0 && reader.IsFinalized(min(to, from+pubsub.MaxBlockRange-1)) {
+		c.Header("Cache-Control", finalizedCacheControl)
+	} else {
+		c.Header("Cache-Control", "no-cache")
+	}
+
+	response := map[string]interface{}{
+		"blocks": blocks,
+	}
+	c.JSON(http.StatusOK, util.GetSuccessResponse(response))
+}
+
+// getBlockParam looks up the block given by number or hash, writing the error
+// response when there is none, and the caching headers otherwise.
+func getBlockParam(c *gin.Context) (*evm.Block, bool) {
+	reader := pubsub.DefaultBlockReader
+	param := c.Param("block")
+
+	var block *evm.Block
+	var err error
+	if isBlockHash(param) {
+		block, err = reader.GetBlockByHash(c.Request.Context(), param)
+	} else {
+		number, parseErr := parseBlockParam(param)
+		if parseErr != nil || number < 0 {
+			c.JSON(http.StatusBadRequest, util.GetFailResponse("Invalid block number or hash"))
+			return nil, false
+		}
+		block, err = reader.GetBlockByNumber(c.Request.Context(), number)
+	}
+
+	if errors.Is(err, storage.ErrBlockNotFound) {
+		c.JSON(http.StatusNotFound, util.GetFailResponse("Block not found"))
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, util.GetFailResponse(err.Error()))
+		return nil, false
+	}
+
+	number, err := util.ParseBlockNumber(block.Number)
+	if err == nil && reader.IsFinalized(number) {
+		etag := `"` + strings.ToLower(block.Hash) + `"`
+		c.Header("Cache-Control", finalizedCacheControl)
+		c.Header("ETag", etag)
+
+		if c.GetHeader("If-None-Match") == etag {
+			c.Status(http.StatusNotModified)
+			return nil, false
+		}
+	} else {
+		c.Header("Cache-Control", "no-cache")
+	}
+
+	return block, true
+}
+
+func isBlockHash(param string) bool {
+	return len(param) == 66 && strings.HasPrefix(param, "0x")
+}
@@ -0,0 +1,54 @@
+package controller_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	evm "ethereum-parser/pkg/ethereum-rpc-client"
+	pubsub "ethereum-parser/pkg/pub-sub"
+	"ethereum-parser/pkg/storage"
+	"ethereum-parser/server/controller"
+)
+
+func TestGetBlocks_Validation(t *testing.T) {
+	publisher := newTestPublisher(t, storage.NewMemoryStorage(0))
+	publisher.AddBlock(&evm.Block{Number: "0x0", Hash: "0xa0"})
+
+	previous := pubsub.DefaultBlockReader
+	pubsub.SetDefaultBlockReader(pubsub.NewBlockReader(publisher, nil))
+	t.Cleanup(func() { pubsub.SetDefaultBlockReader(previous) })
+
+	router := gin.New()
+	router.GET("/blocks", controller.GetBlocks)
+
+	cases := []struct {
+		name     string
+		query    string
+		status   int
+		expected string
+	}{
+		{name: "Missing from", query: "", status: http.StatusBadRequest, expected: "Missing from"},
+		{name: "Empty from", query: "from=", status: http.StatusBadRequest, expected: "Invalid from"},
+		{name: "Invalid from", query: "from=abc", status: http.StatusBadRequest, expected: "Invalid from"},
+		{name: "Invalid to", query: "from=5&to=2", status: http.StatusBadRequest, expected: "Invalid to"},
+		{name: "Genesis", query: "from=0", status: http.StatusOK},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/blocks?"+c.query, nil))
+
+			assert.Equal(t, c.status, recorder.Code)
+
+			var response apiResponse
+			assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+			assert.Equal(t, c.expected, response.Error)
+		})
+	}
+}
@@ -1,11 +1,11 @@
 package controller
 
 import (
+	ethereumParser "ethereum-parser/pkg/ethereum-parser"
 	evm "ethereum-parser/pkg/ethereum-rpc-client"
 	pubsub "ethereum-parser/pkg/pub-sub"
 	"ethereum-parser/util"
 	"net/http"
-	"strings"
 
 	"github.com/gin-gonic/gin"
 )
@@ -26,9 +26,8 @@ func GetCurrentBlock(c *gin.Context) {
 }
 
 func GetCurrentBlockTransactionsByAddress(c *gin.Context) {
-	address := c.Param("address")
-
-	if util.IsValidAddress(address) == false {
+	address, err := util.ParseAddress(c.Param("address"))
+	if err != nil {
 		c.JSON(http.StatusBadRequest, util.GetFailResponse("Invalid address"))
 		return
 	}
@@ -41,24 +40,49 @@ func GetCurrentBlockTransactionsByAddress(c *gin.Context) {
 	}
 
 	blockTxs := &block.Transactions
-	addressMapping := map[string]bool{strings.ToLower(address): true}
+	addressMapping := map[util.Address]bool{address: true}
 	filteredTxs := filterTransactionsByAddresses(blockTxs, &addressMapping)
 
+	nftTransfers := []ethereumParser.NFTTransfer{}
+	for _, tx := range filteredTxs {
+		for _, transfer := range ethereumParser.DecodeNFTTransfers(tx) {
+			if addressMapping[transfer.From] || addressMapping[transfer.To] {
+				nftTransfers = append(nftTransfers, transfer)
+			}
+		}
+	}
+
 	response := map[string]interface{}{
 		"address":      address,
 		"transactions": filteredTxs,
+		"nftTransfers": nftTransfers,
 	}
 	c.JSON(http.StatusOK, util.GetSuccessResponse(response))
 }
 
-func filterTransactionsByAddresses(transactions *[]evm.Transaction, address *map[string]bool) []evm.Transaction {
+func filterTransactionsByAddresses(transactions *[]evm.Transaction, address *map[util.Address]bool) []evm.Transaction {
 	var targetTxs []evm.Transaction
 
 	for _, tx := range *transactions {
-		if (*address)[strings.ToLower(tx.From)] || (*address)[strings.ToLower(tx.To)] {
+		if hasAddress(tx.From, address) || hasAddress(tx.To, address) || hasNFTTransfer(tx, address) {
 			targetTxs = append(targetTxs, tx)
 		}
 	}
 
 	return targetTxs
 }
+
+func hasAddress(hex string, address *map[util.Address]bool) bool {
+	parsed, err := util.ParseAddress(hex)
+	return err == nil && (*address)[parsed]
+}
+
+func hasNFTTransfer(tx evm.Transaction, address *map[util.Address]bool) bool {
+	for _, transfer := range ethereumParser.DecodeNFTTransfers(tx) {
+		if (*address)[transfer.From] || (*address)[transfer.To] {
+			return true
+		}
+	}
+
+	return false
+}
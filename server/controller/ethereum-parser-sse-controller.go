@@ -0,0 +1,379 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"ethereum-parser/logger"
+	ethereumParser "ethereum-parser/pkg/ethereum-parser"
+	evm "ethereum-parser/pkg/ethereum-rpc-client"
+	pubsub "ethereum-parser/pkg/pub-sub"
+	"ethereum-parser/pkg/storage"
+	"ethereum-parser/util"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// streamReplayBatchSize is the number of stored blocks replayed at a time
+const streamReplayBatchSize = 100
+
+// StreamMaxReplay bounds how many blocks behind the latest stored one a client
+// can resume from.
+var StreamMaxReplay = 1000
+
+// StreamHeartbeatInterval is how often idle streams get a comment frame, which
+// keeps proxies from closing them.
+var StreamHeartbeatInterval = 15 * time.Second
+
+// stream is a Server-Sent Events connection. Each event has the number of its
+// block as id, which the client sends back as Last-Event-ID on reconnection.
+type stream struct {
+	c          *gin.Context
+	parser     *ethereumParser.BasicEthereumParser
+	subscriber *pubsub.BlockSubscriber
+	// pending holds the live events taken off the subscriber during the
+	// replay, which would drop them once its channel is full
+	pending []*pubsub.Event
+	// replayedTo is the last block replayed from storage, live blocks up to it
+	// were already sent
+	replayedTo int
+}
+
+// HandleStream pushes the Transactions, Reorg, RemovedTransactions and
+// TransactionStatus events of the addresses query parameter, a comma separated
+// list, as Server-Sent Events. A client resuming with Last-Event-ID first gets
+// the events of the stored blocks after it, unless they are no longer stored or
+// more than StreamMaxReplay blocks behind.
+func HandleStream(c *gin.Context) {
+	log := logger.Logger
+	publisher := pubsub.DefaultPublisher
+
+	var hexes []string
+	for _, value := range c.QueryArray("addresses") {
+		for _, hex := range strings.Split(value, ",") {
+			if hex = strings.TrimSpace(hex); hex != "" {
+				hexes = append(hexes, hex)
+			}
+		}
+	}
+	addresses, err := parseAddresses(hexes)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, util.GetFailResponse(err.Error()))
+		return
+	}
+	if len(addresses) == 0 {
+		c.JSON(http.StatusBadRequest, util.GetFailResponse("No addresses given"))
+		return
+	}
+
+	lastEventID := -1
+	// EventSource only sends the header on reconnection, the query parameter
+	// lets a new page resume too
+	value := c.GetHeader("Last-Event-ID")
+	if value == "" {
+		value = c.Query("lastEventId")
+	}
+	if value != "" {
+		if lastEventID, err = parseBlockParam(value); err != nil || lastEventID < 0 {
+			c.JSON(http.StatusBadRequest, util.GetFailResponse("Invalid Last-Event-ID"))
+			return
+		}
+	}
+
+	// Sessions keep their parser state in memory, the configured storage only
+	// holds what outlives a connection
+	parser := ethereumParser.NewBasicEthereumParser()
+
+	for _, address := range addresses {
+		if _, err := parser.Subscribe(address.Lower()); err != nil {
+			log.Error("Failed to subscribe, " + err.Error())
+			c.JSON(http.StatusInternalServerError, util.GetFailResponse("Failed to subscribe"))
+			return
+		}
+	}
+
+	// Subscribed before the replay so that no block falls between the two
+	subscriber := pubsub.NewBlockSubscriber()
+	publisher.Watch(subscriber, addresses...)
+	publisher.Subscribe(subscriber)
+	defer publisher.Unsubscribe(subscriber)
+
+	if lastEventID >= 0 {
+		replayable, err := canReplay(publisher.Storage(), lastEventID+1)
+		if err != nil {
+			log.Error("Failed to read stored blocks, " + err.Error())
+			c.JSON(http.StatusInternalServerError, util.GetFailResponse("Failed to read stored blocks"))
+			return
+		}
+		if !replayable {
+			c.JSON(http.StatusBadRequest, util.GetFailResponse("Last-Event-ID is too old, reconnect without it"))
+			return
+		}
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	// Stops nginx from buffering the stream
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	s := &stream{c: c, parser: parser, subscriber: subscriber, replayedTo: -1}
+	if lastEventID >= 0 {
+		if err := s.replay(publisher.Storage(), lastEventID+1); err != nil {
+			log.Error("Failed to replay stream, " + err.Error())
+			return
+		}
+	}
+
+	for _, event := range s.pending {
+		if err := s.handle(event); err != nil {
+			log.Error("Failed to notify stream, " + err.Error())
+			return
+		}
+	}
+	s.pending = nil
+
+	heartbeat := time.NewTicker(StreamHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		var err error
+
+		select {
+		case event := <-subscriber.Handler:
+			err = s.handle(event)
+		case <-heartbeat.C:
+			err = s.write(": heartbeat\n\n")
+		case <-subscriber.Quit:
+			return
+		case <-c.Request.Context().Done():
+			return
+		}
+
+		if err != nil {
+			log.Error("Failed to notify stream, " + err.Error())
+			return
+		}
+	}
+}
+
+// canReplay reports whether the blocks from from onwards are all stored, and
+// few enough to replay.
+func canReplay(store storage.Storage, from int) (bool, error) {
+	latest, err := store.GetLatestBlock()
+	if errors.Is(err, storage.ErrNoBlocks) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	to, err := util.ParseBlockNumber(latest.Number)
+	if err != nil {
+		return false, err
+	}
+	if from > to {
+		return true, nil
+	}
+	if to-from+1 > StreamMaxReplay {
+		return false, nil
+	}
+
+	oldest, err := store.GetBlocks(0, to, 1, false)
+	if err != nil {
+		return false, err
+	}
+	if len(oldest) == 0 {
+		return true, nil
+	}
+	first, err := util.ParseBlockNumber(oldest[0].Number)
+	if err != nil {
+		return false, err
+	}
+
+	return from >= first, nil
+}
+
+func (s *stream) handle(event *pubsub.Event) error {
+	switch {
+	case event == nil:
+		return nil
+	case event.Reorg != nil:
+		return s.notifyReorg(event.Reorg)
+	case event.Block != nil:
+		return s.notifyBlock(event.Block)
+	case event.Head != nil:
+		return s.notifyHead(event.Head)
+	}
+
+	return nil
+}
+
+// buffer moves the live events published so far to pending.
+func (s *stream) buffer() {
+	for {
+		select {
+		case event := <-s.subscriber.Handler:
+			s.pending = append(s.pending, event)
+		default:
+			return
+		}
+	}
+}
+
+// replay sends the events of the stored blocks from from onwards, buffering
+// the live events meanwhile.
+func (s *stream) replay(store storage.Storage, from int) error {
+	latest, err := store.GetLatestBlock()
+	if errors.Is(err, storage.ErrNoBlocks) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	to, err := util.ParseBlockNumber(latest.Number)
+	if err != nil {
+		return err
+	}
+
+	for from <= to {
+		blocks, err := store.GetBlocks(from, to, streamReplayBatchSize, false)
+		if err != nil {
+			return errors.New("error reading blocks, " + err.Error())
+		}
+		if len(blocks) == 0 {
+			break
+		}
+
+		for i := range blocks {
+			if err := s.notifyBlock(&blocks[i]); err != nil {
+				return err
+			}
+			s.buffer()
+		}
+
+		last, err := util.ParseBlockNumber(blocks[len(blocks)-1].Number)
+		if err != nil {
+			return err
+		}
+		from = last + 1
+	}
+
+	s.replayedTo = to
+
+	return nil
+}
+
+func (s *stream) notifyBlock(block *evm.Block) error {
+	number, err := util.ParseBlockNumber(block.Number)
+	if err != nil {
+		return err
+	}
+	if number <= s.replayedTo {
+		return nil
+	}
+	s.replayedTo = -1
+
+	activity, err := s.parser.ParseBlockActivity(s.c.Request.Context(), block)
+	if err != nil {
+		return errors.New("Failed to parse block, " + err.Error())
+	}
+
+	if len(activity.Transactions) == 0 {
+		return nil
+	}
+
+	response := map[string]interface{}{
+		"action": "Transactions",
+		"status": ethereumParser.StatusPending,
+		"txs":    activity.Transactions,
+	}
+
+	return s.send(strconv.Itoa(number), response)
+}
+
+// notifyReorg has the id of the common ancestor, a client resuming from it
+// gets the blocks that replaced the removed ones.
+func (s *stream) notifyReorg(reorg *pubsub.ReorgEvent) error {
+	removedTxs, err := s.parser.RemoveBlocks(reorg.RemovedBlocks)
+	if err != nil {
+		return errors.New("Failed to remove blocks, " + err.Error())
+	}
+
+	// Replaced blocks up to the last replayed one are new to the client
+	if s.replayedTo > reorg.CommonAncestor {
+		s.replayedTo = reorg.CommonAncestor
+	}
+
+	removedBlocks := make([]map[string]string, 0, len(reorg.RemovedBlocks))
+	for _, block := range reorg.RemovedBlocks {
+		removedBlocks = append(removedBlocks, map[string]string{
+			"number": block.Number,
+			"hash":   block.Hash,
+		})
+	}
+
+	response := map[string]interface{}{
+		"action":         "Reorg",
+		"commonAncestor": reorg.CommonAncestor,
+		"removedBlocks":  removedBlocks,
+	}
+	if err := s.send(strconv.Itoa(reorg.CommonAncestor), response); err != nil {
+		return err
+	}
+
+	if len(removedTxs) == 0 {
+		return nil
+	}
+
+	response = map[string]interface{}{
+		"action": "RemovedTransactions",
+		"txs":    removedTxs,
+	}
+
+	return s.send("", response)
+}
+
+func (s *stream) notifyHead(head *pubsub.HeadEvent) error {
+	statuses := s.parser.UpdateHead(head)
+	if len(statuses) == 0 {
+		return nil
+	}
+
+	response := map[string]interface{}{
+		"action":   "TransactionStatus",
+		"statuses": statuses,
+	}
+
+	return s.send("", response)
+}
+
+// send writes response as an event named after its action. Events without id
+// leave the Last-Event-ID of the client unchanged.
+func (s *stream) send(id string, response map[string]interface{}) error {
+	data, err := json.Marshal(util.GetSuccessResponse(response))
+	if err != nil {
+		return err
+	}
+
+	message := fmt.Sprintf("event: %s\ndata: %s\n\n", response["action"], data)
+	if id != "" {
+		message = "id: " + id + "\n" + message
+	}
+
+	return s.write(message)
+}
+
+func (s *stream) write(message string) error {
+	if _, err := s.c.Writer.WriteString(message); err != nil {
+		return err
+	}
+	s.c.Writer.Flush()
+
+	return nil
+}
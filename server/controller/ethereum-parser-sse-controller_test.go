@@ -0,0 +1,326 @@
+package controller_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	evm "ethereum-parser/pkg/ethereum-rpc-client"
+	pubsub "ethereum-parser/pkg/pub-sub"
+	"ethereum-parser/pkg/storage"
+	"ethereum-parser/server/controller"
+)
+
+// sseEvent is a frame of a stream, comment holds the text of comment frames.
+type sseEvent struct {
+	id      string
+	event   string
+	data    string
+	comment string
+}
+
+type sseClient struct {
+	reader *bufio.Reader
+}
+
+// next reads the next frame of the stream.
+func (c *sseClient) next(t *testing.T) sseEvent {
+	var event sseEvent
+	for {
+		line, err := c.reader.ReadString('\n')
+		if !assert.NoError(t, err) {
+			return event
+		}
+
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			return event
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "":
+			event.comment = value
+		case "id":
+			event.id = value
+		case "event":
+			event.event = value
+		case "data":
+			event.data = value
+		}
+	}
+}
+
+// nextEvent skips heartbeats up to the next event.
+func (c *sseClient) nextEvent(t *testing.T) sseEvent {
+	for {
+		event := c.next(t)
+		if event.event != "" || t.Failed() {
+			return event
+		}
+	}
+}
+
+func newStreamServer(t *testing.T, store storage.Storage) (*pubsub.BlockPublisher, *httptest.Server) {
+	publisher := newTestPublisher(t, store)
+
+	router := gin.New()
+	router.GET("/stream", controller.HandleStream)
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	return publisher, server
+}
+
+func openStream(t *testing.T, server *httptest.Server, query string, lastEventID string) *sseClient {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+
+	request, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/stream?"+query, nil)
+	if lastEventID != "" {
+		request.Header.Set("Last-Event-ID", lastEventID)
+	}
+
+	response, err := http.DefaultClient.Do(request)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	t.Cleanup(func() { response.Body.Close() })
+
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "text/event-stream", response.Header.Get("Content-Type"))
+
+	return &sseClient{reader: bufio.NewReader(response.Body)}
+}
+
+func aliceBlock(number, hash string) *evm.Block {
+	return &evm.Block{
+		Number: number,
+		Hash:   hash,
+		Transactions: []evm.Transaction{
+			{Hash: hash, From: alice.String(), To: bob.String(), BlockNumber: number, TransactionIndex: "0x0"},
+		},
+	}
+}
+
+func TestHandleStream_Validation(t *testing.T) {
+	newTestPublisher(t, storage.NewMemoryStorage(0))
+
+	router := gin.New()
+	router.GET("/stream", controller.HandleStream)
+
+	cases := []struct {
+		name        string
+		query       string
+		lastEventID string
+		expected    string
+	}{
+		{name: "No addresses", query: "", expected: "No addresses given"},
+		{name: "Empty addresses", query: "addresses=,%20,", expected: "No addresses given"},
+		{name: "Invalid address", query: "addresses=" + alice.String() + ",0x1", expected: "invalid address"},
+		{name: "Invalid Last-Event-ID header", query: "addresses=" + alice.String(), lastEventID: "abc", expected: "Invalid Last-Event-ID"},
+		{name: "Negative lastEventId", query: "addresses=" + alice.String() + "&lastEventId=-1", expected: "Invalid Last-Event-ID"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/stream?"+c.query, nil)
+			if c.lastEventID != "" {
+				request.Header.Set("Last-Event-ID", c.lastEventID)
+			}
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, request)
+
+			assert.Equal(t, http.StatusBadRequest, recorder.Code)
+
+			var response apiResponse
+			assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+			assert.Contains(t, strings.ToLower(response.Error), strings.ToLower(c.expected))
+		})
+	}
+}
+
+func TestHandleStream_ReplayLimits(t *testing.T) {
+	maxReplay := controller.StreamMaxReplay
+	controller.StreamMaxReplay = 3
+	defer func() { controller.StreamMaxReplay = maxReplay }()
+
+	store := storage.NewMemoryStorage(0)
+	for number := 5; number <= 10; number++ {
+		store.AddBlock(aliceBlock("0x"+strconv.FormatInt(int64(number), 16), "0xa"+strconv.Itoa(number)))
+	}
+	_, server := newStreamServer(t, store)
+
+	cases := []struct {
+		name        string
+		lastEventID string
+	}{
+		{name: "Older than the stored blocks", lastEventID: "3"},
+		{name: "More than StreamMaxReplay blocks behind", lastEventID: "6"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			request, _ := http.NewRequest(http.MethodGet, server.URL+"/stream?addresses="+alice.String(), nil)
+			request.Header.Set("Last-Event-ID", c.lastEventID)
+			response, err := http.DefaultClient.Do(request)
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer response.Body.Close()
+
+			assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+
+			var body apiResponse
+			assert.NoError(t, json.NewDecoder(response.Body).Decode(&body))
+			assert.Equal(t, "Last-Event-ID is too old, reconnect without it", body.Error)
+		})
+	}
+
+	client := openStream(t, server, "addresses="+alice.String(), "7")
+	assert.Equal(t, "8", client.nextEvent(t).id, "Recent Last-Event-ID should be replayed")
+}
+
+func TestHandleStream_Heartbeat(t *testing.T) {
+	interval := controller.StreamHeartbeatInterval
+	controller.StreamHeartbeatInterval = 10 * time.Millisecond
+	defer func() { controller.StreamHeartbeatInterval = interval }()
+
+	_, server := newStreamServer(t, storage.NewMemoryStorage(0))
+	client := openStream(t, server, "addresses="+alice.String(), "")
+
+	event := client.next(t)
+	assert.Equal(t, sseEvent{comment: "heartbeat"}, event, "Idle streams should get heartbeats")
+}
+
+func TestHandleStream_Resume(t *testing.T) {
+	store := storage.NewMemoryStorage(0)
+	for _, block := range []*evm.Block{aliceBlock("0x1", "0xa1"), aliceBlock("0x2", "0xa2"), aliceBlock("0x3", "0xa3")} {
+		store.AddBlock(block)
+	}
+	// Blocks without transactions of the address send no event
+	store.AddBlock(&evm.Block{Number: "0x4", Hash: "0xa4"})
+
+	publisher, server := newStreamServer(t, store)
+	client := openStream(t, server, "addresses="+strings.ToLower(alice.String()), "1")
+
+	event := client.nextEvent(t)
+	assert.Equal(t, "2", event.id, "Replay should start after Last-Event-ID")
+	assert.Equal(t, "Transactions", event.event)
+
+	var response struct {
+		Data struct {
+			Action string            `json:"action"`
+			Txs    []evm.Transaction `json:"txs"`
+		} `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal([]byte(event.data), &response))
+	assert.Equal(t, "Transactions", response.Data.Action)
+	assert.Len(t, response.Data.Txs, 1)
+	assert.Equal(t, "0xa2", response.Data.Txs[0].Hash)
+
+	assert.Equal(t, "3", client.nextEvent(t).id)
+
+	// Live blocks up to the latest stored one were already replayed
+	publisher.Publish(aliceBlock("0x3", "0xa3"))
+	publisher.Publish(aliceBlock("0x4", "0xa4"))
+	publisher.Publish(aliceBlock("0x5", "0xa5"))
+	publisher.Publish(aliceBlock("0x6", "0xa6"))
+
+	event = client.nextEvent(t)
+	assert.Equal(t, "5", event.id, "Replayed blocks should not be sent twice")
+	assert.Contains(t, event.data, "0xa5")
+	assert.Equal(t, "6", client.nextEvent(t).id, "Live blocks should follow the replay")
+}
+
+func TestHandleStream_LastEventIDQuery(t *testing.T) {
+	store := storage.NewMemoryStorage(0)
+	store.AddBlock(aliceBlock("0x1", "0xa1"))
+	store.AddBlock(aliceBlock("0x2", "0xa2"))
+
+	_, server := newStreamServer(t, store)
+	client := openStream(t, server, "addresses="+alice.String()+"&lastEventId=0", "")
+
+	assert.Equal(t, "1", client.nextEvent(t).id, "The query parameter should resume like the header")
+	assert.Equal(t, "2", client.nextEvent(t).id)
+}
+
+func TestHandleStream_ReorgAndHead(t *testing.T) {
+	publisher, server := newStreamServer(t, storage.NewMemoryStorage(0))
+	client := openStream(t, server, "addresses="+alice.String(), "")
+
+	orphan := aliceBlock("0x2", "0xb2")
+	publisher.Publish(aliceBlock("0x1", "0xa1"))
+	publisher.Publish(orphan)
+	assert.Equal(t, "1", client.nextEvent(t).id)
+	assert.Equal(t, "2", client.nextEvent(t).id)
+
+	publisher.PublishReorg(&pubsub.ReorgEvent{CommonAncestor: 1, RemovedBlocks: []*evm.Block{orphan}})
+
+	event := client.nextEvent(t)
+	assert.Equal(t, "Reorg", event.event)
+	assert.Equal(t, "1", event.id, "Reorgs should resume from the common ancestor")
+	assert.Contains(t, event.data, `"removedBlocks":[{"hash":"0xb2","number":"0x2"}]`)
+
+	event = client.nextEvent(t)
+	assert.Equal(t, "RemovedTransactions", event.event)
+	assert.Empty(t, event.id)
+	assert.Contains(t, event.data, `"Hash":"0xb2"`)
+
+	publisher.Publish(aliceBlock("0x2", "0xa2"))
+	event = client.nextEvent(t)
+	assert.Equal(t, "2", event.id)
+	assert.Contains(t, event.data, "0xa2")
+
+	// The orphaned transaction is no longer tracked
+	publisher.PublishHead(&pubsub.HeadEvent{Latest: 3, Finalized: 2})
+
+	event = client.nextEvent(t)
+	assert.Equal(t, "TransactionStatus", event.event)
+	assert.Empty(t, event.id)
+
+	var response struct {
+		Data struct {
+			Statuses []struct {
+				Status      string          `json:"status"`
+				Transaction evm.Transaction `json:"transaction"`
+			} `json:"statuses"`
+		} `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal([]byte(event.data), &response))
+	assert.Len(t, response.Data.Statuses, 2)
+	for _, status := range response.Data.Statuses {
+		assert.Equal(t, "finalized", status.Status)
+		assert.NotEqual(t, "0xb2", status.Transaction.Hash)
+	}
+}
+
+func TestHandleStream_ReorgAfterReplay(t *testing.T) {
+	store := storage.NewMemoryStorage(0)
+	store.AddBlock(aliceBlock("0x1", "0xa1"))
+	store.AddBlock(aliceBlock("0x2", "0xa2"))
+
+	publisher, server := newStreamServer(t, store)
+	client := openStream(t, server, "addresses="+alice.String(), "0")
+	assert.Equal(t, "1", client.nextEvent(t).id)
+	assert.Equal(t, "2", client.nextEvent(t).id)
+
+	publisher.PublishReorg(&pubsub.ReorgEvent{CommonAncestor: 1, RemovedBlocks: []*evm.Block{aliceBlock("0x2", "0xa2")}})
+	assert.Equal(t, "Reorg", client.nextEvent(t).event)
+	assert.Equal(t, "RemovedTransactions", client.nextEvent(t).event)
+
+	publisher.Publish(aliceBlock("0x2", "0xc2"))
+	event := client.nextEvent(t)
+	assert.Equal(t, "2", event.id, "Blocks replacing replayed ones should be sent")
+	assert.Contains(t, event.data, "0xc2")
+}
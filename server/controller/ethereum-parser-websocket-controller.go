@@ -1,10 +1,12 @@
 package controller
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
 	"strings"
+	"sync"
 
 	"ethereum-parser/logger"
 
@@ -12,6 +14,8 @@ import (
 
 	evm "ethereum-parser/pkg/ethereum-rpc-client"
 	pubsub "ethereum-parser/pkg/pub-sub"
+	"ethereum-parser/pkg/subscription"
+	watchlist "ethereum-parser/pkg/watch-list"
 	"ethereum-parser/util"
 
 	"github.com/gin-gonic/gin"
@@ -22,6 +26,8 @@ func HandleWebSocket(c *gin.Context) {
 	log := logger.Logger
 	publisher := pubsub.DefaultPublisher
 
+	// Sessions keep their parser state in memory, the configured storage only
+	// holds what outlives a connection
 	parser := ethereumParser.NewBasicEthereumParser()
 
 	conn, err := getWebsocketConnection(c)
@@ -32,70 +38,383 @@ func HandleWebSocket(c *gin.Context) {
 	}
 	defer conn.Close()
 
+	// Hijacked connections are left alone by the server shutdown, closing it
+	// ends the read loop below
+	stopClose := context.AfterFunc(c.Request.Context(), func() { conn.Close() })
+	defer stopClose()
+
 	subscriber := pubsub.NewBlockSubscriber()
+	session := newWSSession(conn, parser, publisher, subscriber)
+
 	err = publisher.Subscribe(subscriber)
 	if err != nil {
 		log.Error("Failed to subscribe, " + err.Error())
-		conn.WriteJSON(util.GetFailResponse("Failed to subscribe"))
+		session.writeJSON(util.GetFailResponse("Failed to subscribe"))
 		return
 	}
-	defer publisher.Unsubscribe(subscriber)
-
-	go notifySubscribers(conn, subscriber, parser)
-
-	// Handle incoming actions (GetCurrentBlock, Subscribe, UnSubscribe)
+	// Only the transactions of subscribed addresses are delivered
+	publisher.Watch(subscriber)
+
+	// Unsubscribing closes the subscriber, the notifier is waited for so it
+	// never writes to a closed connection
+	notifierDone := make(chan struct{})
+	go func() {
+		defer close(notifierDone)
+		notifySubscribers(session, subscriber)
+	}()
+	defer func() {
+		session.detachAll()
+		publisher.Unsubscribe(subscriber)
+		<-notifierDone
+	}()
+
+	// Handle incoming actions (GetCurrentBlock, Subscribe, UnSubscribe,
+	// AttachWatchList, DetachWatchList, AttachSubscriptions,
+	// DetachSubscriptions)
 	for {
-		request, err := getRequest(conn)
+		_, message, err := conn.ReadMessage()
 		if err != nil {
-			if err := conn.Close(); err != nil {
-				log.Error("Failed to close connection, " + err.Error())
-				break
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				log.Error("Connection failed to read message, " + err.Error())
 			}
+			return
+		}
 
+		request, err := getRequest(message)
+		if err != nil {
 			log.Error("Failed to get websocket request, " + err.Error())
-			conn.WriteJSON(util.GetFailResponse("Failed to get websocket request, " + err.Error()))
+			session.writeJSON(util.GetFailResponse("Failed to get websocket request, " + err.Error()))
 			continue
 		}
 
 		switch request["action"] {
 		case "GetCurrentBlock":
-			err := handleGetCurrentBlock(conn, parser)
+			err := handleGetCurrentBlock(session)
 			if err != nil {
 				log.Error("Failed to handle GetCurrentBlock, " + err.Error())
 			}
 		case "Subscribe":
 			address, ok := request["address"].(string)
 			if !ok {
-				log.Error("Invalid address format, " + err.Error())
-				conn.WriteJSON(util.GetFailResponse("Invalid address format"))
+				log.Error("Invalid address format")
+				session.writeJSON(util.GetFailResponse("Invalid address format"))
 				continue
 			}
 
-			err := handleSubscribe(conn, parser, address)
+			policy, err := ethereumParser.ParseConfirmationPolicy(request["confirmations"])
+			if err != nil {
+				log.Error("Invalid confirmations, " + err.Error())
+				session.writeJSON(util.GetFailResponse("Invalid confirmations, " + err.Error()))
+				continue
+			}
+
+			err = handleSubscribe(session, address, policy)
 			if err != nil {
 				log.Error("Failed to handle Subscribe, " + err.Error())
 			}
 		case "UnSubscribe":
 			address, ok := request["address"].(string)
 			if !ok {
-				log.Error("Invalid address format, " + err.Error())
-				conn.WriteJSON(util.GetFailResponse("Invalid address format"))
+				log.Error("Invalid address format")
+				session.writeJSON(util.GetFailResponse("Invalid address format"))
 				continue
 			}
 
-			err := handleUnSubscribe(conn, parser, address)
+			err := handleUnSubscribe(session, address)
 			if err != nil {
 				log.Error("Failed to handle UnSubscribe, " + err.Error())
 			}
+		case "GetTransactions":
+			address, ok := request["address"].(string)
+			if !ok {
+				log.Error("Invalid address format")
+				session.writeJSON(util.GetFailResponse("Invalid address format"))
+				continue
+			}
+
+			err := handleGetTransactions(session, address)
+			if err != nil {
+				log.Error("Failed to handle GetTransactions, " + err.Error())
+			}
+		case "AttachWatchList":
+			listID, ok := request["listId"].(string)
+			if !ok {
+				log.Error("Invalid watch-list id format")
+				session.writeJSON(util.GetFailResponse("Invalid watch-list id format"))
+				continue
+			}
+
+			policy, err := ethereumParser.ParseConfirmationPolicy(request["confirmations"])
+			if err != nil {
+				log.Error("Invalid confirmations, " + err.Error())
+				session.writeJSON(util.GetFailResponse("Invalid confirmations, " + err.Error()))
+				continue
+			}
+
+			err = handleAttachWatchList(session, listID, policy)
+			if err != nil {
+				log.Error("Failed to handle AttachWatchList, " + err.Error())
+			}
+		case "DetachWatchList":
+			listID, ok := request["listId"].(string)
+			if !ok {
+				log.Error("Invalid watch-list id format")
+				session.writeJSON(util.GetFailResponse("Invalid watch-list id format"))
+				continue
+			}
+
+			handleDetachWatchList(session, listID)
+		case "AttachSubscriptions":
+			handleAttachSubscriptions(session)
+		case "DetachSubscriptions":
+			handleDetachSubscriptions(session)
 
 		default:
-			if err := conn.WriteJSON(util.GetFailResponse("Invalid Action")); err != nil {
+			if err := session.writeJSON(util.GetFailResponse("Invalid Action")); err != nil {
 				log.Error("Failed to write message, " + err.Error())
 			}
 		}
 	}
 }
 
+// directSource marks an address the connection subscribed to itself, rather
+// than through an attached watch-list or the server side subscriptions.
+const directSource = ""
+
+// subscriptionsAttachment is the attachment of the server side subscriptions,
+// watch-lists are attached under their source.
+const subscriptionsAttachment = "subscriptions"
+
+// wsSession is the state of a WebSocket connection. The notifier goroutine and
+// the request loop both write to the connection, which gorilla does not allow
+// concurrently, so every write goes through writeJSON.
+//
+// An address is subscribed while the connection asked for it directly, an
+// attached watch-list holds it or it has a server side subscription, sources
+// keeps track of which of them did.
+type wsSession struct {
+	conn       *websocket.Conn
+	parser     *ethereumParser.BasicEthereumParser
+	publisher  *pubsub.BlockPublisher
+	subscriber *pubsub.BlockSubscriber
+	writeMutex sync.Mutex
+
+	stateMutex  sync.Mutex
+	sources     map[util.Address]map[string]struct{}
+	attachments map[string]func() // Detach function by attachment
+}
+
+func newWSSession(conn *websocket.Conn, parser *ethereumParser.BasicEthereumParser, publisher *pubsub.BlockPublisher, subscriber *pubsub.BlockSubscriber) *wsSession {
+	return &wsSession{
+		conn:        conn,
+		parser:      parser,
+		publisher:   publisher,
+		subscriber:  subscriber,
+		sources:     make(map[util.Address]map[string]struct{}),
+		attachments: make(map[string]func()),
+	}
+}
+
+func (s *wsSession) writeJSON(v interface{}) error {
+	s.writeMutex.Lock()
+	defer s.writeMutex.Unlock()
+
+	return s.conn.WriteJSON(v)
+}
+
+// subscribe adds source to the sources of address, subscribing to it with
+// policy. A direct subscription replaces the policy of the address.
+func (s *wsSession) subscribe(address util.Address, source string, policy ethereumParser.ConfirmationPolicy) error {
+	s.stateMutex.Lock()
+	defer s.stateMutex.Unlock()
+
+	sources, ok := s.sources[address]
+	if ok && source != directSource {
+		sources[source] = struct{}{}
+		return nil
+	}
+
+	if _, err := s.parser.SubscribeWithConfirmations(address.Lower(), policy); err != nil {
+		return err
+	}
+	s.publisher.Watch(s.subscriber, address)
+
+	if !ok {
+		sources = make(map[string]struct{})
+		s.sources[address] = sources
+	}
+	sources[source] = struct{}{}
+
+	return nil
+}
+
+// unsubscribe removes source from the sources of address, unsubscribing from
+// it once none is left.
+func (s *wsSession) unsubscribe(address util.Address, source string) error {
+	s.stateMutex.Lock()
+	defer s.stateMutex.Unlock()
+
+	sources, ok := s.sources[address]
+	if !ok {
+		return nil
+	}
+
+	delete(sources, source)
+	if len(sources) > 0 {
+		return nil
+	}
+
+	if _, err := s.parser.UnSubscribe(address.Lower()); err != nil {
+		return err
+	}
+	s.publisher.Unwatch(s.subscriber, address)
+	delete(s.sources, address)
+
+	return nil
+}
+
+// attachWatchList subscribes to the addresses of the watch-list id, following
+// its changes until it is detached.
+func (s *wsSession) attachWatchList(id string, policy ethereumParser.ConfirmationPolicy) (*watchlist.WatchList, error) {
+	source := watchListSource(id)
+	if s.isAttached(source) {
+		return watchlist.DefaultRegistry.Get(id)
+	}
+
+	list, detach, err := watchlist.DefaultRegistry.Attach(id, func(change watchlist.Change) {
+		for _, address := range change.Added {
+			if err := s.subscribe(address, source, policy); err != nil {
+				logger.Logger.Error("Failed to subscribe to watch-list address, " + err.Error())
+			}
+		}
+		for _, address := range change.Removed {
+			if err := s.unsubscribe(address, source); err != nil {
+				logger.Logger.Error("Failed to unsubscribe from watch-list address, " + err.Error())
+			}
+		}
+		if change.Deleted {
+			s.stateMutex.Lock()
+			delete(s.attachments, source)
+			s.stateMutex.Unlock()
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.stateMutex.Lock()
+	s.attachments[source] = detach
+	s.stateMutex.Unlock()
+
+	return list, nil
+}
+
+// attachSubscriptions subscribes to the addresses of the server side
+// subscriptions, following their changes until they are detached. It reports
+// whether they were not attached yet.
+func (s *wsSession) attachSubscriptions() bool {
+	if s.isAttached(subscriptionsAttachment) {
+		return false
+	}
+
+	detach := subscription.DefaultRegistry.Attach(func(change subscription.Change) {
+		for _, added := range change.Added {
+			if err := s.subscribe(added.Address, subscriptionSource(added.ID), added.Confirmations); err != nil {
+				logger.Logger.Error("Failed to subscribe to server subscription, " + err.Error())
+			}
+		}
+		for _, removed := range change.Removed {
+			if err := s.unsubscribe(removed.Address, subscriptionSource(removed.ID)); err != nil {
+				logger.Logger.Error("Failed to unsubscribe from server subscription, " + err.Error())
+			}
+		}
+	})
+
+	s.stateMutex.Lock()
+	s.attachments[subscriptionsAttachment] = detach
+	s.stateMutex.Unlock()
+
+	return true
+}
+
+func (s *wsSession) isAttached(attachment string) bool {
+	s.stateMutex.Lock()
+	defer s.stateMutex.Unlock()
+
+	_, ok := s.attachments[attachment]
+	return ok
+}
+
+// detach stops following attachment and unsubscribes from the addresses
+// nothing else subscribed to, reporting whether it was attached.
+func (s *wsSession) detach(attachment string) bool {
+	s.stateMutex.Lock()
+	detach, ok := s.attachments[attachment]
+	delete(s.attachments, attachment)
+	s.stateMutex.Unlock()
+	if !ok {
+		return false
+	}
+
+	// The registries call their listeners while locked, so detach must not be
+	// called with the session state locked
+	detach()
+
+	owns := func(source string) bool {
+		return source == attachment
+	}
+	if attachment == subscriptionsAttachment {
+		owns = func(source string) bool {
+			return strings.HasPrefix(source, subscriptionSource(""))
+		}
+	}
+
+	type addressSource struct {
+		address util.Address
+		source  string
+	}
+
+	s.stateMutex.Lock()
+	var owned []addressSource
+	for address, sources := range s.sources {
+		for source := range sources {
+			if owns(source) {
+				owned = append(owned, addressSource{address, source})
+			}
+		}
+	}
+	s.stateMutex.Unlock()
+
+	for _, o := range owned {
+		if err := s.unsubscribe(o.address, o.source); err != nil {
+			logger.Logger.Error("Failed to unsubscribe, " + err.Error())
+		}
+	}
+
+	return true
+}
+
+func (s *wsSession) detachAll() {
+	s.stateMutex.Lock()
+	attachments := make([]string, 0, len(s.attachments))
+	for attachment := range s.attachments {
+		attachments = append(attachments, attachment)
+	}
+	s.stateMutex.Unlock()
+
+	for _, attachment := range attachments {
+		s.detach(attachment)
+	}
+}
+
+func watchListSource(id string) string {
+	return "watch-list:" + id
+}
+
+func subscriptionSource(id string) string {
+	return "subscription:" + id
+}
+
 func getWebsocketConnection(c *gin.Context) (*websocket.Conn, error) {
 	upgrader := websocket.Upgrader{
 		ReadBufferSize:  1024,
@@ -113,38 +432,27 @@ func getWebsocketConnection(c *gin.Context) (*websocket.Conn, error) {
 	return conn, nil
 }
 
-func notifySubscribers(conn *websocket.Conn, subscriber *pubsub.BlockSubscriber, parser *ethereumParser.BasicEthereumParser) {
+// notifySubscribers forwards the events of subscriber to the session until
+// the subscriber is closed.
+func notifySubscribers(session *wsSession, subscriber *pubsub.BlockSubscriber) {
 	for {
 		select {
-		case block := <-subscriber.Handler:
-			if block == nil {
-				continue
-			}
-
-			if len(parser.Subscriptions) == 0 {
-				continue
-			}
-
-			var targetTxs []evm.Transaction
-
-			for _, tx := range block.Transactions {
-				if parser.Subscriptions[strings.ToLower(tx.From)] || parser.Subscriptions[strings.ToLower(tx.To)] {
-					targetTxs = append(targetTxs, tx)
-				}
-			}
-
-			if len(targetTxs) == 0 {
+		case event := <-subscriber.Handler:
+			if event == nil {
 				continue
 			}
 
-			response := map[string]interface{}{
-				"action": "Transactions",
-				"txs":    targetTxs,
+			var err error
+			if event.Reorg != nil {
+				err = notifyReorg(session, event.Reorg)
+			} else if event.Block != nil {
+				err = notifyBlock(session, event.Block)
+			} else if event.Head != nil {
+				err = notifyHead(session, event.Head)
 			}
 
-			err := conn.WriteJSON(util.GetSuccessResponse(response))
 			if err != nil {
-				logger.Logger.Error("Failed to write message, " + err.Error())
+				logger.Logger.Error("Failed to notify subscriber, " + err.Error())
 			}
 
 		case <-subscriber.Quit:
@@ -153,12 +461,107 @@ func notifySubscribers(conn *websocket.Conn, subscriber *pubsub.BlockSubscriber,
 	}
 }
 
-func getRequest(conn *websocket.Conn) (map[string]interface{}, error) {
-	_, message, err := conn.ReadMessage()
+func notifyBlock(session *wsSession, block *evm.Block) error {
+	activity, err := session.parser.ParseBlockActivity(context.Background(), block)
 	if err != nil {
-		return nil, errors.New("Connection failed to read message, " + err.Error())
+		return errors.New("Failed to parse block, " + err.Error())
+	}
+
+	if len(activity.Transactions) == 0 {
+		return nil
+	}
+
+	response := map[string]interface{}{
+		"action": "Transactions",
+		"status": ethereumParser.StatusPending,
+		"txs":    activity.Transactions,
+	}
+	if err := session.writeJSON(util.GetSuccessResponse(response)); err != nil {
+		return err
+	}
+
+	if len(activity.TokenTransfers) > 0 {
+		response = map[string]interface{}{
+			"action":    "TokenTransfers",
+			"transfers": activity.TokenTransfers,
+		}
+		if err := session.writeJSON(util.GetSuccessResponse(response)); err != nil {
+			return err
+		}
+	}
+
+	if len(activity.NFTTransfers) > 0 {
+		response = map[string]interface{}{
+			"action":    "NFTTransfers",
+			"transfers": activity.NFTTransfers,
+		}
+		if err := session.writeJSON(util.GetSuccessResponse(response)); err != nil {
+			return err
+		}
+	}
+
+	if len(activity.InternalTransactions) == 0 {
+		return nil
+	}
+
+	response = map[string]interface{}{
+		"action":      "InternalTransactions",
+		"internalTxs": activity.InternalTransactions,
+	}
+
+	return session.writeJSON(util.GetSuccessResponse(response))
+}
+
+func notifyHead(session *wsSession, head *pubsub.HeadEvent) error {
+	statuses := session.parser.UpdateHead(head)
+	if len(statuses) == 0 {
+		return nil
+	}
+
+	response := map[string]interface{}{
+		"action":   "TransactionStatus",
+		"statuses": statuses,
+	}
+
+	return session.writeJSON(util.GetSuccessResponse(response))
+}
+
+func notifyReorg(session *wsSession, reorg *pubsub.ReorgEvent) error {
+	removedTxs, err := session.parser.RemoveBlocks(reorg.RemovedBlocks)
+	if err != nil {
+		return errors.New("Failed to remove blocks, " + err.Error())
+	}
+
+	removedBlocks := make([]map[string]string, 0, len(reorg.RemovedBlocks))
+	for _, block := range reorg.RemovedBlocks {
+		removedBlocks = append(removedBlocks, map[string]string{
+			"number": block.Number,
+			"hash":   block.Hash,
+		})
+	}
+
+	response := map[string]interface{}{
+		"action":         "Reorg",
+		"commonAncestor": reorg.CommonAncestor,
+		"removedBlocks":  removedBlocks,
+	}
+	if err := session.writeJSON(util.GetSuccessResponse(response)); err != nil {
+		return err
+	}
+
+	if len(removedTxs) == 0 {
+		return nil
 	}
 
+	response = map[string]interface{}{
+		"action": "RemovedTransactions",
+		"txs":    removedTxs,
+	}
+
+	return session.writeJSON(util.GetSuccessResponse(response))
+}
+
+func getRequest(message []byte) (map[string]interface{}, error) {
 	var request map[string]interface{}
 	if err := json.Unmarshal(message, &request); err != nil {
 		return nil, errors.New("Failed to unmarshal message, " + err.Error())
@@ -167,11 +570,11 @@ func getRequest(conn *websocket.Conn) (map[string]interface{}, error) {
 	return request, nil
 }
 
-func handleGetCurrentBlock(conn *websocket.Conn, parser *ethereumParser.BasicEthereumParser) error {
-	block, err := parser.GetCurrentBlock()
+func handleGetCurrentBlock(session *wsSession) error {
+	block, err := session.parser.GetCurrentBlock()
 	if err != nil {
 		logger.Logger.Error("Failed to get current block, " + err.Error())
-		conn.WriteJSON(util.GetFailResponse("Failed to get current block"))
+		session.writeJSON(util.GetFailResponse("Failed to get current block"))
 		return err
 	}
 
@@ -179,48 +582,140 @@ func handleGetCurrentBlock(conn *websocket.Conn, parser *ethereumParser.BasicEth
 		"action": "GetCurrentBlock",
 		"block":  block,
 	}
-	if err := conn.WriteJSON(util.GetSuccessResponse(response)); err != nil {
+	if err := session.writeJSON(util.GetSuccessResponse(response)); err != nil {
 		logger.Logger.Error("Failed to write message, " + err.Error())
 	}
 
 	return nil
 }
 
-func handleSubscribe(conn *websocket.Conn, parser *ethereumParser.BasicEthereumParser, address string) error {
-	subscribed, err := parser.Subscribe(address)
+func handleSubscribe(session *wsSession, address string, policy ethereumParser.ConfirmationPolicy) error {
+	subscribed, err := util.ParseAddress(address)
+	if err == nil {
+		err = session.subscribe(subscribed, directSource, policy)
+	}
 	if err != nil {
 		logger.Logger.Error("Failed to subscribe, " + err.Error())
-		conn.WriteJSON(util.GetFailResponse("Failed to subscribe"))
+		session.writeJSON(util.GetFailResponse("Failed to subscribe"))
 		return err
 	}
 
 	response := map[string]interface{}{
-		"action":     "Subscribe",
-		"subscribed": subscribed,
+		"action":        "Subscribe",
+		"subscribed":    true,
+		"confirmations": policy,
 	}
-	if err := conn.WriteJSON(util.GetSuccessResponse(response)); err != nil {
+	if err := session.writeJSON(util.GetSuccessResponse(response)); err != nil {
 		logger.Logger.Error("Failed to write message, " + err.Error())
 	}
 
 	return nil
 }
 
-func handleUnSubscribe(conn *websocket.Conn, parser *ethereumParser.BasicEthereumParser, address string) error {
-	unsubscribed, err := parser.UnSubscribe(address)
+func handleUnSubscribe(session *wsSession, address string) error {
+	unsubscribed, err := util.ParseAddress(address)
+	if err == nil {
+		err = session.unsubscribe(unsubscribed, directSource)
+	}
 	if err != nil {
 		logger.Logger.Error("Failed to unsubscribe, " + err.Error())
-		conn.WriteJSON(util.GetFailResponse("Failed to unsubscribe"))
+		session.writeJSON(util.GetFailResponse("Failed to unsubscribe"))
 		return err
 	}
 
 	response := map[string]interface{}{
 		"action":       "UnSubscribe",
-		"unsubscribed": unsubscribed,
+		"unsubscribed": true,
+	}
+
+	if err := session.writeJSON(util.GetSuccessResponse(response)); err != nil {
+		logger.Logger.Error("Failed to write message, " + err.Error())
+	}
+
+	return nil
+}
+
+func handleGetTransactions(session *wsSession, address string) error {
+	transactions, err := session.parser.GetTransactions(address)
+	if err != nil {
+		logger.Logger.Error("Failed to get transactions, " + err.Error())
+		session.writeJSON(util.GetFailResponse("Failed to get transactions, " + err.Error()))
+		return err
+	}
+
+	nftTransfers, err := session.parser.GetNFTTransfers(address)
+	if err != nil {
+		logger.Logger.Error("Failed to get NFT transfers, " + err.Error())
+		session.writeJSON(util.GetFailResponse("Failed to get transactions, " + err.Error()))
+		return err
+	}
+
+	response := map[string]interface{}{
+		"action":       "GetTransactions",
+		"address":      util.ChecksumAddress(address),
+		"transactions": transactions,
+		"nftTransfers": nftTransfers,
+	}
+
+	if err := session.writeJSON(util.GetSuccessResponse(response)); err != nil {
+		logger.Logger.Error("Failed to write message, " + err.Error())
+	}
+
+	return nil
+}
+
+func handleAttachWatchList(session *wsSession, listID string, policy ethereumParser.ConfirmationPolicy) error {
+	list, err := session.attachWatchList(listID, policy)
+	if err != nil {
+		logger.Logger.Error("Failed to attach watch-list, " + err.Error())
+		if errors.Is(err, watchlist.ErrNotFound) {
+			session.writeJSON(util.GetFailResponse("Watch-list not found"))
+		} else {
+			session.writeJSON(util.GetFailResponse("Failed to attach watch-list"))
+		}
+		return err
 	}
 
-	if err := conn.WriteJSON(util.GetSuccessResponse(response)); err != nil {
+	response := map[string]interface{}{
+		"action":        "AttachWatchList",
+		"watchList":     list,
+		"confirmations": policy,
+	}
+	if err := session.writeJSON(util.GetSuccessResponse(response)); err != nil {
 		logger.Logger.Error("Failed to write message, " + err.Error())
 	}
 
 	return nil
 }
+
+func handleDetachWatchList(session *wsSession, listID string) {
+	response := map[string]interface{}{
+		"action":   "DetachWatchList",
+		"listId":   listID,
+		"detached": session.detach(watchListSource(listID)),
+	}
+	if err := session.writeJSON(util.GetSuccessResponse(response)); err != nil {
+		logger.Logger.Error("Failed to write message, " + err.Error())
+	}
+}
+
+func handleAttachSubscriptions(session *wsSession) {
+	response := map[string]interface{}{
+		"action":        "AttachSubscriptions",
+		"attached":      session.attachSubscriptions(),
+		"subscriptions": subscription.DefaultRegistry.List(),
+	}
+	if err := session.writeJSON(util.GetSuccessResponse(response)); err != nil {
+		logger.Logger.Error("Failed to write message, " + err.Error())
+	}
+}
+
+func handleDetachSubscriptions(session *wsSession) {
+	response := map[string]interface{}{
+		"action":   "DetachSubscriptions",
+		"detached": session.detach(subscriptionsAttachment),
+	}
+	if err := session.writeJSON(util.GetSuccessResponse(response)); err != nil {
+		logger.Logger.Error("Failed to write message, " + err.Error())
+	}
+}
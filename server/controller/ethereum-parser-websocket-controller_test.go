@@ -0,0 +1,119 @@
+package controller_test
+
+import (
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"ethereum-parser/logger"
+	ethereumParser "ethereum-parser/pkg/ethereum-parser"
+	evm "ethereum-parser/pkg/ethereum-rpc-client"
+	pubsub "ethereum-parser/pkg/pub-sub"
+	"ethereum-parser/pkg/storage"
+	"ethereum-parser/server/controller"
+	"ethereum-parser/util"
+)
+
+var (
+	alice = util.MustParseAddress("0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed")
+	bob   = util.MustParseAddress("0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359")
+)
+
+// recordingStorage records the subscription sets written to the storage it
+// wraps.
+type recordingStorage struct {
+	storage.Storage
+	mutex sync.Mutex
+	sets  []string
+}
+
+func (s *recordingStorage) AddSubscription(set string, address string) error {
+	s.mutex.Lock()
+	s.sets = append(s.sets, set)
+	s.mutex.Unlock()
+
+	return s.Storage.AddSubscription(set, address)
+}
+
+func (s *recordingStorage) recordedSets() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return append([]string(nil), s.sets...)
+}
+
+// newTestPublisher makes a publisher over store the default one for the
+// duration of the test.
+func newTestPublisher(t *testing.T, store storage.Storage) *pubsub.BlockPublisher {
+	logger.Logger = zap.NewNop()
+	gin.SetMode(gin.TestMode)
+
+	publisher := pubsub.NewBlockPublisher(store)
+	publisher.AddressesOf = ethereumParser.InvolvedAddresses
+
+	previous := pubsub.DefaultPublisher
+	pubsub.SetDefaultPublisher(publisher)
+	t.Cleanup(func() { pubsub.SetDefaultPublisher(previous) })
+
+	return publisher
+}
+
+func newBoltStore(t *testing.T) storage.Storage {
+	store, err := storage.NewBoltStorage(filepath.Join(t.TempDir(), "test.db"), 0)
+	assert.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+
+	return store
+}
+
+type apiResponse struct {
+	Data  map[string]interface{} `json:"data"`
+	Error string                 `json:"error"`
+}
+
+func readAction(t *testing.T, conn *websocket.Conn, action string) apiResponse {
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	for {
+		var message apiResponse
+		if !assert.NoError(t, conn.ReadJSON(&message)) {
+			return message
+		}
+		if message.Data["action"] == action {
+			return message
+		}
+	}
+}
+
+func TestHandleWebSocket_Storage(t *testing.T) {
+	store := &recordingStorage{Storage: newBoltStore(t)}
+	publisher := newTestPublisher(t, store)
+
+	router := gin.New()
+	router.GET("/ws", controller.HandleWebSocket)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", nil)
+	assert.NoError(t, err)
+
+	assert.NoError(t, conn.WriteJSON(map[string]interface{}{"action": "Subscribe", "address": alice.String()}))
+	readAction(t, conn, "Subscribe")
+
+	tx := evm.Transaction{Hash: "0x1", From: alice.String(), To: bob.String(), BlockNumber: "0x1", TransactionIndex: "0x0"}
+	publisher.Publish(&evm.Block{Number: "0x1", Hash: "0xaa", Transactions: []evm.Transaction{tx}})
+	readAction(t, conn, "Transactions")
+
+	assert.NoError(t, conn.WriteJSON(map[string]interface{}{"action": "GetTransactions", "address": alice.String()}))
+	message := readAction(t, conn, "GetTransactions")
+	assert.Len(t, message.Data["transactions"], 1, "History should be kept for the session")
+
+	assert.Empty(t, store.recordedSets(), "Sessions should leave nothing in the configured storage")
+}
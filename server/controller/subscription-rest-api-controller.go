@@ -0,0 +1,186 @@
+package controller
+
+import (
+	"errors"
+	"ethereum-parser/config"
+	ethereumParser "ethereum-parser/pkg/ethereum-parser"
+	"ethereum-parser/pkg/subscription"
+	"ethereum-parser/pkg/webhook"
+	"ethereum-parser/util"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type subscriptionRequest struct {
+	Address       string      `json:"address"`
+	Confirmations interface{} `json:"confirmations"`
+	CallbackURL   string      `json:"callbackUrl"`
+	Secret        string      `json:"secret"`
+}
+
+// subscriptionsRequest is either a single subscription or, in bulk, a list of
+// them.
+type subscriptionsRequest struct {
+	subscriptionRequest
+	Subscriptions []subscriptionRequest `json:"subscriptions"`
+}
+
+// CreateSubscriptions subscribes server side to one address, or to every
+// address of a bulk request. Nothing is subscribed when any of them is invalid.
+func CreateSubscriptions(c *gin.Context) {
+	var request subscriptionsRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, util.GetFailResponse("Invalid request, "+err.Error()))
+		return
+	}
+
+	bulk := request.Subscriptions != nil
+	requests := request.Subscriptions
+	if !bulk {
+		requests = []subscriptionRequest{request.subscriptionRequest}
+	}
+	if len(requests) == 0 {
+		c.JSON(http.StatusBadRequest, util.GetFailResponse("No subscriptions given"))
+		return
+	}
+
+	subscriptions := make([]subscription.Subscription, 0, len(requests))
+	for i, r := range requests {
+		s, err := parseSubscription(r)
+		if err != nil {
+			if bulk {
+				err = errors.New("subscription " + strconv.Itoa(i) + ", " + err.Error())
+			}
+			c.JSON(http.StatusBadRequest, util.GetFailResponse(err.Error()))
+			return
+		}
+		subscriptions = append(subscriptions, s)
+	}
+
+	added, err := subscription.DefaultRegistry.Add(subscriptions...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, util.GetFailResponse(err.Error()))
+		return
+	}
+
+	if !bulk {
+		c.JSON(http.StatusCreated, util.GetSuccessResponse(added[0]))
+		return
+	}
+
+	response := map[string]interface{}{
+		"subscriptions": added,
+	}
+	c.JSON(http.StatusCreated, util.GetSuccessResponse(response))
+}
+
+// GetSubscriptions returns every server side subscription, or only those of
+// the address query parameter.
+func GetSubscriptions(c *gin.Context) {
+	subscriptions := subscription.DefaultRegistry.List()
+
+	if value := c.Query("address"); value != "" {
+		address, err := util.ParseAddress(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, util.GetFailResponse("Invalid address"))
+			return
+		}
+
+		filtered := []subscription.Subscription{}
+		for _, s := range subscriptions {
+			if s.Address == address {
+				filtered = append(filtered, s)
+			}
+		}
+		subscriptions = filtered
+	}
+
+	response := map[string]interface{}{
+		"subscriptions": subscriptions,
+	}
+	c.JSON(http.StatusOK, util.GetSuccessResponse(response))
+}
+
+func GetSubscription(c *gin.Context) {
+	s, err := subscription.DefaultRegistry.Get(c.Param("id"))
+	if errors.Is(err, subscription.ErrNotFound) {
+		c.JSON(http.StatusNotFound, util.GetFailResponse("Subscription not found"))
+		return
+	}
+
+	c.JSON(http.StatusOK, util.GetSuccessResponse(s))
+}
+
+func DeleteSubscription(c *gin.Context) {
+	removed, err := subscription.DefaultRegistry.Remove(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, util.GetFailResponse(err.Error()))
+		return
+	}
+	if len(removed) == 0 {
+		c.JSON(http.StatusNotFound, util.GetFailResponse("Subscription not found"))
+		return
+	}
+
+	c.JSON(http.StatusOK, util.GetSuccessResponse(map[string]interface{}{"deleted": true}))
+}
+
+// DeleteSubscriptions removes the subscriptions given by id in bulk, unknown
+// ids are skipped.
+func DeleteSubscriptions(c *gin.Context) {
+	var request struct {
+		IDs []string `json:"ids"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, util.GetFailResponse("Invalid request, "+err.Error()))
+		return
+	}
+	if len(request.IDs) == 0 {
+		c.JSON(http.StatusBadRequest, util.GetFailResponse("No subscription ids given"))
+		return
+	}
+
+	removed, err := subscription.DefaultRegistry.Remove(request.IDs...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, util.GetFailResponse(err.Error()))
+		return
+	}
+
+	response := map[string]interface{}{
+		"deleted": removed,
+	}
+	c.JSON(http.StatusOK, util.GetSuccessResponse(response))
+}
+
+func parseSubscription(request subscriptionRequest) (subscription.Subscription, error) {
+	address, err := util.ParseAddress(request.Address)
+	if err != nil {
+		return subscription.Subscription{}, err
+	}
+
+	policy, err := ethereumParser.ParseConfirmationPolicy(request.Confirmations)
+	if err != nil {
+		return subscription.Subscription{}, errors.New("invalid confirmations, " + err.Error())
+	}
+
+	if request.CallbackURL != "" {
+		allowPrivate := config.GetConfig().Webhook.AllowPrivateCallbacks
+		if err := webhook.ValidateCallbackURL(request.CallbackURL, allowPrivate); err != nil {
+			return subscription.Subscription{}, err
+		}
+		if request.Secret == "" {
+			return subscription.Subscription{}, errors.New("callback secret is empty")
+		}
+	} else if request.Secret != "" {
+		return subscription.Subscription{}, errors.New("secret given without callback url")
+	}
+
+	return subscription.Subscription{
+		Address:       address,
+		Confirmations: policy,
+		CallbackURL:   request.CallbackURL,
+		Secret:        request.Secret,
+	}, nil
+}
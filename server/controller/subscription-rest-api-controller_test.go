@@ -0,0 +1,58 @@
+package controller_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"ethereum-parser/config"
+	"ethereum-parser/pkg/storage"
+	"ethereum-parser/pkg/subscription"
+	"ethereum-parser/server/controller"
+)
+
+func TestCreateSubscriptions_CallbackURL(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	registry, _ := subscription.NewRegistry(storage.NewMemoryStorage(0))
+	previous := subscription.DefaultRegistry
+	subscription.SetDefaultRegistry(registry)
+	defer subscription.SetDefaultRegistry(previous)
+
+	router := gin.New()
+	router.POST("/subscriptions", controller.CreateSubscriptions)
+
+	create := func(callbackURL string) (int, string) {
+		body := `{"address":"` + alice.String() + `","callbackUrl":"` + callbackURL + `","secret":"secret"}`
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/subscriptions", strings.NewReader(body)))
+
+		var response apiResponse
+		json.Unmarshal(recorder.Body.Bytes(), &response)
+		return recorder.Code, response.Error
+	}
+
+	code, message := create("http://169.254.169.254/latest/meta-data")
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "callback host is not public, 169.254.169.254", message)
+
+	code, _ = create("http://localhost:8080/hook")
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Empty(t, registry.List(), "Refused subscriptions should not be added")
+
+	code, _ = create("https://example.com/hook")
+	assert.Equal(t, http.StatusCreated, code)
+
+	// Private callbacks are opt-in
+	config.GetConfig().Webhook.AllowPrivateCallbacks = true
+	defer func() { config.GetConfig().Webhook.AllowPrivateCallbacks = false }()
+
+	code, _ = create("http://localhost:8080/hook")
+	assert.Equal(t, http.StatusCreated, code)
+	assert.Len(t, registry.List(), 2)
+}
@@ -0,0 +1,148 @@
+package controller
+
+import (
+	"errors"
+	watchlist "ethereum-parser/pkg/watch-list"
+	"ethereum-parser/util"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type watchListRequest struct {
+	Name      string   `json:"name"`
+	Addresses []string `json:"addresses"`
+}
+
+func CreateWatchList(c *gin.Context) {
+	var request watchListRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, util.GetFailResponse("Invalid request, "+err.Error()))
+		return
+	}
+
+	addresses, err := parseAddresses(request.Addresses)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, util.GetFailResponse(err.Error()))
+		return
+	}
+
+	list, err := watchlist.DefaultRegistry.Create(request.Name, addresses)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, util.GetFailResponse(err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusCreated, util.GetSuccessResponse(list))
+}
+
+func GetWatchLists(c *gin.Context) {
+	response := map[string]interface{}{
+		"watchLists": watchlist.DefaultRegistry.List(),
+	}
+	c.JSON(http.StatusOK, util.GetSuccessResponse(response))
+}
+
+func GetWatchList(c *gin.Context) {
+	list, err := watchlist.DefaultRegistry.Get(c.Param("id"))
+	if err != nil {
+		writeWatchListError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, util.GetSuccessResponse(list))
+}
+
+func DeleteWatchList(c *gin.Context) {
+	if err := watchlist.DefaultRegistry.Delete(c.Param("id")); err != nil {
+		writeWatchListError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, util.GetSuccessResponse(map[string]interface{}{"deleted": true}))
+}
+
+func AddWatchListAddresses(c *gin.Context) {
+	addresses, ok := bindAddresses(c)
+	if !ok {
+		return
+	}
+
+	list, err := watchlist.DefaultRegistry.AddAddresses(c.Param("id"), addresses...)
+	if err != nil {
+		writeWatchListError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, util.GetSuccessResponse(list))
+}
+
+func RemoveWatchListAddresses(c *gin.Context) {
+	var addresses []util.Address
+	if c.Param("address") != "" {
+		address, err := util.ParseAddress(c.Param("address"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, util.GetFailResponse("Invalid address"))
+			return
+		}
+		addresses = append(addresses, address)
+	} else {
+		var ok bool
+		if addresses, ok = bindAddresses(c); !ok {
+			return
+		}
+	}
+
+	list, err := watchlist.DefaultRegistry.RemoveAddresses(c.Param("id"), addresses...)
+	if err != nil {
+		writeWatchListError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, util.GetSuccessResponse(list))
+}
+
+// bindAddresses reads the addresses of a request body, writing the error
+// response when it is invalid.
+func bindAddresses(c *gin.Context) ([]util.Address, bool) {
+	var request watchListRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, util.GetFailResponse("Invalid request, "+err.Error()))
+		return nil, false
+	}
+
+	if len(request.Addresses) == 0 {
+		c.JSON(http.StatusBadRequest, util.GetFailResponse("No addresses given"))
+		return nil, false
+	}
+
+	addresses, err := parseAddresses(request.Addresses)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, util.GetFailResponse(err.Error()))
+		return nil, false
+	}
+
+	return addresses, true
+}
+
+func parseAddresses(hexes []string) ([]util.Address, error) {
+	addresses := make([]util.Address, 0, len(hexes))
+	for _, hex := range hexes {
+		address, err := util.ParseAddress(hex)
+		if err != nil {
+			return nil, err
+		}
+		addresses = append(addresses, address)
+	}
+
+	return addresses, nil
+}
+
+func writeWatchListError(c *gin.Context, err error) {
+	if errors.Is(err, watchlist.ErrNotFound) {
+		c.JSON(http.StatusNotFound, util.GetFailResponse("Watch-list not found"))
+		return
+	}
+
+	c.JSON(http.StatusInternalServerError, util.GetFailResponse(err.Error()))
+}
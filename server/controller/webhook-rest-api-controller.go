@@ -0,0 +1,79 @@
+package controller
+
+import (
+	"errors"
+	"ethereum-parser/pkg/webhook"
+	"ethereum-parser/util"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetWebhookDeliveries returns the webhook deliveries with the status query
+// parameter, or all of them, oldest first.
+func GetWebhookDeliveries(c *gin.Context) {
+	status := c.Query("status")
+	switch status {
+	case "", webhook.StatusPending, webhook.StatusDelivered, webhook.StatusFailed:
+	default:
+		c.JSON(http.StatusBadRequest, util.GetFailResponse("Invalid status"))
+		return
+	}
+
+	deliveries, err := webhook.DefaultDispatcher.GetDeliveries(status)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, util.GetFailResponse(err.Error()))
+		return
+	}
+
+	response := map[string]interface{}{
+		"deliveries": deliveries,
+	}
+	c.JSON(http.StatusOK, util.GetSuccessResponse(response))
+}
+
+func GetWebhookDeadLetters(c *gin.Context) {
+	deliveries, err := webhook.DefaultDispatcher.GetDeadLetters()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, util.GetFailResponse(err.Error()))
+		return
+	}
+
+	response := map[string]interface{}{
+		"deliveries": deliveries,
+	}
+	c.JSON(http.StatusOK, util.GetSuccessResponse(response))
+}
+
+func GetWebhookDelivery(c *gin.Context) {
+	delivery, err := webhook.DefaultDispatcher.GetDelivery(c.Param("id"))
+	if err != nil {
+		writeWebhookError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, util.GetSuccessResponse(delivery))
+}
+
+// RedeliverWebhook sends a delivery again in the background, returning it
+// pending.
+func RedeliverWebhook(c *gin.Context) {
+	delivery, err := webhook.DefaultDispatcher.Redeliver(c.Param("id"))
+	if err != nil {
+		writeWebhookError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, util.GetSuccessResponse(delivery))
+}
+
+func writeWebhookError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, webhook.ErrNotFound):
+		c.JSON(http.StatusNotFound, util.GetFailResponse("Delivery not found"))
+	case errors.Is(err, webhook.ErrInProgress):
+		c.JSON(http.StatusConflict, util.GetFailResponse("Delivery in progress"))
+	default:
+		c.JSON(http.StatusInternalServerError, util.GetFailResponse(err.Error()))
+	}
+}
@@ -1,20 +1,79 @@
 package server
 
 import (
+	"context"
+	"errors"
 	"ethereum-parser/config"
 	"ethereum-parser/server/controller"
+	"net"
+	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
-func StartServer() {
+// ShutdownTimeout bounds how long StartServer waits for requests in flight
+// once its context is cancelled.
+var ShutdownTimeout = 10 * time.Second
+
+// StartServer serves the API until ctx is cancelled, then shuts the server
+// down. Requests get a context derived from ctx, so streams end along with it.
+func StartServer(ctx context.Context) error {
 	r := gin.Default()
 
 	r.GET("/ws", controller.HandleWebSocket)
+	r.GET("/stream", controller.HandleStream)
 	r.GET("/current-block", controller.GetCurrentBlock)
+	r.GET("/blocks", controller.GetBlocks)
+	r.GET("/blocks/:block", controller.GetBlock)
+	r.GET("/blocks/:block/transactions", controller.GetBlockTransactions)
 	r.GET("/transaction/:address", controller.GetCurrentBlockTransactionsByAddress)
+	r.GET("/addresses/:address/transactions", controller.GetAddressTransactions)
+
+	r.POST("/watch-lists", controller.CreateWatchList)
+	r.GET("/watch-lists", controller.GetWatchLists)
+	r.GET("/watch-lists/:id", controller.GetWatchList)
+	r.DELETE("/watch-lists/:id", controller.DeleteWatchList)
+	r.POST("/watch-lists/:id/addresses", controller.AddWatchListAddresses)
+	r.DELETE("/watch-lists/:id/addresses", controller.RemoveWatchListAddresses)
+	r.DELETE("/watch-lists/:id/addresses/:address", controller.RemoveWatchListAddresses)
+
+	r.POST("/subscriptions", controller.CreateSubscriptions)
+	r.GET("/subscriptions", controller.GetSubscriptions)
+	r.DELETE("/subscriptions", controller.DeleteSubscriptions)
+	r.GET("/subscriptions/:id", controller.GetSubscription)
+	r.DELETE("/subscriptions/:id", controller.DeleteSubscription)
+
+	r.GET("/webhooks/deliveries", controller.GetWebhookDeliveries)
+	r.GET("/webhooks/deliveries/:id", controller.GetWebhookDelivery)
+	r.POST("/webhooks/deliveries/:id/redeliver", controller.RedeliverWebhook)
+	r.GET("/webhooks/dead-letters", controller.GetWebhookDeadLetters)
 
 	port := config.Config.Server.Port
-	r.Run(":" + strconv.Itoa(port))
+	server := &http.Server{
+		Addr:        ":" + strconv.Itoa(port),
+		Handler:     r,
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil && !errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+
+	return nil
 }
@@ -0,0 +1,109 @@
+package util
+
+import (
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"golang.org/x/crypto/sha3"
+)
+
+const AddressLength = 20
+
+// Address is a 20 byte account address. Being comparable it can be used as a
+// map key regardless of the case the address was written in, and it is
+// written out with the EIP-55 mixed-case checksum.
+type Address [AddressLength]byte
+
+// ParseAddress parses a "0x" prefixed hex address. All lowercase and all
+// uppercase addresses are accepted as is, mixed-case ones must carry a valid
+// EIP-55 checksum.
+func ParseAddress(s string) (Address, error) {
+	var a Address
+	if len(s) != 2+2*AddressLength || !strings.HasPrefix(s, "0x") {
+		return a, errors.New("invalid address, " + s)
+	}
+
+	if _, err := hex.Decode(a[:], []byte(s[2:])); err != nil {
+		return Address{}, errors.New("invalid address, " + s)
+	}
+
+	digits := s[2:]
+	if digits != strings.ToLower(digits) && digits != strings.ToUpper(digits) && a.Hex() != s {
+		return Address{}, errors.New("invalid address checksum, " + s)
+	}
+
+	return a, nil
+}
+
+// MustParseAddress is ParseAddress for constants, it panics on invalid input.
+func MustParseAddress(s string) Address {
+	a, err := ParseAddress(s)
+	if err != nil {
+		panic(err)
+	}
+
+	return a
+}
+
+// ChecksumAddress returns address with its EIP-55 checksum, or address
+// unchanged when it is not a valid address.
+func ChecksumAddress(address string) string {
+	a, err := ParseAddress(address)
+	if err != nil {
+		return address
+	}
+
+	return a.Hex()
+}
+
+// Hex returns the EIP-55 checksummed form of the address.
+func (a Address) Hex() string {
+	lower := hex.EncodeToString(a[:])
+
+	hash := sha3.NewLegacyKeccak256()
+	hash.Write([]byte(lower))
+	digest := hash.Sum(nil)
+
+	checksummed := []byte(lower)
+	for i, c := range checksummed {
+		// Letters whose nibble of the hash of the lowercase address is 8 or
+		// more are uppercased
+		nibble := digest[i/2] >> 4
+		if i%2 == 1 {
+			nibble = digest[i/2] & 0x0f
+		}
+		if c >= 'a' && nibble >= 8 {
+			checksummed[i] = c - 'a' + 'A'
+		}
+	}
+
+	return "0x" + string(checksummed)
+}
+
+// Lower returns the lowercase form of the address, as used for storage keys.
+func (a Address) Lower() string {
+	return "0x" + hex.EncodeToString(a[:])
+}
+
+func (a Address) String() string {
+	return a.Hex()
+}
+
+func (a Address) IsZero() bool {
+	return a == Address{}
+}
+
+func (a Address) MarshalText() ([]byte, error) {
+	return []byte(a.Hex()), nil
+}
+
+func (a *Address) UnmarshalText(text []byte) error {
+	parsed, err := ParseAddress(string(text))
+	if err != nil {
+		return err
+	}
+
+	*a = parsed
+	return nil
+}
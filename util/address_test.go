@@ -0,0 +1,72 @@
+package util_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"ethereum-parser/util"
+)
+
+func TestParseAddress(t *testing.T) {
+	tests := []struct {
+		input       string
+		expected    string
+		expectedErr string
+	}{
+		// EIP-55 test vectors
+		{"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed", "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed", ""},
+		{"0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359", "0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359", ""},
+		{"0xdbF03B407c01E7cD3CBea99509d93f8DDDC8C6FB", "0xdbF03B407c01E7cD3CBea99509d93f8DDDC8C6FB", ""},
+		{"0xD1220A0cf47c7B9Be7A2E6BA89F429762e7b9aDb", "0xD1220A0cf47c7B9Be7A2E6BA89F429762e7b9aDb", ""},
+		{"0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed", "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed", ""}, // Lowercase
+		{"0x5AAEB6053F3E94C9B9A09F33669435E7EF1BEAED", "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed", ""}, // Uppercase
+		{"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAeD", "", "invalid address checksum"},
+		{"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeA", "", "invalid address"},
+		{"5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed00", "", "invalid address"},
+		{"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAeg", "", "invalid address"},
+	}
+
+	for _, test := range tests {
+		address, err := util.ParseAddress(test.input)
+
+		if test.expectedErr != "" {
+			assert.ErrorContains(t, err, test.expectedErr, "Unexpected error for address: %s", test.input)
+			continue
+		}
+
+		assert.NoError(t, err, "Unexpected error for address: %s", test.input)
+		assert.Equal(t, test.expected, address.Hex(), "Unexpected checksum for address: %s", test.input)
+	}
+}
+
+func TestAddress_MapKey(t *testing.T) {
+	subscriptions := map[util.Address]bool{
+		util.MustParseAddress("0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed"): true,
+	}
+
+	assert.True(t, subscriptions[util.MustParseAddress("0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed")], "Case should not matter")
+	assert.Equal(t, "0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed", util.MustParseAddress("0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed").Lower())
+}
+
+func TestAddress_JSON(t *testing.T) {
+	var decoded struct {
+		Address util.Address
+	}
+	err := json.Unmarshal([]byte(`{"Address":"0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359"}`), &decoded)
+	assert.NoError(t, err, "Unexpected error")
+
+	encoded, err := json.Marshal(decoded)
+	assert.NoError(t, err, "Unexpected error")
+	assert.JSONEq(t, `{"Address":"0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359"}`, string(encoded), "Addresses should be written checksummed")
+
+	err = json.Unmarshal([]byte(`{"Address":"0xFb6916095ca1df60bB79Ce92cE3Ea74c37c5d359"}`), &decoded)
+	assert.Error(t, err, "Bad checksums should be rejected")
+}
+
+func TestChecksumAddress(t *testing.T) {
+	assert.Equal(t, "0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359", util.ChecksumAddress("0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359"))
+	assert.Equal(t, "", util.ChecksumAddress(""), "Missing addresses should be left empty")
+	assert.Equal(t, "0xa", util.ChecksumAddress("0xa"), "Invalid addresses should be left unchanged")
+}
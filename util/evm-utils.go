@@ -1,16 +1,19 @@
 package util
 
 import (
-	"regexp"
 	"strconv"
 	"strings"
 )
 
+// IsValidAddress reports whether address is a hex address, rejecting
+// mixed-case addresses whose EIP-55 checksum does not match.
 func IsValidAddress(address string) bool {
-	re := regexp.MustCompile("^0x[0-9a-fA-F]{40}$")
-	return re.MatchString(address)
+	_, err := ParseAddress(address)
+	return err == nil
 }
 
+// HexToDecimal parses small hex numbers such as block numbers and indexes,
+// amounts of wei need ParseQuantity.
 func HexToDecimal(hex string) (int64, error) {
 	hex = strings.Replace(hex, "0x", "", -1)
 	return strconv.ParseInt(hex, 16, 64)
@@ -18,6 +18,8 @@ func TestIsValidAddress(t *testing.T) {
 		{"0x7a250d5630B4cF539739dF2C5dAcb4c659F2488Dasdd", false}, // Address too long
 		{"0x7a250d5630B4cF539739dF2C5dAcb4c659F2488D", true},      // Valid address
 		{"0x123g567890", false},                                   // Invalid character 'g'
+		{"0x7a250d5630b4cf539739df2c5dacb4c659f2488d", true},      // Lowercase, no checksum
+		{"0x7A250d5630B4cF539739dF2C5dAcb4c659F2488D", false},     // Bad checksum
 	}
 
 	for _, test := range tests {
@@ -0,0 +1,18 @@
+package util
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+)
+
+// NewID returns a random 16 hex digit identifier, for records such as
+// watch-lists and subscriptions.
+func NewID() (string, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", errors.New("error generating id, " + err.Error())
+	}
+
+	return hex.EncodeToString(id), nil
+}
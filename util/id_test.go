@@ -0,0 +1,19 @@
+package util_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"ethereum-parser/util"
+)
+
+func TestNewID(t *testing.T) {
+	first, err := util.NewID()
+	assert.NoError(t, err)
+	assert.Regexp(t, "^[0-9a-f]{16}$", first)
+
+	second, err := util.NewID()
+	assert.NoError(t, err)
+	assert.NotEqual(t, first, second)
+}
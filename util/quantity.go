@@ -0,0 +1,138 @@
+package util
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strings"
+)
+
+// Quantity is an arbitrary precision unsigned integer as used for wei amounts
+// and block numbers. It reads "0x" prefixed hex or decimal strings as well as
+// JSON numbers, and writes hex as the JSON-RPC API does.
+type Quantity struct {
+	value big.Int
+}
+
+func NewQuantity(value *big.Int) Quantity {
+	var q Quantity
+	if value != nil && value.Sign() > 0 {
+		q.value.Set(value)
+	}
+
+	return q
+}
+
+// ParseQuantity parses a "0x" prefixed hex or a decimal string. The empty
+// string is zero.
+func ParseQuantity(s string) (Quantity, error) {
+	var q Quantity
+	if s == "" {
+		return q, nil
+	}
+
+	digits, base := s, 10
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		digits, base = s[2:], 16
+	}
+
+	if digits == "" || strings.HasPrefix(digits, "+") || strings.HasPrefix(digits, "-") {
+		return q, errors.New("invalid quantity, " + s)
+	}
+
+	value, ok := new(big.Int).SetString(digits, base)
+	if !ok {
+		return q, errors.New("invalid quantity, " + s)
+	}
+
+	return NewQuantity(value), nil
+}
+
+// MustParseQuantity is ParseQuantity for constants, it panics on invalid input.
+func MustParseQuantity(s string) Quantity {
+	q, err := ParseQuantity(s)
+	if err != nil {
+		panic(err)
+	}
+
+	return q
+}
+
+// Big returns a copy of the value.
+func (q Quantity) Big() *big.Int {
+	return new(big.Int).Set(&q.value)
+}
+
+func (q Quantity) IsZero() bool {
+	return q.value.Sign() == 0
+}
+
+func (q Quantity) Cmp(other Quantity) int {
+	return q.value.Cmp(&other.value)
+}
+
+// Int returns the value as an int, failing when it does not fit.
+func (q Quantity) Int() (int, error) {
+	if !q.value.IsInt64() || q.value.Int64() != int64(int(q.value.Int64())) {
+		return 0, errors.New("quantity out of range, " + q.String())
+	}
+
+	return int(q.value.Int64()), nil
+}
+
+func (q Quantity) Hex() string {
+	return "0x" + q.value.Text(16)
+}
+
+// String returns the value in decimal.
+func (q Quantity) String() string {
+	return q.value.String()
+}
+
+// Format returns the value divided by 10^decimals, e.g. Format(EtherDecimals)
+// for an amount of wei.
+func (q Quantity) Format(decimals int) string {
+	return FormatUnits(&q.value, decimals)
+}
+
+func (q Quantity) MarshalJSON() ([]byte, error) {
+	return json.Marshal(q.Hex())
+}
+
+func (q *Quantity) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*q = Quantity{}
+		return nil
+	}
+
+	var s string
+	if len(data) > 0 && data[0] == '"' {
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+	} else {
+		s = string(data)
+	}
+
+	parsed, err := ParseQuantity(s)
+	if err != nil {
+		return err
+	}
+
+	*q = parsed
+	return nil
+}
+
+// ParseBlockNumber parses a hex block number as returned by the node.
+func ParseBlockNumber(hex string) (int, error) {
+	if !strings.HasPrefix(hex, "0x") {
+		return 0, errors.New("invalid block number, " + hex)
+	}
+
+	q, err := ParseQuantity(hex)
+	if err != nil {
+		return 0, err
+	}
+
+	return q.Int()
+}
@@ -0,0 +1,95 @@
+package util_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"ethereum-parser/util"
+)
+
+func TestParseQuantity(t *testing.T) {
+	tests := []struct {
+		input       string
+		expected    string
+		expectedErr bool
+	}{
+		{"", "0", false},    // Empty is zero
+		{"0x0", "0", false}, // Hex 0
+		{"0xde0b6b3a7640000", "1000000000000000000", false},            // 1 ether in wei
+		{"0xffffffffffffffffffff", "1208925819614629174706175", false}, // Beyond int64
+		{"1000", "1000", false},                                        // Decimal
+		{"0x", "", true},                                               // No digits
+		{"0xzz", "", true},                                             // Invalid hex
+		{"-1", "", true},                                               // Negative
+		{"1.5", "", true},                                              // Not an integer
+	}
+
+	for _, test := range tests {
+		quantity, err := util.ParseQuantity(test.input)
+
+		if test.expectedErr {
+			assert.Error(t, err, "Expected error for input: %s", test.input)
+			continue
+		}
+
+		assert.NoError(t, err, "Unexpected error for input: %s", test.input)
+		assert.Equal(t, test.expected, quantity.String(), "Unexpected value for input: %s", test.input)
+	}
+}
+
+func TestQuantity_JSON(t *testing.T) {
+	var values struct {
+		Hex     util.Quantity
+		Decimal util.Quantity
+		Number  util.Quantity
+		Missing util.Quantity
+	}
+	err := json.Unmarshal([]byte(`{"Hex":"0x1bc16d674ec80000","Decimal":"2000000000000000000","Number":2000000000000000000}`), &values)
+
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, values.Hex, values.Decimal, "Hex and decimal should decode to the same value")
+	assert.Equal(t, values.Hex, values.Number, "Hex and numbers should decode to the same value")
+	assert.True(t, values.Missing.IsZero(), "Missing values should be zero")
+
+	encoded, err := json.Marshal(values)
+	assert.NoError(t, err, "Unexpected error")
+	assert.JSONEq(t, `{"Hex":"0x1bc16d674ec80000","Decimal":"0x1bc16d674ec80000","Number":"0x1bc16d674ec80000","Missing":"0x0"}`, string(encoded))
+
+	assert.Error(t, json.Unmarshal([]byte(`{"Hex":"0xzz"}`), &values), "Invalid quantities should fail")
+}
+
+func TestQuantity_Int(t *testing.T) {
+	number, err := util.MustParseQuantity("0x10").Int()
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, 16, number)
+
+	_, err = util.MustParseQuantity("0xffffffffffffffffffff").Int()
+	assert.Error(t, err, "Values beyond int should fail")
+}
+
+func TestParseBlockNumber(t *testing.T) {
+	tests := []struct {
+		hex         string
+		expected    int
+		expectedErr bool
+	}{
+		{"0x10", 16, false},
+		{"", 0, true},
+		{"0x", 0, true},
+		{"16", 0, true},
+	}
+
+	for _, test := range tests {
+		number, err := util.ParseBlockNumber(test.hex)
+
+		if test.expectedErr {
+			assert.Error(t, err, "Expected error for hex: %s", test.hex)
+			continue
+		}
+
+		assert.NoError(t, err, "Unexpected error for hex: %s", test.hex)
+		assert.Equal(t, test.expected, number, "Unexpected block number for hex: %s", test.hex)
+	}
+}
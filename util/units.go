@@ -0,0 +1,71 @@
+package util
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+)
+
+// Decimals of the common ether denominations, relative to wei
+const (
+	WeiDecimals   = 0
+	GweiDecimals  = 9
+	EtherDecimals = 18
+)
+
+func pow10(decimals int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)
+}
+
+// FormatUnits renders value divided by 10^decimals as an exact decimal
+// string without trailing zeros, e.g. 1500000000000000000 with 18 decimals
+// is "1.5".
+func FormatUnits(value *big.Int, decimals int) string {
+	if value == nil {
+		return "0"
+	}
+
+	if decimals <= 0 {
+		return value.String()
+	}
+
+	sign := ""
+	abs := new(big.Int).Abs(value)
+	if value.Sign() < 0 {
+		sign = "-"
+	}
+
+	integer, fraction := new(big.Int).QuoRem(abs, pow10(decimals), new(big.Int))
+	if fraction.Sign() == 0 {
+		return sign + integer.String()
+	}
+
+	fractionDigits := fraction.String()
+	fractionDigits = strings.Repeat("0", decimals-len(fractionDigits)) + fractionDigits
+
+	return sign + integer.String() + "." + strings.TrimRight(fractionDigits, "0")
+}
+
+// ParseUnits converts a decimal amount such as "1.5" into its integer value
+// in units of 10^-decimals, e.g. wei for 18 decimals.
+func ParseUnits(amount string, decimals int) (*big.Int, error) {
+	integer, fraction, _ := strings.Cut(amount, ".")
+	if integer == "" && fraction == "" || strings.ContainsAny(amount, "+-") {
+		return nil, errors.New("invalid amount, " + amount)
+	}
+
+	if len(fraction) > decimals {
+		if strings.Trim(fraction[decimals:], "0") != "" {
+			return nil, errors.New("amount has too many decimals, " + amount)
+		}
+		fraction = fraction[:decimals]
+	}
+
+	digits := integer + fraction + strings.Repeat("0", decimals-len(fraction))
+	value, ok := new(big.Int).SetString(digits, 10)
+	if !ok {
+		return nil, errors.New("invalid amount, " + amount)
+	}
+
+	return value, nil
+}
@@ -0,0 +1,63 @@
+package util_test
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"ethereum-parser/util"
+)
+
+func TestFormatUnits(t *testing.T) {
+	tests := []struct {
+		value    string
+		decimals int
+		expected string
+	}{
+		{"0", util.EtherDecimals, "0"},
+		{"1000000000000000000", util.EtherDecimals, "1"},
+		{"1500000000000000000", util.EtherDecimals, "1.5"},
+		{"1", util.EtherDecimals, "0.000000000000000001"},
+		{"123456789000000000000000", util.EtherDecimals, "123456.789"},
+		{"30000000000", util.GweiDecimals, "30"},
+		{"42", util.WeiDecimals, "42"},
+		{"-1500000", 6, "-1.5"},
+	}
+
+	for _, test := range tests {
+		value, _ := new(big.Int).SetString(test.value, 10)
+		assert.Equal(t, test.expected, util.FormatUnits(value, test.decimals), "Unexpected result for value: %s", test.value)
+	}
+}
+
+func TestParseUnits(t *testing.T) {
+	tests := []struct {
+		amount      string
+		decimals    int
+		expected    string
+		expectedErr bool
+	}{
+		{"1", util.EtherDecimals, "1000000000000000000", false},
+		{"1.5", util.EtherDecimals, "1500000000000000000", false},
+		{".5", util.GweiDecimals, "500000000", false},
+		{"30", util.GweiDecimals, "30000000000", false},
+		{"1.10", 1, "11", false}, // Trailing zeros beyond the decimals are fine
+		{"1.11", 1, "", true},    // Too precise
+		{"", util.EtherDecimals, "", true},
+		{"-1", util.EtherDecimals, "", true},
+		{"1e18", util.EtherDecimals, "", true},
+	}
+
+	for _, test := range tests {
+		value, err := util.ParseUnits(test.amount, test.decimals)
+
+		if test.expectedErr {
+			assert.Error(t, err, "Expected error for amount: %s", test.amount)
+			continue
+		}
+
+		assert.NoError(t, err, "Unexpected error for amount: %s", test.amount)
+		assert.Equal(t, test.expected, value.String(), "Unexpected result for amount: %s", test.amount)
+	}
+}